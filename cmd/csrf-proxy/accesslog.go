@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CSRF verdicts recorded in access log entries.
+const (
+	verdictSafe   = "safe"   // safe method, forwarded without validation
+	verdictPass   = "pass"   // unsafe method, validated and forwarded
+	verdictReject = "reject" // turned away by the middleware
+	verdictReport = "report" // failed validation, forwarded in report-only mode
+)
+
+// accessEntry is one JSON access log line.
+type accessEntry struct {
+	Time       string `json:"time"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Query      string `json:"query,omitempty"`
+	Status     int    `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	RemoteAddr string `json:"remote_addr"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	Origin     string `json:"origin,omitempty"`
+	Referer    string `json:"referer,omitempty"`
+	Verdict    string `json:"verdict"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// redactable maps the -redact field names to setters that blank them out.
+var redactable = map[string]func(e *accessEntry){
+	"query":       func(e *accessEntry) { e.Query = redactedValue(e.Query) },
+	"remote_addr": func(e *accessEntry) { e.RemoteAddr = redactedValue(e.RemoteAddr) },
+	"user_agent":  func(e *accessEntry) { e.UserAgent = redactedValue(e.UserAgent) },
+	"origin":      func(e *accessEntry) { e.Origin = redactedValue(e.Origin) },
+	"referer":     func(e *accessEntry) { e.Referer = redactedValue(e.Referer) },
+}
+
+func redactedValue(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+// accessLogger writes one JSON line per request to out, applying redaction.
+type accessLogger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	redact []func(e *accessEntry)
+}
+
+// newAccessLogger parses a comma-separated list of field names to redact.
+//
+// Params:
+// - out: destination of the JSON lines.
+// - redact: comma-separated field names (see redactable).
+//
+// Returns:
+// - *accessLogger ready for use, or an error naming the valid fields when
+// redact lists an unknown one, so a typo never leaves data unredacted.
+func newAccessLogger(out io.Writer, redact string) (*accessLogger, error) {
+	l := &accessLogger{out: out}
+	for _, name := range strings.Split(redact, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		f, ok := redactable[name]
+		if !ok {
+			valid := make([]string, 0, len(redactable))
+			for k := range redactable {
+				valid = append(valid, k)
+			}
+			slices.Sort(valid)
+			return nil, fmt.Errorf("unknown -redact field %q (valid: %s)", name, strings.Join(valid, ", "))
+		}
+		l.redact = append(l.redact, f)
+	}
+	return l, nil
+}
+
+// logState is the per-request state shared between the access log wrapper,
+// the OnReject hook and the upstream handler.
+type logState struct {
+	forwarded bool
+	reason    string
+}
+
+type logStateKey struct{}
+
+// recordReject is installed as csrf.Config.OnReject.
+func recordReject(r *http.Request, err error) {
+	if st, ok := r.Context().Value(logStateKey{}).(*logState); ok {
+		st.reason = err.Error()
+	}
+}
+
+// markForwarded wraps the upstream handler so the log knows the request made
+// it past the middleware.
+func markForwarded(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if st, ok := r.Context().Value(logStateKey{}).(*logState); ok {
+			st.forwarded = true
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wrap returns next instrumented with access logging.
+func (l *accessLogger) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		st := &logState{}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), logStateKey{}, st)))
+
+		e := accessEntry{
+			Time:       start.UTC().Format(time.RFC3339Nano),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      r.URL.RawQuery,
+			Status:     rec.status,
+			DurationMS: time.Since(start).Milliseconds(),
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+			Origin:     r.Header.Get("Origin"),
+			Referer:    r.Referer(),
+			Reason:     st.reason,
+		}
+		switch {
+		case !st.forwarded:
+			e.Verdict = verdictReject
+		case st.reason != "":
+			e.Verdict = verdictReport
+		case isSafeMethod(r.Method):
+			e.Verdict = verdictSafe
+		default:
+			e.Verdict = verdictPass
+		}
+		l.write(e)
+	})
+}
+
+func (l *accessLogger) write(e accessEntry) {
+	for _, f := range l.redact {
+		f(&e)
+	}
+	b, _ := json.Marshal(e)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(append(b, '\n'))
+}
+
+func isSafeMethod(m string) bool {
+	switch m {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return false
+	}
+	return true
+}
+
+// statusRecorder captures the response status code.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.status = code
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+// The methods below keep optional ResponseWriter interfaces reachable through
+// the recorder, so streaming (SSE), WebSocket upgrades and sendfile keep
+// working behind the access logger.
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Flush implements http.Flusher; it is a no-op if the underlying writer
+// cannot flush.
+func (s *statusRecorder) Flush() {
+	s.wroteHeader = true
+	_ = http.NewResponseController(s.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker, failing with http.ErrNotSupported if the
+// underlying writer cannot be hijacked.
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(s.ResponseWriter).Hijack()
+	if err == nil && !s.wroteHeader {
+		s.status = http.StatusSwitchingProtocols
+		s.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// ReadFrom implements io.ReaderFrom, delegating to the underlying writer's
+// ReadFrom (sendfile) when it has one.
+func (s *statusRecorder) ReadFrom(src io.Reader) (int64, error) {
+	s.wroteHeader = true
+	if rf, ok := s.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(s.ResponseWriter, src)
+}
+
+// Push implements http.Pusher, failing with http.ErrNotSupported if the
+// underlying writer cannot push.
+func (s *statusRecorder) Push(target string, opts *http.PushOptions) error {
+	if p, ok := s.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
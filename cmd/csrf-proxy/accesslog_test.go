@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JeanGrijp/go-csrf/csrf"
+)
+
+func TestAccessLogVerdicts(t *testing.T) {
+	var buf bytes.Buffer
+	p := csrf.New(csrf.Config{OnReject: recordReject})
+	upstream := markForwarded(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	l, err := newAccessLogger(&buf, "user_agent, origin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := l.wrap(p.Protect(upstream))
+
+	get := httptest.NewRequest(http.MethodGet, "/", nil)
+	get.Header.Set("User-Agent", "secret-agent")
+	h.ServeHTTP(httptest.NewRecorder(), get)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+	dec := json.NewDecoder(&buf)
+	var safe, rejected accessEntry
+	if err := dec.Decode(&safe); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if err := dec.Decode(&rejected); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if safe.Verdict != verdictSafe || safe.UserAgent != "[redacted]" {
+		t.Fatalf("unexpected safe entry: %+v", safe)
+	}
+	if rejected.Verdict != verdictReject || rejected.Reason != "missing CSRF token" || rejected.Status != http.StatusForbidden {
+		t.Fatalf("unexpected rejected entry: %+v", rejected)
+	}
+}
+
+// In report-only mode a failing request is forwarded and logged as
+// "report", not "reject".
+func TestAccessLogReportVerdict(t *testing.T) {
+	var buf bytes.Buffer
+	p := csrf.New(csrf.Config{OnReject: recordReject, ReportOnly: true})
+	l, _ := newAccessLogger(&buf, "")
+	h := l.wrap(p.Protect(markForwarded(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+	var e accessEntry
+	if err := json.NewDecoder(&buf).Decode(&e); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if e.Verdict != verdictReport || e.Reason != "missing CSRF token" || e.Status != http.StatusOK {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+}
+
+// The recorder keeps Flusher, Hijacker, ReaderFrom and Pusher reachable so
+// streaming responses and upgrades work behind the access logger.
+func TestStatusRecorderInterfaces(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := newAccessLogger(&buf, "")
+	h := l.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(interface {
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}); !ok {
+			t.Error("expected optional interfaces to be preserved")
+		}
+		if _, _, err := http.NewResponseController(w).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("expected ErrNotSupported from hijack, got %v", err)
+		}
+		_, _ = w.Write([]byte("data: 1\n\n"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("flush: %v", err)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if !rec.Flushed {
+		t.Fatal("expected the flush to reach the underlying writer")
+	}
+}
+
+// A misspelled -redact field is an error, not silently ignored.
+func TestAccessLogUnknownRedactField(t *testing.T) {
+	for _, redact := range []string{"remote-addr", "query,ip"} {
+		if _, err := newAccessLogger(io.Discard, redact); err == nil || !strings.Contains(err.Error(), "remote_addr") {
+			t.Fatalf("%q: got %v", redact, err)
+		}
+	}
+}
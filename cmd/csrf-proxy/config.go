@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/JeanGrijp/go-csrf/csrf"
+)
+
+// fileConfig is the on-disk JSON configuration of the proxy. Only settings
+// that are safe to change at runtime live here; listen address and upstream
+// are fixed by flags for the lifetime of the process.
+type fileConfig struct {
+	CookieName         string   `json:"cookieName"`
+	CookieDomain       string   `json:"cookieDomain"`
+	CookieSecure       bool     `json:"cookieSecure"`
+	CookieSameSite     string   `json:"cookieSameSite"` // "lax", "strict" or "none"
+	HeaderName         string   `json:"headerName"`
+	FormField          string   `json:"formField"`
+	EnforceOriginCheck bool     `json:"enforceOriginCheck"`
+	AllowedOrigins     []string `json:"allowedOrigins"`
+	AllowedOrigin      string   `json:"allowedOrigin"` // deprecated: use allowedOrigins
+	ReportOnly         bool     `json:"reportOnly"`
+
+	// SigningKeys enables signed tokens; rotate by adding the new key
+	// first and keeping the old one with verifyOnly until its tokens
+	// expire.
+	SigningKeys []signingKey `json:"signingKeys"`
+}
+
+// signingKey is one entry of fileConfig.SigningKeys, in the format of
+// csrf.FileSecretProvider.
+type signingKey struct {
+	ID         string `json:"id"`
+	Secret     string `json:"secret"` // base64
+	VerifyOnly bool   `json:"verifyOnly"`
+}
+
+// loadConfig reads and parses the JSON file at path into a csrf.Config.
+//
+// Params:
+// - path: JSON configuration file; empty means all library defaults.
+//
+// Returns:
+// - the csrf.Config to build a Protector from, or an error.
+func loadConfig(path string) (csrf.Config, error) {
+	var fc fileConfig
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return csrf.Config{}, err
+		}
+		if err := json.Unmarshal(b, &fc); err != nil {
+			return csrf.Config{}, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	cfg := csrf.Config{
+		CookieName:         fc.CookieName,
+		CookieDomain:       fc.CookieDomain,
+		CookieSecure:       fc.CookieSecure,
+		HeaderName:         fc.HeaderName,
+		EnforceOriginCheck: fc.EnforceOriginCheck,
+		AllowedOrigins:     fc.AllowedOrigins,
+		ReportOnly:         fc.ReportOnly,
+	}
+	for _, k := range fc.SigningKeys {
+		secret, err := base64.StdEncoding.DecodeString(k.Secret)
+		if err != nil {
+			return csrf.Config{}, fmt.Errorf("signing key %q: %w", k.ID, err)
+		}
+		cfg.SigningKeys = append(cfg.SigningKeys, csrf.SigningKeyEntry{ID: k.ID, Secret: secret, VerifyOnly: k.VerifyOnly})
+	}
+	if fc.FormField != "" {
+		cfg.FormFields = []string{fc.FormField}
+	}
+	if fc.AllowedOrigin != "" && !slices.Contains(cfg.AllowedOrigins, fc.AllowedOrigin) {
+		cfg.AllowedOrigins = append([]string{fc.AllowedOrigin}, cfg.AllowedOrigins...)
+	}
+	switch strings.ToLower(fc.CookieSameSite) {
+	case "":
+	case "lax":
+		cfg.CookieSameSite = http.SameSiteLaxMode
+	case "strict":
+		cfg.CookieSameSite = http.SameSiteStrictMode
+	case "none":
+		cfg.CookieSameSite = http.SameSiteNoneMode
+	default:
+		return csrf.Config{}, fmt.Errorf("invalid cookieSameSite %q", fc.CookieSameSite)
+	}
+	return cfg, nil
+}
+
+// buildHandler loads the configuration at path and wraps proxy with a new
+// Protector built from it with csrf.TryNew, so a bad file yields an error
+// instead of a panic.
+//
+// Params:
+// - path: JSON configuration file; empty means all library defaults.
+// - proxy: the upstream handler.
+//
+// Returns:
+// - the protected handler, or an error.
+func buildHandler(path string, proxy http.Handler) (http.Handler, error) {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.OnReject = recordReject
+	p, err := csrf.TryNew(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return p.Protect(proxy), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "csrf.json")
+	os.WriteFile(path, []byte(`{"cookieSecure":true,"cookieSameSite":"strict","enforceOriginCheck":true,"allowedOrigin":"app.example.com"}`), 0o600)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.CookieSecure || cfg.CookieSameSite != http.SameSiteStrictMode || !cfg.EnforceOriginCheck || len(cfg.AllowedOrigins) != 1 || cfg.AllowedOrigins[0] != "app.example.com" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	os.WriteFile(path, []byte(`{"allowedOrigins":["www.example.com","admin.example.com"],"allowedOrigin":"app.example.com"}`), 0o600)
+	if cfg, err = loadConfig(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"app.example.com", "www.example.com", "admin.example.com"}; !slices.Equal(cfg.AllowedOrigins, want) {
+		t.Fatalf("AllowedOrigins: got %v, want %v", cfg.AllowedOrigins, want)
+	}
+
+	os.WriteFile(path, []byte(`{"cookieSameSite":"sometimes"}`), 0o600)
+	if _, err := loadConfig(path); err == nil {
+		t.Fatalf("expected error for invalid cookieSameSite")
+	}
+}
+
+// Report-only mode and signing keys come from the file; an invalid
+// configuration is an error, not a panic.
+func TestBuildHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "csrf.json")
+	upstream := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	os.WriteFile(path, []byte(`{"reportOnly":true,"signingKeys":[{"id":"k1","secret":"`+key+`"}]}`), 0o600)
+
+	cfg, err := loadConfig(path)
+	if err != nil || !cfg.ReportOnly || len(cfg.SigningKeys) != 1 || cfg.SigningKeys[0].ID != "k1" {
+		t.Fatalf("unexpected config: %+v, %v", cfg, err)
+	}
+	h, err := buildHandler(path, upstream)
+	if err != nil {
+		t.Fatalf("buildHandler: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("report-only POST: got %d", rec.Code)
+	}
+
+	for _, bad := range []string{
+		`{"signingKeys":[{"id":"k1","secret":"c2hvcnQ="}]}`, // too short
+		`{"signingKeys":[{"id":"k1","secret":"%%%"}]}`,
+	} {
+		os.WriteFile(path, []byte(bad), 0o600)
+		if _, err := buildHandler(path, upstream); err == nil {
+			t.Fatalf("%s: expected an error", bad)
+		}
+	}
+}
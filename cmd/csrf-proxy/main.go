@@ -0,0 +1,123 @@
+// Command csrf-proxy is a reverse proxy that enforces CSRF protection in front
+// of an upstream application that has none.
+//
+// Usage:
+//
+//	csrf-proxy -listen :8080 -upstream http://127.0.0.1:3000 -config csrf.json
+//
+// Every request is logged to stdout as one JSON line including the CSRF verdict
+// ("safe", "pass", "report" for failures forwarded in report-only mode, or
+// "reject") and the failure reason. Fields listed in
+// -redact (query, remote_addr, user_agent, origin, referer) are replaced with
+// "[redacted]"; an unknown field name stops the proxy at startup.
+//
+// The server bounds slow clients with -read-header-timeout (default 5s),
+// -read-timeout (30s) and -idle-timeout (2m); zero disables a limit.
+//
+// The configuration file is reloaded on SIGHUP and whenever its modification
+// time changes, so report-only mode can be flipped and signing keys rotated
+// without a restart. Reloads swap the protector atomically: the listener and
+// open connections are untouched, in-flight requests finish with the
+// previous settings and new requests use the new ones. An invalid file is
+// logged and the previous settings stay in effect.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to listen on")
+	upstream := flag.String("upstream", "", "upstream base URL (required)")
+	configPath := flag.String("config", "", "JSON configuration file")
+	poll := flag.Duration("poll", 2*time.Second, "interval to check the config file for changes (0 disables)")
+	redact := flag.String("redact", "", "comma-separated access log fields to redact")
+	readHeaderTimeout := flag.Duration("read-header-timeout", 5*time.Second, "maximum time to read request headers")
+	readTimeout := flag.Duration("read-timeout", 30*time.Second, "maximum time to read a whole request, body included")
+	idleTimeout := flag.Duration("idle-timeout", 2*time.Minute, "maximum time a keep-alive connection waits for the next request")
+	flag.Parse()
+
+	target, err := url.Parse(*upstream)
+	if err != nil || target.Host == "" {
+		log.Fatalf("csrf-proxy: invalid -upstream %q", *upstream)
+	}
+
+	proxy := markForwarded(httputil.NewSingleHostReverseProxy(target))
+	protected, err := buildHandler(*configPath, proxy)
+	if err != nil {
+		log.Fatalf("csrf-proxy: %v", err)
+	}
+	var current atomic.Pointer[http.Handler]
+	current.Store(&protected)
+
+	reload := func(reason string) {
+		h, err := buildHandler(*configPath, proxy)
+		if err != nil {
+			log.Printf("csrf-proxy: reload (%s) failed, keeping previous config: %v", reason, err)
+			return
+		}
+		current.Store(&h)
+		log.Printf("csrf-proxy: config reloaded (%s)", reason)
+	}
+	go watchSIGHUP(reload)
+	if *configPath != "" && *poll > 0 {
+		go watchFile(*configPath, *poll, reload)
+	}
+
+	accessLog, err := newAccessLogger(os.Stdout, *redact)
+	if err != nil {
+		log.Fatalf("csrf-proxy: %v", err)
+	}
+	handler := accessLog.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		(*current.Load()).ServeHTTP(w, r)
+	}))
+
+	log.Printf("csrf-proxy: listening on %s, forwarding to %s", *listen, target)
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           handler,
+		ReadHeaderTimeout: *readHeaderTimeout,
+		ReadTimeout:       *readTimeout,
+		IdleTimeout:       *idleTimeout,
+	}
+	log.Fatal(srv.ListenAndServe())
+}
+
+// watchSIGHUP calls reload every time the process receives SIGHUP.
+func watchSIGHUP(reload func(reason string)) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		reload("SIGHUP")
+	}
+}
+
+// watchFile polls path and calls reload whenever its modification time or
+// size changes.
+func watchFile(path string, every time.Duration, reload func(reason string)) {
+	var lastMod time.Time
+	var lastSize int64
+	if fi, err := os.Stat(path); err == nil {
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+	}
+	for range time.Tick(every) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if fi.ModTime().Equal(lastMod) && fi.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+		reload("file change")
+	}
+}
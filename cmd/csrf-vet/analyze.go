@@ -0,0 +1,442 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unsafeMethods are the HTTP methods whose routes must be protected.
+var unsafeMethods = map[string]bool{"POST": true, "PUT": true, "PATCH": true, "DELETE": true}
+
+// finding is an unprotected route registration.
+type finding struct {
+	Pos    token.Position
+	Method string // "" for ServeMux patterns without a method (-strict)
+	Path   string
+}
+
+// String formats f the way go vet prints diagnostics.
+func (f finding) String() string {
+	method := f.Method
+	if method == "" {
+		method = "any method"
+	}
+	return fmt.Sprintf("%s: %s %s is registered on a router not wrapped by csrf Protect", f.Pos, method, f.Path)
+}
+
+// analyzer finds route registrations for unsafe methods in one package that
+// no csrf Protect call covers. It works on syntax only: a router counts as
+// protected when it is passed to Protect (directly, through wrapping calls
+// or as the result of a function), given a middleware referring to Protect
+// with Use, or derived from a protected router (Group, Route, With,
+// sub-router closures). Functions whose body calls Protect (e.g., gin
+// adapters) count as Protect themselves.
+type analyzer struct {
+	fset   *token.FileSet
+	files  []*ast.File
+	strict bool
+
+	// protecting holds names of functions whose body calls Protect.
+	protecting map[string]bool
+	// returnsProtected holds names of functions whose result is wrapped.
+	returnsProtected map[string]bool
+	// marked holds keys (see key) of protected routers.
+	marked map[any]bool
+}
+
+// analyze reports the unprotected registrations in files, which must form
+// one package.
+//
+// Params:
+// - fset: file set the files were parsed with (comments included).
+// - files: the package's files.
+// - strict: also report ServeMux patterns without a method.
+//
+// Returns:
+// - findings sorted by position.
+func analyze(fset *token.FileSet, files []*ast.File, strict bool) []finding {
+	a := &analyzer{
+		fset:             fset,
+		files:            files,
+		strict:           strict,
+		protecting:       map[string]bool{},
+		returnsProtected: map[string]bool{},
+		marked:           map[any]bool{},
+	}
+	a.findProtectingFuncs()
+	for i := 0; i < 10 && a.markPass(); i++ {
+	}
+
+	var out []finding
+	for _, f := range files {
+		ignored := ignoredLines(fset, f)
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			method, path, ok := a.registration(call)
+			if !ok || a.covered(call) {
+				return true
+			}
+			pos := fset.Position(call.Pos())
+			if ignored[pos.Line] || ignored[pos.Line-1] {
+				return true
+			}
+			out = append(out, finding{Pos: pos, Method: method, Path: path})
+			return true
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Pos.Filename != out[j].Pos.Filename {
+			return out[i].Pos.Filename < out[j].Pos.Filename
+		}
+		return out[i].Pos.Offset < out[j].Pos.Offset
+	})
+	return out
+}
+
+// ignoredLines returns the lines carrying a //csrf:ignore comment.
+func ignoredLines(fset *token.FileSet, f *ast.File) map[int]bool {
+	lines := map[int]bool{}
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//csrf:ignore") {
+				lines[fset.Position(c.Pos()).Line] = true
+			}
+		}
+	}
+	return lines
+}
+
+// findProtectingFuncs collects the functions whose body calls Protect,
+// directly or through other such functions.
+func (a *analyzer) findProtectingFuncs() {
+	for changed := true; changed; {
+		changed = false
+		for _, f := range a.files {
+			for _, d := range f.Decls {
+				fd, ok := d.(*ast.FuncDecl)
+				if !ok || fd.Body == nil || a.protecting[fd.Name.Name] {
+					continue
+				}
+				if a.refersToProtect(fd.Body, 0) {
+					a.protecting[fd.Name.Name] = true
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+// markPass runs one round of router marking.
+//
+// Returns:
+// - true if a new router was marked.
+func (a *analyzer) markPass() bool {
+	before := len(a.marked)
+	for _, f := range a.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				a.markCall(n)
+			case *ast.AssignStmt:
+				for i, rhs := range n.Rhs {
+					if i < len(n.Lhs) && a.derivedFromProtected(rhs) {
+						a.mark(n.Lhs[i])
+					}
+				}
+			case *ast.ValueSpec:
+				for i, v := range n.Values {
+					if i < len(n.Names) && a.derivedFromProtected(v) {
+						a.mark(n.Names[i])
+					}
+				}
+			case *ast.FuncDecl:
+				if n.Body != nil && a.returnsProtected[n.Name.Name] {
+					ast.Inspect(n.Body, func(m ast.Node) bool {
+						if ret, ok := m.(*ast.ReturnStmt); ok {
+							for _, r := range ret.Results {
+								a.mark(r)
+							}
+						}
+						return true
+					})
+				}
+			}
+			return true
+		})
+	}
+	return len(a.marked) > before
+}
+
+// markCall marks the routers covered by call: the arguments of Protect
+// (through wrapping calls), the receiver of Use(<protecting middleware>) and
+// the router parameter of closures passed to a protected router.
+func (a *analyzer) markCall(call *ast.CallExpr) {
+	if isProtectName(call.Fun) {
+		for _, arg := range call.Args {
+			a.markWrapped(arg)
+		}
+		return
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	if sel.Sel.Name == "Use" {
+		for _, arg := range call.Args {
+			if a.refersToProtect(arg, 0) {
+				a.mark(sel.X)
+			}
+		}
+		return
+	}
+	if !a.protectedRouter(sel.X) {
+		return
+	}
+	for _, arg := range call.Args {
+		if lit, ok := arg.(*ast.FuncLit); ok && len(lit.Type.Params.List) > 0 && len(lit.Type.Params.List[0].Names) > 0 {
+			a.mark(lit.Type.Params.List[0].Names[0])
+		}
+	}
+}
+
+// markWrapped marks e, passed to Protect, as protected: a router variable,
+// nil (http.DefaultServeMux), the result of a function, or the arguments of
+// a wrapping middleware call.
+func (a *analyzer) markWrapped(e ast.Expr) {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		if e.Name == "nil" {
+			a.marked["http.DefaultServeMux"] = true
+			return
+		}
+		a.mark(e)
+	case *ast.CallExpr:
+		if name := funcName(e.Fun); name != "" && !a.returnsProtected[name] {
+			a.returnsProtected[name] = true
+			a.marked[returnKey(name)] = true // forces another pass
+		}
+		for _, arg := range e.Args {
+			a.markWrapped(arg)
+		}
+	default:
+		a.mark(e)
+	}
+}
+
+// mark records e as a protected router.
+func (a *analyzer) mark(e ast.Expr) {
+	if k := a.key(e); k != nil {
+		a.marked[k] = true
+	}
+}
+
+// derivedFromProtected reports whether e yields a router derived from a
+// protected one (an alias, or a call such as Group on a protected router).
+func (a *analyzer) derivedFromProtected(e ast.Expr) bool {
+	e = ast.Unparen(e)
+	if call, ok := e.(*ast.CallExpr); ok {
+		if name := funcName(call.Fun); name != "" && a.protecting[name] {
+			return false // a middleware, not a router
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		return ok && a.protectedRouter(sel.X)
+	}
+	k := a.key(e)
+	return k != nil && a.marked[k]
+}
+
+// protectedRouter reports whether the router expression e is protected:
+// marked, derived from a protected router, or a chain such as
+// r.With(p.Protect).
+func (a *analyzer) protectedRouter(e ast.Expr) bool {
+	e = ast.Unparen(e)
+	if id, ok := e.(*ast.Ident); ok && id.Name == "http" && id.Obj == nil {
+		return a.marked["http.DefaultServeMux"]
+	}
+	if k := a.key(e); k != nil && a.marked[k] {
+		return true
+	}
+	call, ok := e.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	for _, arg := range call.Args {
+		if a.refersToProtect(arg, 0) {
+			return true
+		}
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && a.protectedRouter(sel.X)
+}
+
+// covered reports whether the registration call is protected through its
+// router or one of its arguments (e.g., p.Protect(h) or a gin middleware).
+func (a *analyzer) covered(call *ast.CallExpr) bool {
+	sel := call.Fun.(*ast.SelectorExpr)
+	if a.protectedRouter(sel.X) {
+		return true
+	}
+	for _, arg := range call.Args {
+		if a.refersToProtect(arg, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// refersToProtect reports whether e mentions Protect, calls a protecting
+// function, or uses a variable assigned from such an expression.
+func (a *analyzer) refersToProtect(n ast.Node, depth int) bool {
+	found := false
+	ast.Inspect(n, func(m ast.Node) bool {
+		if found {
+			return false
+		}
+		switch m := m.(type) {
+		case *ast.Ident:
+			if isProtectName(m) || a.protecting[m.Name] {
+				found = true
+			} else if depth < 5 {
+				if v := assignedValue(m); v != nil {
+					found = a.refersToProtect(v, depth+1)
+				}
+			}
+		case *ast.SelectorExpr:
+			if isProtectName(m) || a.protecting[m.Sel.Name] {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// registration reports whether call registers a route for an unsafe method
+// (or, with -strict, a method-less ServeMux pattern).
+//
+// Recognized forms: r.Post/Put/Patch/Delete (chi), r.POST/PUT/PATCH/DELETE
+// (gin, echo), r.Method("POST", path, h) and r.Handle("POST", path, h), and
+// ServeMux Handle/HandleFunc with a "POST /path" pattern. Paths must be
+// string literals starting with "/", which rules out HTTP client calls
+// such as http.Post(url, ...).
+//
+// Returns:
+// - the method, the path, and whether call is such a registration.
+func (a *analyzer) registration(call *ast.CallExpr) (string, string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || len(call.Args) < 2 {
+		return "", "", false
+	}
+	lit := func(i int) string {
+		if i >= len(call.Args) {
+			return ""
+		}
+		bl, ok := call.Args[i].(*ast.BasicLit)
+		if !ok || bl.Kind != token.STRING {
+			return ""
+		}
+		s, _ := strconv.Unquote(bl.Value)
+		return s
+	}
+	name := sel.Sel.Name
+	switch {
+	case unsafeMethods[strings.ToUpper(name)] && (name == strings.ToUpper(name) || name[1:] == strings.ToLower(name[1:])):
+		path := lit(0)
+		return strings.ToUpper(name), path, strings.HasPrefix(path, "/")
+	case name == "Method" || name == "MethodFunc" || name == "Handle" && unsafeMethods[lit(0)]:
+		method, path := strings.ToUpper(lit(0)), lit(1)
+		return method, path, unsafeMethods[method] && strings.HasPrefix(path, "/")
+	case name == "Handle" || name == "HandleFunc":
+		pattern := lit(0)
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			return "", pattern, a.strict && strings.HasPrefix(pattern, "/")
+		}
+		path = strings.TrimSpace(path)
+		return method, path, unsafeMethods[method] && strings.HasPrefix(path, "/")
+	}
+	return "", "", false
+}
+
+// key identifies a router expression: local variables by their declaration,
+// package-level variables and fields by name.
+func (a *analyzer) key(e ast.Expr) any {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		if e.Obj != nil && e.Obj.Kind == ast.Var && !a.packageLevel(e.Obj) {
+			return e.Obj
+		}
+		return e.Name
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			return x.Name + "." + e.Sel.Name
+		}
+	case *ast.StarExpr:
+		return a.key(e.X)
+	case *ast.UnaryExpr:
+		return a.key(e.X)
+	}
+	return nil
+}
+
+// packageLevel reports whether obj is declared at package level.
+func (a *analyzer) packageLevel(obj *ast.Object) bool {
+	for _, f := range a.files {
+		if f.Scope != nil && f.Scope.Objects[obj.Name] == obj {
+			return true
+		}
+	}
+	return false
+}
+
+// assignedValue returns the expression a local variable was declared with,
+// or nil.
+func assignedValue(id *ast.Ident) ast.Expr {
+	if id.Obj == nil || id.Obj.Kind != ast.Var {
+		return nil
+	}
+	switch d := id.Obj.Decl.(type) {
+	case *ast.AssignStmt:
+		for i, lhs := range d.Lhs {
+			if l, ok := lhs.(*ast.Ident); ok && l.Obj == id.Obj && i < len(d.Rhs) {
+				return d.Rhs[i]
+			}
+		}
+	case *ast.ValueSpec:
+		for i, n := range d.Names {
+			if n.Obj == id.Obj && i < len(d.Values) {
+				return d.Values[i]
+			}
+		}
+	}
+	return nil
+}
+
+// protectNames are the Protector methods that wrap handlers.
+var protectNames = map[string]bool{"Protect": true, "ProtectSSE": true, "ProtectStreaming": true}
+
+// isProtectName reports whether e names one of protectNames.
+func isProtectName(e ast.Expr) bool {
+	return protectNames[funcName(e)]
+}
+
+// funcName returns the name of a called function or method, or "".
+func funcName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	}
+	return ""
+}
+
+// returnKey is the marker recorded when a function's result is protected.
+type returnKey string
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+// vetSource analyzes src as a one-file package and returns the flagged paths.
+func vetSource(t *testing.T, src string, strict bool) []string {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "routes.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var paths []string
+	for _, fd := range analyze(fset, []*ast.File{f}, strict) {
+		paths = append(paths, fd.Method+" "+fd.Path)
+	}
+	return paths
+}
+
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		name   string
+		src    string
+		strict bool
+		want   []string
+	}{
+		{"unprotected chi", `package app
+func routes() {
+	r := chi.NewRouter()
+	r.Get("/", home)
+	r.Post("/transfer", transfer)
+}`, false, []string{"POST /transfer"}},
+		{"chi with Use and groups", `package app
+func routes(p *csrf.Protector) {
+	r := chi.NewRouter()
+	r.Use(p.Protect)
+	r.Post("/transfer", transfer)
+	r.Route("/admin", func(r chi.Router) { r.Delete("/user", del) })
+	api := chi.NewRouter()
+	api.With(p.Protect).Put("/item", put)
+	api.Patch("/item", patch)
+}`, false, []string{"PATCH /item"}},
+		{"ServeMux wrapped by a returning function", `package app
+func mux() *http.ServeMux {
+	m := http.NewServeMux()
+	m.HandleFunc("POST /submit", submit)
+	return m
+}
+func main() {
+	p := csrf.New(csrf.Config{})
+	http.ListenAndServe(":8080", p.Protect(logging(mux())))
+	other := http.NewServeMux()
+	other.HandleFunc("DELETE /x", del)
+	other.HandleFunc("/legacy", legacy)
+	http.Post("https://example.com", "text/plain", nil)
+}`, true, []string{"DELETE /x", " /legacy"}},
+		{"gin middleware adapter", `package app
+func mw(p *csrf.Protector) gin.HandlerFunc {
+	return func(c *gin.Context) { p.Protect(next).ServeHTTP(c.Writer, c.Request) }
+}
+func routes(r *gin.Engine, p *csrf.Protector) {
+	m := mw(p)
+	app := r.Group("/app")
+	app.Use(m)
+	app.POST("/transfer", transfer)
+	r.POST("/inline", m, inline)
+	r.POST("/open", open)
+	//csrf:ignore protected by the gateway
+	r.DELETE("/gateway", gw)
+}`, false, []string{"POST /open"}},
+	}
+	for _, tc := range cases {
+		got := vetSource(t, tc.src, tc.strict)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+			}
+		}
+	}
+}
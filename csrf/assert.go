@@ -0,0 +1,72 @@
+package csrf
+
+import (
+	"context"
+	"net/http"
+)
+
+// Fail-closed assertions: built with -tags csrfassert, Protect (or Prepare)
+// panics when an unsafe request reaches the protected handler without a
+// verdict recorded by the checks (validated, or deliberately skipped or
+// reported). Run the test suite with the tag to catch refactors that open
+// a bypass; production builds compile the checks away.
+
+const verdictKey ctxKey = "csrf_verdict_ctx"
+
+// verdictRecord holds the verdict of one Protector for a request.
+type verdictRecord struct {
+	p       *Protector
+	verdict string
+}
+
+// trackVerdict returns r with a verdict record for p in its context, when
+// assertions are enabled and it has none yet.
+//
+// Params:
+// - r: incoming request.
+//
+// Returns:
+// - the request to pass on.
+func (p *Protector) trackVerdict(r *http.Request) *http.Request {
+	if !failClosedAssertions {
+		return r
+	}
+	if rec, ok := r.Context().Value(verdictKey).(*verdictRecord); ok && rec.p == p {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), verdictKey, &verdictRecord{p: p}))
+}
+
+// recordVerdict records the verdict (AssertionSafe, AssertionValidated,
+// ...) of p for r.
+func (p *Protector) recordVerdict(r *http.Request, verdict string) {
+	if !failClosedAssertions {
+		return
+	}
+	if rec, ok := r.Context().Value(verdictKey).(*verdictRecord); ok && rec.p == p {
+		rec.verdict = verdict
+	}
+}
+
+// assertVerdict wraps next so that an unsafe request reaching it without a
+// verdict of p panics. Without assertions it returns next.
+//
+// Params:
+// - next: the protected handler.
+//
+// Returns:
+// - the handler to hand requests to.
+func (p *Protector) assertVerdict(next http.Handler) http.Handler {
+	if !failClosedAssertions {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unsafeMethods[r.Method] {
+			rec, ok := r.Context().Value(verdictKey).(*verdictRecord)
+			if !ok || rec.p != p || rec.verdict == "" {
+				panic("csrf: unsafe " + r.Method + " " + r.URL.Path + " reached the handler without validation")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
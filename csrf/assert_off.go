@@ -0,0 +1,7 @@
+//go:build !csrfassert
+
+package csrf
+
+// failClosedAssertions enables the checks of assert.go; see the csrfassert
+// build tag.
+const failClosedAssertions = false
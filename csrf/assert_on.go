@@ -0,0 +1,6 @@
+//go:build csrfassert
+
+package csrf
+
+// failClosedAssertions enables the checks of assert.go.
+const failClosedAssertions = true
@@ -0,0 +1,45 @@
+//go:build csrfassert
+
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Run with -tags csrfassert: an unsafe request reaching the handler without
+// a verdict panics, validated ones do not.
+func TestFailClosedAssertions(t *testing.T) {
+	p := New(Config{})
+	ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	tok, _ := newToken(32)
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tok})
+	req.Header.Set("X-CSRF-Token", tok)
+	rec := httptest.NewRecorder()
+	p.Protect(ok).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("valid POST: got %d", rec.Code)
+	}
+
+	// a verdict of another Protector does not count
+	other := New(Config{})
+	bypass := p.assertVerdict(ok)
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/", nil),
+		other.trackVerdict(httptest.NewRequest(http.MethodDelete, "/", nil)),
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s without verdict: no panic", r.Method)
+				}
+			}()
+			other.recordVerdict(r, AssertionValidated)
+			bypass.ServeHTTP(httptest.NewRecorder(), r)
+		}()
+	}
+	bypass.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,39 @@
+package csrf
+
+import "net/http"
+
+// Attestor verifies native mobile app attestations (Apple App Attest,
+// Google Play Integrity, ...) carried by a request, e.g. in a header the
+// app adds. Verification is up to the application: the package only asks
+// for the verdict. Implementations must be safe for concurrent use and
+// should bind the attestation to the request (method, path, body hash or a
+// server nonce) so a captured one cannot be replayed.
+type Attestor interface {
+	// Attested reports whether r carries a valid attestation of a genuine
+	// app. Verification failures (including backend errors) report false.
+	Attested(r *http.Request) bool
+}
+
+// attested reports whether the unsafe request r comes from an attested
+// native app and may skip the token check. Requests with browser fetch
+// metadata (Sec-Fetch-Site or Sec-Fetch-Mode) are never handed to the
+// Attestor: a browser is not a native app, whatever it carries, so
+// cross-site requests from browsers stay subject to the token check.
+//
+// Params:
+// - r: incoming unsafe request.
+//
+// Returns:
+// - true if Attestor is set, r does not come from a browser and the
+// Attestor accepts it.
+func (p *Protector) attested(r *http.Request) bool {
+	a := p.cfg.Attestor
+	if a == nil || r.Header.Get("Sec-Fetch-Site") != "" || r.Header.Get("Sec-Fetch-Mode") != "" {
+		return false
+	}
+	if !a.Attested(r) {
+		return false
+	}
+	p.stats.attested.Add(1)
+	return true
+}
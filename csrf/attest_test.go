@@ -0,0 +1,43 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// headerAttestor accepts requests carrying a fixed attestation header.
+type headerAttestor struct{}
+
+func (headerAttestor) Attested(r *http.Request) bool {
+	return r.Header.Get("X-App-Attestation") == "valid"
+}
+
+// Attested app requests skip the token check; browsers never do.
+func TestAttestor(t *testing.T) {
+	p := New(Config{Attestor: headerAttestor{}})
+	app := p.Protect(appHandler(p))
+
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"attested app", map[string]string{"X-App-Attestation": "valid"}, http.StatusOK},
+		{"bad attestation", map[string]string{"X-App-Attestation": "forged"}, http.StatusForbidden},
+		{"browser", map[string]string{"X-App-Attestation": "valid", "Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+	if n := p.stats.attested.Load(); n != 1 {
+		t.Fatalf("attested counter: got %d", n)
+	}
+}
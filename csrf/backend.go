@@ -0,0 +1,97 @@
+package csrf
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// BackendFailurePolicy decides what happens to a request when a store the
+// middleware depends on (Blocklist, FailureLimiter) fails or times out.
+type BackendFailurePolicy int
+
+const (
+	// FailClosed answers the request with 500 (the default): no request
+	// skips a check because a store is down.
+	FailClosed BackendFailurePolicy = iota
+
+	// FailOpen skips the failed check and carries on with the stateless
+	// validation, favoring availability. Every skipped check is logged.
+	FailOpen
+
+	// FailOpenIdempotent fails open only for idempotent-looking requests
+	// (PUT, DELETE, or any request carrying an Idempotency-Key header),
+	// which can be retried safely, and closed for the rest.
+	FailOpenIdempotent
+)
+
+// String returns the policy name used in DebugHandler.
+func (b BackendFailurePolicy) String() string {
+	switch b {
+	case FailClosed:
+		return "fail-closed"
+	case FailOpen:
+		return "fail-open"
+	case FailOpenIdempotent:
+		return "fail-open-idempotent"
+	default:
+		return "unknown"
+	}
+}
+
+// defaultStoreTimeout is the default StoreTimeout when a store is configured.
+const defaultStoreTimeout = time.Second
+
+// storeContext returns the context for store calls made on behalf of r: the
+// request context, bounded by StoreTimeout when set.
+//
+// Params:
+// - r: current request.
+//
+// Returns:
+// - the context and its cancel function.
+func (p *Protector) storeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if p.cfg.StoreTimeout <= 0 {
+		return r.Context(), func() {}
+	}
+	return context.WithTimeout(r.Context(), p.cfg.StoreTimeout)
+}
+
+// backendFailed applies BackendFailurePolicy and DegradationFloor to a store
+// error. When the request may not proceed it writes the 500 response itself;
+// when it may, the skipped check is logged.
+//
+// Params:
+// - w: response writer for the error response.
+// - r: current request.
+// - what: the failed component, used in the response (e.g. "blocklist").
+// - err: the store error.
+//
+// Returns:
+// - true if the request may proceed without the failed check.
+func (p *Protector) backendFailed(w http.ResponseWriter, r *http.Request, what string, err error) bool {
+	open := false
+	switch p.cfg.BackendFailurePolicy {
+	case FailOpen:
+		open = true
+	case FailOpenIdempotent:
+		open = idempotent(r)
+	}
+	if !open {
+		p.fail(w, r, http.StatusInternalServerError, unavailable(what))
+		return false
+	}
+	if !p.degrade(w, r, what) {
+		return false
+	}
+	p.cfg.Logger.Warn("csrf: store unavailable, check skipped",
+		"store", what, "error", err, "method", r.Method, "path", r.URL.Path)
+	return true
+}
+
+// idempotent reports whether r looks safe to retry: an idempotent method or
+// an Idempotency-Key header.
+func idempotent(r *http.Request) bool {
+	return r.Method == http.MethodPut || r.Method == http.MethodDelete ||
+		r.Header.Get("Idempotency-Key") != ""
+}
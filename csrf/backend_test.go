@@ -0,0 +1,130 @@
+package csrf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hangingLimiter blocks until the context is done.
+type hangingLimiter struct{}
+
+func (hangingLimiter) Allow(ctx context.Context, _ string) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func (hangingLimiter) Fail(ctx context.Context, _ string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// A hanging store is cut off by StoreTimeout; the policy decides the outcome
+// and skipped checks are logged.
+func TestStoreTimeoutAndPolicy(t *testing.T) {
+	token, _ := newToken(16)
+	var logs bytes.Buffer
+	send := func(policy BackendFailurePolicy, method string) int {
+		p := New(Config{
+			TokenBytes:           16,
+			FailureLimiter:       hangingLimiter{},
+			StoreTimeout:         10 * time.Millisecond,
+			BackendFailurePolicy: policy,
+			Logger:               slog.New(slog.NewTextHandler(&logs, nil)),
+		})
+		req := httptest.NewRequest(method, "/submit", nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+		req.Header.Set("X-CSRF-Token", token)
+		rec := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			appHandler(p).ServeHTTP(rec, req)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("request hung on the store")
+		}
+		return rec.Code
+	}
+
+	if code := send(FailClosed, http.MethodPost); code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when failing closed, got %d", code)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("expected no warning when failing closed, got %s", logs.String())
+	}
+	if code := send(FailOpen, http.MethodPost); code != http.StatusOK {
+		t.Fatalf("expected the valid request to pass when failing open, got %d", code)
+	}
+	if !strings.Contains(logs.String(), "store=limiter") {
+		t.Fatalf("expected the skipped check to be logged, got %s", logs.String())
+	}
+	if code := send(FailOpenIdempotent, http.MethodPost); code != http.StatusInternalServerError {
+		t.Fatalf("expected POST to fail closed, got %d", code)
+	}
+	if code := send(FailOpenIdempotent, http.MethodPut); code != http.StatusOK {
+		t.Fatalf("expected PUT to fail open, got %d", code)
+	}
+}
+
+// failingLimiter always errors.
+type failingLimiter struct{ calls int }
+
+func (l *failingLimiter) Allow(context.Context, string) (bool, error) {
+	l.calls++
+	return false, errors.New("redis down")
+}
+
+func (l *failingLimiter) Fail(context.Context, string) error {
+	l.calls++
+	return errors.New("redis down")
+}
+
+// Once open, the breaker skips the store and requests get stateless
+// validation; after the cooldown the store is tried again.
+func TestStoreBreaker(t *testing.T) {
+	now := time.Now()
+	var states []bool
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	b.OnStateChange = func(open bool) { states = append(states, open) }
+	l := &failingLimiter{}
+	p := New(Config{TokenBytes: 16, FailureLimiter: l, StoreBreaker: b, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+
+	token, _ := newToken(16)
+	send := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+		req.Header.Set("X-CSRF-Token", token)
+		rec := httptest.NewRecorder()
+		appHandler(p).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	send()
+	send()
+	if !b.Open() || len(states) != 1 || !states[0] {
+		t.Fatalf("expected the breaker to open after 2 failures (states %v)", states)
+	}
+	calls := l.calls
+	if code := send(); code != http.StatusOK || l.calls != calls {
+		t.Fatalf("expected stateless validation without store calls, got %d (%d calls)", code, l.calls-calls)
+	}
+	if p.stats.breakerSkipped.Load() != 1 {
+		t.Fatal("expected the skipped check to be counted")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if code := send(); code != http.StatusInternalServerError || l.calls == calls {
+		t.Fatalf("expected the store to be retried after the cooldown, got %d", code)
+	}
+}
@@ -0,0 +1,195 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Verdicts forwarded in Config.ForwardAssertion.
+const (
+	// AssertionSafe marks a safe-method request.
+	AssertionSafe = "safe"
+	// AssertionValidated marks an unsafe request that passed every check.
+	AssertionValidated = "validated"
+	// AssertionSkipped marks an unsafe request let through by
+	// TrustedNetworks or Exempt.
+	AssertionSkipped = "skipped"
+	// AssertionReported marks an unsafe request that failed in report-only
+	// mode.
+	AssertionReported = "reported"
+)
+
+// defaultAssertionMaxAge is the accepted age (and clock skew) of a signed
+// assertion in VerifyForwardedAssertion.
+const defaultAssertionMaxAge = time.Minute
+
+var (
+	errUntrustedCaller = errors.New("untrusted caller")
+	errNotValidated    = errors.New("CSRF not validated upstream")
+)
+
+// forwardAssertion records the verdict for r in the ForwardAssertion
+// request header, for services behind a backend-for-frontend, and for the
+// fail-closed assertions (see assert.go).
+//
+// Params:
+// - r: request about to be passed to the next handler.
+// - verdict: one of the Assertion constants.
+func (p *Protector) forwardAssertion(r *http.Request, verdict string) {
+	p.recordVerdict(r, verdict)
+	if p.cfg.ForwardAssertion == "" {
+		return
+	}
+	if len(p.cfg.AssertionKey) > 0 {
+		verdict = signAssertion(p.cfg.AssertionKey, verdict, r, time.Now())
+	}
+	r.Header.Set(p.cfg.ForwardAssertion, verdict)
+}
+
+// signAssertion returns "<verdict>.<unix>.<mac>", where the MAC binds the
+// verdict and time to the request method and path.
+func signAssertion(key []byte, verdict string, r *http.Request, now time.Time) string {
+	body := verdict + "." + strconv.FormatInt(now.Unix(), 10)
+	mac := tokenMAC(key, body+"."+r.Method+" "+r.URL.Path)
+	return body + "." + base64.RawURLEncoding.EncodeToString(mac)
+}
+
+// verifyAssertion checks a signAssertion value for r and returns its verdict.
+func verifyAssertion(key []byte, value string, r *http.Request, maxAge time.Duration) (string, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	sec, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	if d := time.Since(time.Unix(sec, 0)); d > maxAge || d < -maxAge {
+		return "", false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(parts[2])
+	want := tokenMAC(key, parts[0]+"."+parts[1]+"."+r.Method+" "+r.URL.Path)
+	if err != nil || !hmac.Equal(got, want) {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// VerifyForwardedAssertion is the upstream half of a BFF setup that signs
+// its verdicts (Config.ForwardAssertion with Config.AssertionKey): internal
+// services need no cookie logic, yet refuse unsafe requests that did not go
+// through the edge. Unsafe requests pass only with a valid "validated" or
+// "skipped" assertion signed for their method and path within maxAge;
+// others get 403. The path must reach the service unchanged, so strip
+// prefixes after this middleware.
+//
+// Params:
+// - next: the internal service's handler.
+// - header: the edge's Config.ForwardAssertion.
+// - key: the edge's Config.AssertionKey.
+// - maxAge: accepted assertion age and clock skew. Default: 1 minute.
+//
+// Returns:
+// - the verifying handler. It panics when header or key is empty.
+func VerifyForwardedAssertion(next http.Handler, header string, key []byte, maxAge time.Duration) http.Handler {
+	if header == "" || len(key) == 0 {
+		panic("csrf: VerifyForwardedAssertion needs a header and a key")
+	}
+	if maxAge <= 0 {
+		maxAge = defaultAssertionMaxAge
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unsafeMethods[r.Method] {
+			verdict, ok := verifyAssertion(key, r.Header.Get(header), r, maxAge)
+			if !ok || verdict != AssertionValidated && verdict != AssertionSkipped {
+				http.Error(w, errNotValidated.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BFFVerifier is the upstream half of a backend-for-frontend (BFF) setup:
+// the BFF runs Protect with Config.ForwardAssertion set and proxies to
+// internal services, which wrap their handlers with Middleware. Internal
+// services then need no cookie logic, but refuse unsafe requests that did
+// not go through the BFF's CSRF check.
+//
+// The caller is identified either by its mTLS client certificate (the
+// server must verify client certificates, e.g. tls.RequireAndVerifyClientCert)
+// or by a shared secret the BFF's proxy adds to every request.
+type BFFVerifier struct {
+	// AssertionHeader is the BFF's Config.ForwardAssertion.
+	AssertionHeader string
+
+	// ClientNames lists the accepted client certificate names (Subject
+	// common name or DNS SAN) of the BFF.
+	ClientNames []string
+
+	// SecretHeader and Secret, when set, accept callers sending Secret in
+	// SecretHeader. The header is removed before the handler runs.
+	SecretHeader string
+	Secret       string
+}
+
+// Middleware wraps next so it only serves requests from the BFF, and unsafe
+// requests only when the BFF validated (or deliberately skipped) CSRF.
+// Other requests get 403.
+//
+// Params:
+// - next: the internal service's handler.
+//
+// Returns:
+// - the verifying handler. It panics when AssertionHeader or both caller
+// identities are unset, since it would refuse every request.
+func (v BFFVerifier) Middleware(next http.Handler) http.Handler {
+	if v.AssertionHeader == "" || len(v.ClientNames) == 0 && (v.SecretHeader == "" || v.Secret == "") {
+		panic("csrf: BFFVerifier needs AssertionHeader and ClientNames or SecretHeader/Secret")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !v.trustedCaller(r) {
+			http.Error(w, errUntrustedCaller.Error(), http.StatusForbidden)
+			return
+		}
+		if v.SecretHeader != "" {
+			r.Header.Del(v.SecretHeader)
+		}
+		if unsafeMethods[r.Method] {
+			switch r.Header.Get(v.AssertionHeader) {
+			case AssertionValidated, AssertionSkipped:
+			default:
+				http.Error(w, errNotValidated.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trustedCaller reports whether r comes from the BFF.
+func (v BFFVerifier) trustedCaller(r *http.Request) bool {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 && len(v.ClientNames) > 0 {
+		leaf := r.TLS.PeerCertificates[0]
+		if slices.Contains(v.ClientNames, leaf.Subject.CommonName) {
+			return true
+		}
+		for _, name := range leaf.DNSNames {
+			if slices.Contains(v.ClientNames, name) {
+				return true
+			}
+		}
+	}
+	if v.SecretHeader == "" || v.Secret == "" {
+		return false
+	}
+	got := r.Header.Get(v.SecretHeader)
+	return subtle.ConstantTimeCompare([]byte(got), []byte(v.Secret)) == 1
+}
@@ -0,0 +1,144 @@
+package csrf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The BFF forwards its verdict, stripping forged copies, and the upstream
+// verifier only accepts validated unsafe requests from the BFF.
+func TestBFFAssertion(t *testing.T) {
+	const hdr = "X-CSRF-Assertion"
+	var upstream http.Handler
+	bff := New(Config{TokenBytes: 16, ForwardAssertion: hdr}).Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("X-BFF-Secret", "s3cret")
+		upstream.ServeHTTP(w, r)
+	}))
+	v := BFFVerifier{AssertionHeader: hdr, SecretHeader: "X-BFF-Secret", Secret: "s3cret"}
+	upstream = v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-BFF-Secret") != "" {
+			t.Error("secret header reached the handler")
+		}
+		_, _ = w.Write([]byte(r.Header.Get(hdr)))
+	}))
+
+	rec := httptest.NewRecorder()
+	bff.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Body.String() != AssertionSafe {
+		t.Fatalf("GET: got %q", rec.Body.String())
+	}
+	token := rec.Result().Cookies()[0].Value
+
+	req := httptest.NewRequest(http.MethodPost, "/save", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+	req.Header.Set("X-CSRF-Token", token)
+	rec = httptest.NewRecorder()
+	bff.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != AssertionValidated {
+		t.Fatalf("valid POST: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// a forged assertion from the client is discarded before the check
+	req = httptest.NewRequest(http.MethodPost, "/save", nil)
+	req.Header.Set(hdr, AssertionValidated)
+	rec = httptest.NewRecorder()
+	bff.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("forged POST: got %d", rec.Code)
+	}
+
+	// direct calls to the upstream are refused
+	for _, secret := range []string{"", "wrong"} {
+		req = httptest.NewRequest(http.MethodPost, "/save", nil)
+		req.Header.Set(hdr, AssertionValidated)
+		req.Header.Set("X-BFF-Secret", secret)
+		rec = httptest.NewRecorder()
+		upstream.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "untrusted caller") {
+			t.Fatalf("secret %q: got %d %q", secret, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestBFFVerifierClientCert(t *testing.T) {
+	v := BFFVerifier{AssertionHeader: "X-CSRF-Assertion", ClientNames: []string{"bff.internal"}}
+	h := v.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	cases := []struct {
+		cert      *x509.Certificate
+		assertion string
+		code      int
+	}{
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "bff.internal"}}, AssertionValidated, http.StatusOK},
+		{&x509.Certificate{DNSNames: []string{"bff.internal"}}, AssertionSkipped, http.StatusOK},
+		{&x509.Certificate{DNSNames: []string{"bff.internal"}}, AssertionReported, http.StatusForbidden},
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "other"}}, AssertionValidated, http.StatusForbidden},
+		{nil, AssertionValidated, http.StatusForbidden},
+	}
+	for i, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-CSRF-Assertion", tc.assertion)
+		if tc.cert != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tc.cert}}
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("case %d: got %d, want %d", i, rec.Code, tc.code)
+		}
+	}
+}
+
+// Signed assertions are bound to the method, path and time of the request.
+func TestVerifyForwardedAssertion(t *testing.T) {
+	const hdr = "X-CSRF-Assertion"
+	key := []byte("0123456789abcdef0123456789abcdef")
+	var upstream http.Handler
+	edge := New(Config{TokenBytes: 16, ForwardAssertion: hdr, AssertionKey: key, Exempt: func(r *http.Request) bool {
+		return r.URL.Path == "/hook"
+	}}).Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream.ServeHTTP(w, r)
+	}))
+	upstream = VerifyForwardedAssertion(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), hdr, key, time.Minute)
+
+	rec := httptest.NewRecorder()
+	edge.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/hook", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("exempt POST through edge: got %d", rec.Code)
+	}
+
+	now := time.Now()
+	post := httptest.NewRequest(http.MethodPost, "/save", nil)
+	cases := []struct {
+		name, value string
+		code        int
+	}{
+		{"validated", signAssertion(key, AssertionValidated, post, now), http.StatusOK},
+		{"reported", signAssertion(key, AssertionReported, post, now), http.StatusForbidden},
+		{"unsigned", AssertionValidated, http.StatusForbidden},
+		{"expired", signAssertion(key, AssertionValidated, post, now.Add(-2*time.Minute)), http.StatusForbidden},
+		{"other path", signAssertion(key, AssertionValidated, httptest.NewRequest(http.MethodPost, "/other", nil), now), http.StatusForbidden},
+		{"other key", signAssertion([]byte("k"), AssertionValidated, post, now), http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/save", nil)
+		req.Header.Set(hdr, tc.value)
+		rec := httptest.NewRecorder()
+		upstream.ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("%s: got %d, want %d", tc.name, rec.Code, tc.code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	upstream.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/page", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: got %d", rec.Code)
+	}
+}
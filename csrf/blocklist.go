@@ -0,0 +1,94 @@
+package csrf
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BlockStore keeps temporarily blocked clients. Implementations must be safe
+// for concurrent use and may be backed by shared storage so that blocks apply
+// across instances; the key is the client IP resolved by the middleware.
+type BlockStore interface {
+	// Block marks key as blocked until the given time.
+	Block(ctx context.Context, key string, until time.Time) error
+
+	// Blocked reports whether key is currently blocked.
+	Blocked(ctx context.Context, key string) (bool, error)
+}
+
+// MemoryBlockStore is an in-memory BlockStore. Expired entries are dropped
+// on lookup, when listing and by a sweep every 1024 blocks, so rotating
+// client IPs cannot grow it without bound.
+type MemoryBlockStore struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	ops     int
+
+	now func() time.Time
+}
+
+// NewMemoryBlockStore returns an empty MemoryBlockStore.
+func NewMemoryBlockStore() *MemoryBlockStore {
+	return &MemoryBlockStore{entries: make(map[string]time.Time), now: time.Now}
+}
+
+// Block marks key as blocked until the given time. An existing longer block
+// is kept.
+func (s *MemoryBlockStore) Block(_ context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.entries[key]; !ok || until.After(cur) {
+		s.entries[key] = until
+	}
+	s.ops++
+	if s.ops >= sweepEvery {
+		s.ops = 0
+		now := s.now()
+		for k, until := range s.entries {
+			if !now.Before(until) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Blocked reports whether key is blocked right now.
+func (s *MemoryBlockStore) Blocked(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.entries[key]
+	if !ok {
+		return false, nil
+	}
+	if !s.now().Before(until) {
+		delete(s.entries, key)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Unblock removes key from the store, e.g. from an ops endpoint.
+func (s *MemoryBlockStore) Unblock(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// Entries returns a snapshot of the currently blocked keys and their expiry,
+// suitable for surfacing in ops tooling.
+func (s *MemoryBlockStore) Entries() map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	out := make(map[string]time.Time, len(s.entries))
+	for k, until := range s.entries {
+		if !now.Before(until) {
+			delete(s.entries, k)
+			continue
+		}
+		out[k] = until
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package csrf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Entries expire after their TTL.
+func TestMemoryBlockStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	s := NewMemoryBlockStore()
+	s.now = func() time.Time { return now }
+
+	s.Block(ctx, "198.51.100.9", now.Add(time.Minute))
+	if blocked, _ := s.Blocked(ctx, "198.51.100.9"); !blocked {
+		t.Fatalf("expected key to be blocked")
+	}
+	if n := len(s.Entries()); n != 1 {
+		t.Fatalf("expected 1 entry, got %d", n)
+	}
+
+	now = now.Add(time.Minute)
+	if blocked, _ := s.Blocked(ctx, "198.51.100.9"); blocked {
+		t.Fatalf("expected block to expire")
+	}
+}
+
+// Expired blocks of clients never seen again are swept as new ones arrive.
+func TestMemoryBlockStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	s := NewMemoryBlockStore()
+	s.now = func() time.Time { return now }
+	for i := range sweepEvery {
+		s.Block(ctx, fmt.Sprintf("10.0.%d.%d", i/256, i%256), now.Add(time.Minute))
+	}
+	now = now.Add(2 * time.Minute)
+	for i := range sweepEvery {
+		s.Block(ctx, fmt.Sprintf("10.1.%d.%d", i/256, i%256), now.Add(time.Minute))
+	}
+	if n := len(s.entries); n != sweepEvery {
+		t.Fatalf("expected expired blocks swept, %d entries left", n)
+	}
+}
+
+// A client denied by the limiter is blocked and OnBlock is notified.
+func TestProtectBlocklist(t *testing.T) {
+	var blockedKey string
+	store := NewMemoryBlockStore()
+	cfg := Config{
+		CookieName:     "csrf_token_test",
+		TokenBytes:     16,
+		FailureLimiter: NewMemoryLimiter(1, time.Hour),
+		Blocklist:      store,
+		OnBlock:        func(key string, _ time.Time) { blockedKey = key },
+	}
+	app := appHandler(New(cfg))
+
+	post := func() int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		req.Header.Set("X-CSRF-Token", "wrong")
+		app.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post(); code != http.StatusForbidden {
+		t.Fatalf("expected 403 on first failure, got %d", code)
+	}
+	if code := post(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 when limited, got %d", code)
+	}
+	if blockedKey != "203.0.113.7" {
+		t.Fatalf("expected OnBlock for client, got %q", blockedKey)
+	}
+	if code := post(); code != http.StatusForbidden {
+		t.Fatalf("expected 403 while blocked, got %d", code)
+	}
+	if _, ok := store.Entries()["203.0.113.7"]; !ok {
+		t.Fatalf("expected client in blocklist entries")
+	}
+}
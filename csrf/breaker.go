@@ -0,0 +1,85 @@
+package csrf
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreaker guards the external stores (Blocklist, FailureLimiter).
+// After Threshold consecutive store failures it opens for Cooldown: store
+// calls are skipped and requests get the stateless double-submit validation
+// only, instead of paying a timeout on every unsafe request. After the
+// cooldown the next call is tried; a success closes the breaker, a failure
+// opens it again.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	// OnStateChange, if set, is called when the breaker opens (true) or
+	// closes (false). It must not block.
+	OnStateChange func(open bool)
+
+	mu        sync.Mutex
+	failures  int
+	open      bool
+	openUntil time.Time
+
+	now func() time.Time
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker.
+//
+// Params:
+// - threshold: consecutive failures that open the breaker (min 1).
+// - cooldown: how long the breaker stays open (default 30s when <= 0).
+//
+// Returns:
+// - *CircuitBreaker ready to be set as Config.StoreBreaker.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Open reports whether store calls are currently being skipped.
+func (b *CircuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open && b.now().Before(b.openUntil)
+}
+
+// allow reports whether a store call may be attempted. A nil breaker always
+// allows.
+func (b *CircuitBreaker) allow() bool {
+	return b == nil || !b.Open()
+}
+
+// record updates the breaker with the outcome of a store call.
+func (b *CircuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	changed := false
+	if err == nil {
+		b.failures = 0
+		changed = b.open
+		b.open = false
+	} else {
+		b.failures++
+		if b.failures >= b.threshold {
+			changed = !b.open
+			b.open = true
+			b.openUntil = b.now().Add(b.cooldown)
+		}
+	}
+	open, hook := b.open, b.OnStateChange
+	b.mu.Unlock()
+	if changed && hook != nil {
+		hook(open)
+	}
+}
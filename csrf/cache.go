@@ -0,0 +1,62 @@
+package csrf
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CacheSafety decides how responses carrying a freshly minted token cookie
+// are marked for shared caches (CDNs, reverse proxies), so one user's token
+// page is never served to others.
+type CacheSafety int
+
+const (
+	// CacheSafetyOff leaves caching headers to the application (the default).
+	CacheSafetyOff CacheSafety = iota
+
+	// CacheSafetyPrivate sets "Cache-Control: private" unless the response
+	// is already private or no-store.
+	CacheSafetyPrivate
+
+	// CacheSafetyVary appends "Cookie" to Vary, keeping the response
+	// cacheable per cookie set.
+	CacheSafetyVary
+)
+
+// String returns the mode name used in DebugHandler.
+func (c CacheSafety) String() string {
+	switch c {
+	case CacheSafetyOff:
+		return "off"
+	case CacheSafetyPrivate:
+		return "private"
+	case CacheSafetyVary:
+		return "vary"
+	default:
+		return "unknown"
+	}
+}
+
+// markUncacheable applies CacheSafety to a response on which a token cookie
+// is being set. Handlers that set caching headers afterwards override it.
+//
+// Params:
+// - h: response headers.
+func (p *Protector) markUncacheable(h http.Header) {
+	switch p.cfg.CacheSafety {
+	case CacheSafetyPrivate:
+		cc := strings.ToLower(h.Get("Cache-Control"))
+		if !strings.Contains(cc, "private") && !strings.Contains(cc, "no-store") {
+			h.Set("Cache-Control", "private")
+		}
+	case CacheSafetyVary:
+		for _, v := range h.Values("Vary") {
+			for _, name := range strings.Split(v, ",") {
+				if n := strings.TrimSpace(name); n == "*" || strings.EqualFold(n, "Cookie") {
+					return
+				}
+			}
+		}
+		h.Add("Vary", "Cookie")
+	}
+}
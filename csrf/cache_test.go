@@ -0,0 +1,45 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Responses carrying a new token cookie are marked for shared caches;
+// responses that reuse the cookie are left alone.
+func TestCacheSafety(t *testing.T) {
+	cases := []struct {
+		mode   CacheSafety
+		header string
+		want   string
+	}{
+		{CacheSafetyOff, "Cache-Control", ""},
+		{CacheSafetyPrivate, "Cache-Control", "private"},
+		{CacheSafetyVary, "Vary", "Cookie"},
+	}
+	for _, tc := range cases {
+		p := New(Config{TokenBytes: 16, CacheSafety: tc.mode})
+		rec := httptest.NewRecorder()
+		appHandler(p).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got := rec.Header().Get(tc.header); got != tc.want {
+			t.Errorf("mode %d: %s = %q, want %q", tc.mode, tc.header, got, tc.want)
+		}
+
+		c := getCookieByName(rec.Result(), "csrf_token")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(c)
+		rec = httptest.NewRecorder()
+		appHandler(p).ServeHTTP(rec, req)
+		if got := rec.Header().Get(tc.header); got != "" {
+			t.Errorf("mode %d: expected no %s without a new cookie, got %q", tc.mode, tc.header, got)
+		}
+	}
+
+	h := http.Header{"Cache-Control": {"no-store"}, "Vary": {"Accept-Encoding, Cookie"}}
+	New(Config{CacheSafety: CacheSafetyPrivate}).markUncacheable(h)
+	New(Config{CacheSafety: CacheSafetyVary}).markUncacheable(h)
+	if h.Get("Cache-Control") != "no-store" || len(h.Values("Vary")) != 1 {
+		t.Fatalf("expected existing headers to be kept, got %v", h)
+	}
+}
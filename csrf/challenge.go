@@ -0,0 +1,99 @@
+package csrf
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults for the challenge hook.
+const (
+	defaultChallengeAfter  = 5
+	defaultChallengeWindow = 10 * time.Minute
+)
+
+// failureCounter counts CSRF failures per client within a fixed window, for
+// the challenge hook.
+type failureCounter struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*failureWindow
+	ops     int
+
+	now func() time.Time
+}
+
+type failureWindow struct {
+	n     int
+	start time.Time
+}
+
+// newFailureCounter returns an empty counter with the given window.
+func newFailureCounter(window time.Duration) *failureCounter {
+	return &failureCounter{window: window, clients: make(map[string]*failureWindow), now: time.Now}
+}
+
+// add records one failure for key and returns the failures in its current
+// window.
+func (c *failureCounter) add(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	fw, ok := c.clients[key]
+	if !ok || now.Sub(fw.start) >= c.window {
+		fw = &failureWindow{start: now}
+		c.clients[key] = fw
+	}
+	fw.n++
+
+	c.ops++
+	if c.ops >= sweepEvery {
+		c.ops = 0
+		for k, w := range c.clients {
+			if now.Sub(w.start) >= c.window {
+				delete(c.clients, k)
+			}
+		}
+	}
+	return fw.n
+}
+
+// reset forgets the failures of key.
+func (c *failureCounter) reset(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.clients, key)
+}
+
+// challenge hands a rejected request to Config.Challenge once its client
+// has failed ChallengeAfter times within ChallengeWindow.
+//
+// Params:
+// - w: response writer of the rejected request.
+// - r: the rejected request.
+//
+// Returns:
+// - true if the challenge wrote the response.
+func (p *Protector) challenge(w http.ResponseWriter, r *http.Request) bool {
+	if p.failures == nil {
+		return false
+	}
+	n := p.failures.add(clientIP(r, p.cfg.TrustedProxies))
+	if n < p.cfg.ChallengeAfter {
+		return false
+	}
+	if !p.cfg.Challenge(w, r, n) {
+		return false
+	}
+	p.stats.challenged.Add(1)
+	return true
+}
+
+// clearFailures forgets the failures of the client sending r after it
+// passed validation.
+func (p *Protector) clearFailures(r *http.Request) {
+	if p.failures != nil {
+		p.failures.reset(clientIP(r, p.cfg.TrustedProxies))
+	}
+}
@@ -0,0 +1,56 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// After ChallengeAfter failures the client is sent to the challenge; a
+// successful request clears its count.
+func TestChallenge(t *testing.T) {
+	var counts []int
+	p := New(Config{
+		TokenBytes:     16,
+		ChallengeAfter: 3,
+		Challenge: func(w http.ResponseWriter, r *http.Request, failures int) bool {
+			counts = append(counts, failures)
+			http.Redirect(w, r, "/captcha", http.StatusSeeOther)
+			return true
+		},
+	})
+	app := appHandler(p)
+	token, _ := newToken(16)
+	send := func(valid bool) int {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+		if valid {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	var codes []int
+	for range 4 {
+		codes = append(codes, send(false))
+	}
+	want := []int{http.StatusForbidden, http.StatusForbidden, http.StatusSeeOther, http.StatusSeeOther}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("got %v, want %v", codes, want)
+		}
+	}
+	if len(counts) != 2 || counts[0] != 3 || counts[1] != 4 {
+		t.Fatalf("challenge counts %v", counts)
+	}
+
+	send(true)
+	if code := send(false); code != http.StatusForbidden {
+		t.Fatalf("after success: got %d", code)
+	}
+	if n := p.stats.challenged.Load(); n != 2 {
+		t.Fatalf("challenged = %d", n)
+	}
+}
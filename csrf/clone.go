@@ -0,0 +1,113 @@
+package csrf
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"slices"
+)
+
+// Clone returns a new Protector built from p's effective configuration with
+// mutators applied in order, for closely related protectors such as an
+// admin panel with Strict SameSite and a shorter token lifetime:
+//
+//	admin := p.Clone(func(c *csrf.Config) {
+//	    c.CookieSameSite = http.SameSiteStrictMode
+//	    c.MaxTokenAge = 15 * time.Minute
+//	})
+//
+// Stores and hooks (Blocklist, FailureLimiter, StoreBreaker, ...) are shared
+// as they are. The key ring, its usage counters and SecretProvider
+// refreshes are shared too, unless a mutator changes SigningKey,
+// SigningKeys or SecretProvider, or the SecretProvider is a value of a
+// non-comparable type. Counters (see DebugHandler) are not shared.
+// Slices and maps are copied deeply (including the Methods and FormFields of
+// Rules, signing key secrets, trusted networks and the Profiles configs), so
+// mutators cannot alter p.
+//
+// Params:
+// - mutators: functions adjusting the copied Config.
+//
+// Returns:
+// - the new Protector (New panics on an invalid result, as for New).
+func (p *Protector) Clone(mutators ...func(*Config)) *Protector {
+	cfg := cloneConfig(p.cfg)
+	for _, m := range mutators {
+		m(&cfg)
+	}
+	c := New(cfg)
+	if sameKeys(p.cfg, c.cfg) {
+		c.keys = p.keys
+	}
+	return c
+}
+
+// cloneConfig returns a copy of cfg sharing no slice or map with it. Hooks,
+// stores and other interface values are shared.
+//
+// Params:
+// - cfg: the configuration to copy.
+//
+// Returns:
+// - the deep copy.
+func cloneConfig(cfg Config) Config {
+	cfg.FormFields = slices.Clone(cfg.FormFields)
+	cfg.AllowedOrigins = slices.Clone(cfg.AllowedOrigins)
+	cfg.AllowedOriginPatterns = slices.Clone(cfg.AllowedOriginPatterns)
+	cfg.AllowedExtensionIDs = slices.Clone(cfg.AllowedExtensionIDs)
+	cfg.SigningKey = slices.Clone(cfg.SigningKey)
+	cfg.SigningKeys = slices.Clone(cfg.SigningKeys)
+	for i := range cfg.SigningKeys {
+		cfg.SigningKeys[i].Secret = slices.Clone(cfg.SigningKeys[i].Secret)
+	}
+	cfg.PeerRegions = slices.Clone(cfg.PeerRegions)
+	cfg.FormStashKey = slices.Clone(cfg.FormStashKey)
+	cfg.AssertionKey = slices.Clone(cfg.AssertionKey)
+	cfg.DeviceKey = slices.Clone(cfg.DeviceKey)
+	cfg.RedactEventFields = slices.Clone(cfg.RedactEventFields)
+	cfg.TrustedProxies = cloneNets(cfg.TrustedProxies)
+	cfg.TrustedNetworks = cloneNets(cfg.TrustedNetworks)
+	cfg.Rules = slices.Clone(cfg.Rules)
+	for i := range cfg.Rules {
+		cfg.Rules[i].Methods = slices.Clone(cfg.Rules[i].Methods)
+		cfg.Rules[i].FormFields = slices.Clone(cfg.Rules[i].FormFields)
+	}
+	if cfg.Profiles != nil {
+		profiles := make(map[string]Config, len(cfg.Profiles))
+		for name, prof := range cfg.Profiles {
+			profiles[name] = cloneConfig(prof)
+		}
+		cfg.Profiles = profiles
+	}
+	return cfg
+}
+
+// cloneNets copies nets along with the IP and mask bytes of each network.
+func cloneNets(nets []net.IPNet) []net.IPNet {
+	nets = slices.Clone(nets)
+	for i := range nets {
+		nets[i].IP = slices.Clone(nets[i].IP)
+		nets[i].Mask = slices.Clone(nets[i].Mask)
+	}
+	return nets
+}
+
+// sameProvider reports whether a and b are the same SecretProvider. Values
+// of a non-comparable type (a struct holding a slice, say) cannot be
+// compared with == without panicking, so they are never reported equal.
+func sameProvider(a, b SecretProvider) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ta := reflect.TypeOf(a)
+	return ta == reflect.TypeOf(b) && ta.Comparable() && a == b
+}
+
+// sameKeys reports whether a and b sign and verify with the same keys.
+func sameKeys(a, b Config) bool {
+	return sameProvider(a.SecretProvider, b.SecretProvider) &&
+		bytes.Equal(a.SigningKey, b.SigningKey) &&
+		slices.EqualFunc(a.SigningKeys, b.SigningKeys, func(x, y SigningKeyEntry) bool {
+			return x.ID == y.ID && x.VerifyOnly == y.VerifyOnly && bytes.Equal(x.Secret, y.Secret)
+		})
+}
@@ -0,0 +1,77 @@
+package csrf
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+)
+
+// A clone differs only in the mutated fields and shares the key ring.
+func TestClone(t *testing.T) {
+	p := New(Config{TokenBytes: 16, SigningKey: bytes.Repeat([]byte{1}, 32), AllowedOrigins: []string{"app.example.com"}})
+	admin := p.Clone(func(c *Config) {
+		c.CookieSameSite = http.SameSiteStrictMode
+		c.AllowedOrigins = append(c.AllowedOrigins, "admin.example.com")
+	})
+
+	if admin.cfg.CookieSameSite != http.SameSiteStrictMode || p.cfg.CookieSameSite != http.SameSiteLaxMode {
+		t.Fatalf("unexpected SameSite: clone %v, parent %v", admin.cfg.CookieSameSite, p.cfg.CookieSameSite)
+	}
+	if len(p.cfg.AllowedOrigins) != 1 || len(admin.cfg.AllowedOrigins) != 2 {
+		t.Fatalf("expected the mutator not to alter the parent: %v / %v", p.cfg.AllowedOrigins, admin.cfg.AllowedOrigins)
+	}
+	if admin.keys != p.keys {
+		t.Fatal("expected the clone to share the key ring")
+	}
+	tok, _ := p.newToken()
+	if admin.countVerified(tok); !admin.verifyToken(tok) || p.KeyUsage()["default"].Verified != 1 {
+		t.Fatalf("expected the clone to verify the parent's token on the shared ring: %+v", p.KeyUsage())
+	}
+
+	rekeyed := p.Clone(func(c *Config) { c.SigningKey = bytes.Repeat([]byte{2}, 32) })
+	if rekeyed.keys == p.keys || rekeyed.verifyToken(tok) {
+		t.Fatal("expected a clone with another key not to share the ring")
+	}
+}
+
+// Mutators editing nested slices in place leave the original untouched.
+func TestCloneDeepCopiesRules(t *testing.T) {
+	p := New(Config{
+		Rules: []Rule{{PathPrefix: "/admin", Methods: []string{"POST"}, FormFields: []string{"admin_token"}}},
+		Profiles: map[string]Config{
+			"dev": {AllowedOrigins: []string{"localhost:3000"}},
+		},
+	})
+	p.Clone(func(c *Config) {
+		c.Rules[0].Methods[0] = "DELETE"
+		c.Rules[0].FormFields[0] = "other"
+		c.Profiles["dev"].AllowedOrigins[0] = "evil.example"
+	})
+
+	if r := p.cfg.Rules[0]; r.Methods[0] != "POST" || r.FormFields[0] != "admin_token" {
+		t.Fatalf("rule of the original altered: %+v", r)
+	}
+	if got := p.cfg.Profiles["dev"].AllowedOrigins[0]; got != "localhost:3000" {
+		t.Fatalf("profile of the original altered: %q", got)
+	}
+}
+
+// sliceProvider is a SecretProvider of a non-comparable value type.
+type sliceProvider struct {
+	keys []SigningKeyEntry
+}
+
+func (s sliceProvider) Current() SigningKeyEntry      { return s.keys[0] }
+func (s sliceProvider) All() []SigningKeyEntry        { return s.keys }
+func (s sliceProvider) Refresh(context.Context) error { return nil }
+
+// Cloning with a non-comparable SecretProvider value does not panic; the
+// clone loads its own key ring.
+func TestCloneNonComparableProvider(t *testing.T) {
+	p := New(Config{SecretProvider: sliceProvider{keys: []SigningKeyEntry{{ID: "a", Secret: bytes.Repeat([]byte{1}, 32)}}}})
+	c := p.Clone()
+	if c.keys == p.keys || c.keys.ring.Load() == nil {
+		t.Fatal("expected the clone to load its own key ring")
+	}
+}
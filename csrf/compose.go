@@ -0,0 +1,123 @@
+package csrf
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+const composeKey ctxKey = "csrf_compose_ctx"
+
+// Composite runs two Protectors side by side during a migration window (for
+// example, a legacy cookie name and new signed tokens): an unsafe request is
+// accepted when either validates it. Build it with Compose.
+type Composite struct {
+	primary, secondary *Protector
+
+	byPrimary, bySecondary, rejected atomic.Int64
+}
+
+// CompositeCounts reports which Protector of a Composite validated unsafe
+// requests.
+type CompositeCounts struct {
+	Primary   int64 `json:"primary"`
+	Secondary int64 `json:"secondary"`
+	Rejected  int64 `json:"rejected"`
+}
+
+// Compose returns a Composite of primary and secondary. Safe requests are
+// handled by primary alone, so new tokens come from the new policy; unsafe
+// requests are handled by secondary when they carry its token and not
+// primary's, else by primary, so each request goes through the checks (and
+// the hooks and stores) of one policy only. Once Counts shows no more
+// secondary validations, secondary can be dropped.
+//
+// Params:
+// - primary: the policy being migrated to.
+// - secondary: the policy being retired.
+//
+// Returns:
+// - the Composite; use its Protect method as the middleware.
+func Compose(primary, secondary *Protector) *Composite {
+	return &Composite{primary: primary, secondary: secondary}
+}
+
+// Protect wraps next with the composed policies.
+//
+// Params:
+// - next: downstream handler.
+//
+// Returns:
+// - the protected handler.
+func (c *Composite) Protect(next http.Handler) http.Handler {
+	reached := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, _ := r.Context().Value(composeKey).(*bool); ok != nil {
+			*ok = true
+		}
+		next.ServeHTTP(w, r)
+	})
+	primary := c.primary.Protect(reached)
+	secondary := c.secondary.Protect(reached)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !unsafeMethods[r.Method] {
+			primary.ServeHTTP(w, r)
+			return
+		}
+		h, count := primary, &c.byPrimary
+		if !claims(c.primary, r) && claims(c.secondary, r) {
+			h, count = secondary, &c.bySecondary
+		}
+		validated := false
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), composeKey, &validated)))
+		if validated {
+			count.Add(1)
+		} else {
+			c.rejected.Add(1)
+		}
+	})
+}
+
+// Counts returns how many unsafe requests each policy validated and how
+// many neither did.
+func (c *Composite) Counts() CompositeCounts {
+	return CompositeCounts{
+		Primary:   c.byPrimary.Load(),
+		Secondary: c.bySecondary.Load(),
+		Rejected:  c.rejected.Load(),
+	}
+}
+
+// claims reports whether r carries p's token: a well-formed client token
+// under p's names, read under p's header-only rules, matching p's cookie
+// and signed by one of p's keys when signing is on. In synchronizer token
+// mode the client token must match the one stored for the session. It
+// runs no hooks other than CookieNameFunc and SessionID, calls no store
+// but the SessionTokenStore and counts nothing, so the request is checked
+// once, by the Protector it selects.
+//
+// Params:
+// - p: one of the composed Protectors.
+// - r: unsafe request.
+//
+// Returns:
+// - true if r is meant for p.
+func claims(p *Protector, r *http.Request) bool {
+	header, fields := p.tokenNames(p.ruleFor(r))
+	client, err := extractClientToken(r, header, fields, p.headerOnly(r))
+	if err != nil || client == "" || !p.wellFormed(client) {
+		return false
+	}
+	if p.synchronized() {
+		stored, ok, err := p.sessionToken(r)
+		return err == nil && ok && p.tokensMatch(client, stored)
+	}
+	c, err := r.Cookie(p.cookieName(r))
+	if err != nil || !p.tokensMatch(client, c.Value) {
+		return false
+	}
+	if p.keyring() == nil {
+		return true
+	}
+	body, sig, ok := p.signedParts(c.Value)
+	return ok && p.keyring().match(body, sig) != nil
+}
@@ -0,0 +1,110 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Either policy may validate during the migration; Counts shows which did.
+func TestCompose(t *testing.T) {
+	legacy := New(Config{TokenBytes: 16, CookieName: "XSRF-TOKEN", HeaderName: "X-XSRF-TOKEN"})
+	signed := New(Config{TokenBytes: 16, SigningKey: make([]byte, 32)})
+	c := Compose(signed, legacy)
+	app := c.Protect(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "csrf_token" {
+		t.Fatalf("safe request should get the primary cookie, got %v", cookies)
+	}
+	newTok := cookies[0].Value
+	oldTok, _ := newToken(16)
+
+	post := func(cookie, header, tok string) int {
+		req := httptest.NewRequest(http.MethodPost, "/save", nil)
+		req.AddCookie(&http.Cookie{Name: cookie, Value: tok})
+		req.Header.Set(header, tok)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post("csrf_token", "X-CSRF-Token", newTok); code != http.StatusOK {
+		t.Fatalf("primary token: got %d", code)
+	}
+	if code := post("XSRF-TOKEN", "X-XSRF-TOKEN", oldTok); code != http.StatusOK {
+		t.Fatalf("legacy token: got %d", code)
+	}
+	if code := post("csrf_token", "X-CSRF-Token", oldTok); code != http.StatusForbidden {
+		t.Fatalf("unsigned token under primary name: got %d", code)
+	}
+	if got := c.Counts(); got != (CompositeCounts{Primary: 1, Secondary: 1, Rejected: 1}) {
+		t.Fatalf("counts %+v", got)
+	}
+}
+
+// Each unsafe request runs the hooks of one policy, once.
+func TestComposeRunsHooksOnce(t *testing.T) {
+	var exempt, rejects int
+	hooks := func(c Config) *Protector {
+		c.TokenBytes = 16
+		c.Exempt = func(*http.Request) bool { exempt++; return false }
+		c.OnReject = func(*http.Request, error) { rejects++ }
+		return New(c)
+	}
+	legacy := hooks(Config{CookieName: "XSRF-TOKEN", HeaderName: "X-XSRF-TOKEN"})
+	signed := hooks(Config{SigningKey: make([]byte, 32)})
+	app := Compose(signed, legacy).Protect(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	tok, _ := newToken(16)
+	for _, tc := range []struct {
+		cookie, header string
+		rejects        int
+	}{
+		{"XSRF-TOKEN", "X-XSRF-TOKEN", 0}, // legacy
+		{"csrf_token", "X-CSRF-Token", 1}, // neither
+	} {
+		exempt, rejects = 0, 0
+		req := httptest.NewRequest(http.MethodPost, "/save", nil)
+		req.AddCookie(&http.Cookie{Name: tc.cookie, Value: tok})
+		req.Header.Set(tc.header, tok)
+		app.ServeHTTP(httptest.NewRecorder(), req)
+		if exempt != 1 || rejects != tc.rejects {
+			t.Fatalf("%s: Exempt called %d times, OnReject %d times", tc.cookie, exempt, rejects)
+		}
+	}
+	if n := legacy.stats.validated.Load() + signed.stats.validated.Load(); n != 1 {
+		t.Fatalf("validated counted %d times", n)
+	}
+}
+
+// A synchronized policy claims only the token stored for the session, and
+// claims honor the policy's header-only rules without parsing the form.
+func TestComposeClaims(t *testing.T) {
+	sync := New(Config{
+		TokenBytes:        16,
+		SessionTokenStore: NewMemoryTokenStore(),
+		SessionID:         func(*http.Request) string { return "s1" },
+	})
+	legacy := New(Config{TokenBytes: 16, CookieName: "legacy", RequireHeaderForBodyless: true})
+	app := Compose(sync, legacy).Protect(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	tok, _ := newToken(16)
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "legacy", Value: tok})
+	req.Header.Set("X-CSRF-Token", tok)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("legacy token routed to the synchronized policy: %d", rec.Code)
+	}
+
+	form := httptest.NewRequest(http.MethodDelete, "/", strings.NewReader("csrf_token="+tok))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	form.AddCookie(&http.Cookie{Name: "legacy", Value: tok})
+	if claims(legacy, form) || form.Form != nil {
+		t.Fatal("claims read a form token RequireHeaderForBodyless forbids")
+	}
+}
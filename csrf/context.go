@@ -6,16 +6,25 @@ type ctxKey string
 
 const tokenKey ctxKey = "csrf_token_ctx"
 
-// contextWithToken returns a derived context that stores the given CSRF token.
+// requestState is stored in the request context by the middleware. Keeping the
+// token and the Protector in one value costs a single context.WithValue.
+type requestState struct {
+	token string
+	p     *Protector
+}
+
+// contextWithToken returns a derived context that stores the given CSRF token
+// and the Protector that issued it.
 //
 // Params:
 // - ctx: base context to attach the token to.
 // - tok: CSRF token string to store.
+// - p: the Protector handling the request.
 //
 // Returns:
 // - a new context containing the token.
-func contextWithToken(ctx context.Context, tok string) context.Context {
-	return context.WithValue(ctx, tokenKey, tok)
+func contextWithToken(ctx context.Context, tok string, p *Protector) context.Context {
+	return context.WithValue(ctx, tokenKey, &requestState{token: tok, p: p})
 }
 
 // tokenFromContext extracts the CSRF token from ctx, if present.
@@ -24,12 +33,29 @@ func contextWithToken(ctx context.Context, tok string) context.Context {
 // - ctx: context possibly containing the token.
 //
 // Returns:
-// - token (string) and a boolean indicating presence.
+//   - token (string) and a boolean indicating presence (false when the
+//     middleware ran but issued no token, see IssueOnNavigationOnly).
 func tokenFromContext(ctx context.Context) (string, bool) {
-	v := ctx.Value(tokenKey)
-	if v == nil {
+	st, ok := ctx.Value(tokenKey).(*requestState)
+	if !ok {
 		return "", false
 	}
-	s, ok := v.(string)
-	return s, ok
+	return st.token, st.token != ""
+}
+
+// ProtectorFromContext returns the Protector that handled the request, as
+// stored by the middleware (unless SkipContextInjection is set). Helpers use
+// it to resolve field and header names without passing the Protector around.
+//
+// Params:
+// - ctx: request context.
+//
+// Returns:
+// - the Protector and a boolean indicating presence.
+func ProtectorFromContext(ctx context.Context) (*Protector, bool) {
+	st, ok := ctx.Value(tokenKey).(*requestState)
+	if !ok {
+		return nil, false
+	}
+	return st.p, true
 }
@@ -0,0 +1,81 @@
+package csrf
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Route identifies a registered route for Coverage.
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// RouteCoverage is the protection Coverage found for a route.
+type RouteCoverage struct {
+	Route
+	Protection Protection `json:"protection"`
+	// Reason explains the result, e.g. "exempt" or `rule "/beta/"`.
+	Reason string `json:"reason"`
+}
+
+// Coverage reports how the middleware treats each of routes, so accidental
+// gaps (exempted or report-only routes) can be caught before release, e.g.
+// in a test walking the router:
+//
+//	var routes []csrf.Route
+//	chi.Walk(r, func(method, path string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+//	    routes = append(routes, csrf.Route{Method: method, Path: path})
+//	    return nil
+//	})
+//	for _, c := range p.Coverage(routes) { ... }
+//
+// Safe methods are reported as skipped. Exempt is called with a bodyless
+// request for the route, so predicates depending on the body or the client
+// (TrustedNetworks) cannot be evaluated; it only reports whether routes
+// are exempt by path.
+//
+// Params:
+// - routes: the routes to evaluate.
+//
+// Returns:
+// - one RouteCoverage per route, in order.
+func (p *Protector) Coverage(routes []Route) []RouteCoverage {
+	out := make([]RouteCoverage, 0, len(routes))
+	for _, rt := range routes {
+		out = append(out, p.coverage(rt))
+	}
+	return out
+}
+
+// coverage evaluates a single route.
+func (p *Protector) coverage(rt Route) RouteCoverage {
+	c := RouteCoverage{Route: rt, Protection: ProtectionSkipped}
+	method := strings.ToUpper(rt.Method)
+	if !unsafeMethods[method] {
+		c.Reason = "safe method"
+		return c
+	}
+	r, err := http.NewRequest(method, rt.Path, http.NoBody)
+	if err != nil {
+		c.Reason = "invalid path: " + err.Error()
+		return c
+	}
+	if p.cfg.Exempt != nil && p.cfg.Exempt(r) {
+		c.Reason = "exempt"
+		return c
+	}
+	rule := p.ruleFor(r)
+	c.Protection = p.protectionFor(rule)
+	switch {
+	case rule != nil && rule.ReportOnly:
+		c.Reason = `rule "` + rule.PathPrefix + `"`
+	case p.cfg.ReportOnly:
+		c.Reason = "ReportOnly"
+	case rule != nil:
+		c.Reason = `rule "` + rule.PathPrefix + `"`
+	default:
+		c.Reason = "default"
+	}
+	return c
+}
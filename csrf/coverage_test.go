@@ -0,0 +1,35 @@
+package csrf
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	p := New(Config{
+		Rules:  []Rule{{PathPrefix: "/beta/", ReportOnly: true}, {PathPrefix: "/account/", Sensitive: true}},
+		Exempt: func(r *http.Request) bool { return r.URL.Path == "/webhook" },
+	})
+	got := p.Coverage([]Route{
+		{Method: "get", Path: "/"},
+		{Method: http.MethodPost, Path: "/webhook"},
+		{Method: http.MethodPost, Path: "/beta/save"},
+		{Method: http.MethodDelete, Path: "/account/1"},
+		{Method: http.MethodPut, Path: "/profile"},
+	})
+	want := []struct {
+		protection Protection
+		reason     string
+	}{
+		{ProtectionSkipped, "safe method"},
+		{ProtectionSkipped, "exempt"},
+		{ProtectionReportOnly, `rule "/beta/"`},
+		{ProtectionEnforce, `rule "/account/"`},
+		{ProtectionEnforce, "default"},
+	}
+	for i, w := range want {
+		if got[i].Protection != w.protection || got[i].Reason != w.reason {
+			t.Errorf("%s %s: got %s (%s), want %s (%s)", got[i].Method, got[i].Path, got[i].Protection, got[i].Reason, w.protection, w.reason)
+		}
+	}
+}
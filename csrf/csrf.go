@@ -2,9 +2,43 @@ package csrf
 
 import (
 	"context"
-	"crypto/subtle"
 	"errors"
+	"net"
 	"net/http"
+	"strings"
+	"time"
+)
+
+// Reasons a request is rejected by the middleware, for errors.Is checks in
+// OnReject, ErrorHandler or on FailureReasonFromContext. The message doubles
+// as the plain-text response body. Origin failures are wrapped in an
+// *OriginError carrying the observed and expected hosts.
+var (
+	// ErrNoOrigin: EnforceOriginCheck is set and the request has neither
+	// Origin nor Referer.
+	ErrNoOrigin = errors.New("no origin/referer")
+	// ErrBadOrigin: the Origin is not allowed.
+	ErrBadOrigin = errors.New("bad origin")
+	// ErrBadReferer: there is no Origin and the Referer is not allowed.
+	ErrBadReferer = errors.New("bad referer")
+	// ErrMissingToken: the request carries no token.
+	ErrMissingToken = errors.New("missing CSRF token")
+	// ErrMissingHeaderToken: a bodyless request lacks the token header
+	// (RequireHeaderForBodyless).
+	ErrMissingHeaderToken = errors.New("missing header token")
+	// ErrTokenMismatch: the token does not match the cookie.
+	ErrTokenMismatch = errors.New("bad CSRF token")
+	// ErrRateLimited: FailureLimiter denied the client.
+	ErrRateLimited = errors.New("too many CSRF failures")
+	// ErrBlocked: the client is on the Blocklist.
+	ErrBlocked = errors.New("client blocked")
+	// ErrTokenExpired: the token is older than MaxTokenAge.
+	ErrTokenExpired = errors.New("CSRF token expired")
+	// ErrTokenStale: the token is older than MaxTokenAgeForSensitiveRoutes.
+	ErrTokenStale = errors.New("CSRF token stale")
+	// ErrBodyTooLarge: the form body exceeded its limit before the token
+	// field was read.
+	ErrBodyTooLarge = errors.New("request body too large")
 )
 
 // Methods that require CSRF protection
@@ -15,14 +49,31 @@ var unsafeMethods = map[string]bool{
 	http.MethodDelete: true,
 }
 
+// bodylessMethods are the unsafe methods whose requests conventionally carry
+// no body; see Config.RequireHeaderForBodyless.
+var bodylessMethods = map[string]bool{
+	http.MethodDelete: true,
+}
+
 // Protect wraps the given next http.Handler and enforces CSRF protection.
+// Nested wrappers of the same Protector (e.g., router groups that both apply
+// the middleware) detect the outer one through the request context and pass
+// straight through; with SkipContextInjection they re-run the checks, which
+// are idempotent.
 //
 // Behavior:
-//   - For "safe" methods (GET/HEAD/OPTIONS): ensures the token cookie exists and
+//   - For "safe" methods (GET/HEAD/OPTIONS): ensures the token cookie exists
+//     (only for HTML navigations when IssueOnNavigationOnly is set) and
 //     injects the token into the request context, then calls next.
-//   - For "unsafe" methods (POST/PUT/PATCH/DELETE): optionally validates Origin/Referer
-//     (when EnforceOriginCheck is true), extracts the client token from header or form,
-//     compares it in constant time against the cookie token, and only then calls next.
+//   - For "unsafe" methods (POST/PUT/PATCH/DELETE): lets requests from
+//     TrustedNetworks, accepted by Exempt or from apps verified by Attestor
+//     through, turns away clients on the Blocklist or denied by
+//     FailureLimiter, optionally validates Origin/Referer
+//     (when EnforceOriginCheck is true), extracts the client token from header
+//     or form, compares it in constant time against the cookie token, rejects
+//     tokens older than MaxTokenAge (or than the freshness demanded by the
+//     matching Rule), and only then calls next. With ReportOnly (globally or
+//     on the matching Rule), failures are reported and next is called anyway.
 //
 // Params:
 // - next: downstream handler to be executed after CSRF checks pass.
@@ -30,84 +81,636 @@ var unsafeMethods = map[string]bool{
 // Returns:
 // - An http.Handler that performs the CSRF logic before delegating to next.
 func (p *Protector) Protect(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return p.protect(next, false)
+}
+
+// protect builds Protect (routed false) and Prepare (routed true, where
+// unsafe requests no route of next matches are left to the router).
+//
+// Params:
+// - next: downstream handler.
+// - routed: whether unmatched unsafe requests are handed to next unchecked.
+//
+// Returns:
+// - the middleware handler.
+func (p *Protector) protect(next http.Handler, routed bool) http.Handler {
+	router := next
+	return p.guard(p.assertVerdict(next), func(w http.ResponseWriter, r *http.Request, next http.Handler) {
 		cfg := p.cfg
+		r = p.trackVerdict(r)
 
-		// 1) always ensure the cookie exists
-		cookieToken, err := p.ensureCookieToken(w, r)
-		if err != nil {
-			http.Error(w, "failed to set CSRF cookie", http.StatusInternalServerError)
+		// 0) an outer Protect or Prepare of this Protector already
+		// handled the request
+		if st, ok := r.Context().Value(tokenKey).(*requestState); ok && st.p == p {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		// inject the token into the request context for downstream handlers
-		r = r.WithContext(contextWithToken(r.Context(), cookieToken))
+		// a client cannot forge the verdict forwarded to upstream services
+		if cfg.ForwardAssertion != "" {
+			r.Header.Del(cfg.ForwardAssertion)
+		}
 
-		// 2) for safe methods, just continue
-		if !unsafeMethods[r.Method] {
+		// CORS preflights go straight to the CORS middleware inside
+		if cfg.PreflightPassthrough && isPreflight(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// 3) Origin/Referer validation (if enabled)
-		if cfg.EnforceOriginCheck {
-			if err := validateOriginOrReferer(r, cfg.AllowedOrigin); err != nil {
-				http.Error(w, "invalid origin", http.StatusForbidden)
-				return
-			}
+		// Prepare always injects the token, so inner Enforce calls see
+		// that the request was handled
+		r, cookieToken, ok := p.prepare(w, r, !cfg.SkipContextInjection || routed)
+		if !ok {
+			return
 		}
 
-		// 4) extract client-provided token (header or form)
-		clientToken := extractClientToken(r, cfg.HeaderName, cfg.FormField)
-		if clientToken == "" {
-			http.Error(w, "missing CSRF token", http.StatusForbidden)
+		// 2) for safe methods, just continue
+		rule := p.ruleFor(r)
+		if !unsafeMethods[r.Method] {
+			p.setStatusHeader(w, p.protectionFor(rule))
+			p.forwardAssertion(r, AssertionSafe)
+			p.pushToken(w, r)
+			p.sendEarlyHints(w, r, cookieToken)
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		// 5) time-constant compare
-		if subtle.ConstantTimeCompare([]byte(clientToken), []byte(cookieToken)) != 1 {
-			http.Error(w, "bad CSRF token", http.StatusForbidden)
+		if routed && unrouted(router, r) {
+			// no handler runs: the router answers 404 or 405
+			p.recordVerdict(r, AssertionSkipped)
+			next.ServeHTTP(w, r)
 			return
 		}
+		p.enforce(w, r, rule, cookieToken, next)
+	})
+}
+
+// prepare runs the checks and issuance that precede routing: oversized
+// headers of unsafe requests are rejected, the cookie is ensured and the
+// token injected into the request context.
+//
+// Params:
+// - w: response writer.
+// - r: incoming request.
+// - inject: whether to inject the token into the context.
+//
+// Returns:
+// - the request to pass on, the cookie token, and false when the response
+// was already written.
+func (p *Protector) prepare(w http.ResponseWriter, r *http.Request, inject bool) (*http.Request, string, bool) {
+	// oversized token, cookie or origin headers are turned away before
+	// anything is parsed
+	if unsafeMethods[r.Method] {
+		if err := p.checkLengths(r); err != nil {
+			p.reject(w, r, http.StatusRequestHeaderFieldsTooLarge, err)
+			return r, "", false
+		}
+	}
+
+	// 1) ensure the cookie exists (safe requests only when they qualify
+	// for issuance)
+	var cookieToken string
+	if unsafeMethods[r.Method] || p.shouldIssue(r) {
+		tok, err := p.ensureCookieToken(w, r)
+		if err != nil {
+			p.fail(w, r, http.StatusInternalServerError, issueFailure(err))
+			return r, "", false
+		}
+		cookieToken = tok
+	} else {
+		cookieToken, _ = p.cookieToken(r)
+	}
+
+	// inject the token into the request context for downstream handlers
+	if inject {
+		r = r.WithContext(context.WithValue(r.Context(), tokenKey,
+			&requestState{token: cookieToken, p: p}))
+	}
+	return r, cookieToken, true
+}
 
+// enforce runs the checks of an unsafe request (steps 3 to 10 of Protect)
+// and calls next when it passes or when failures are only reported.
+//
+// Params:
+// - w: response writer for the rejection response.
+// - r: incoming unsafe request.
+// - rule: the rule matching r, or nil.
+// - cookieToken: token from (or just set as) the cookie.
+// - next: downstream handler.
+func (p *Protector) enforce(w http.ResponseWriter, r *http.Request, rule *Rule, cookieToken string, next http.Handler) {
+	cfg := p.cfg
+
+	// 3) requests from trusted internal networks, explicitly exempted
+	// (e.g., signed webhooks) or from attested native apps skip
+	// enforcement
+	if p.fromTrustedNetwork(r) || (cfg.Exempt != nil && cfg.Exempt(r)) || p.attested(r) {
+		p.setStatusHeader(w, ProtectionSkipped)
+		p.forwardAssertion(r, AssertionSkipped)
 		next.ServeHTTP(w, r)
-	})
+		return
+	}
+
+	// 4) blocked or rate-limited clients are turned away early
+	if !p.admitClient(w, r) {
+		return
+	}
+
+	// 5-10) origin and token checks; in report-only mode failures are
+	// only reported
+	origin, err := p.verify(r, rule, cookieToken)
+	if err != nil {
+		if p.protectionFor(rule) == ProtectionReportOnly {
+			r = withFailure(r, err)
+			p.report(r, err)
+			p.setStatusHeader(w, ProtectionReportOnly)
+			p.forwardAssertion(r, AssertionReported)
+			next.ServeHTTP(w, r)
+			return
+		}
+		p.setStatusHeader(w, ProtectionEnforce)
+		p.reject(w, r, p.statusFor(err), err)
+		return
+	}
+
+	p.stats.validated.Add(1)
+	p.countVerified(cookieToken)
+	p.window.add(windowValidated)
+	p.countRoute(r, routeValidated)
+	p.countOriginMatch(r, origin)
+	p.clearFailures(r)
+	p.setStatusHeader(w, p.protectionFor(rule))
+	p.forwardAssertion(r, AssertionValidated)
+	next.ServeHTTP(w, r)
+}
+
+// statusFor returns the response status for a failed check: BodyTooLargeStatus
+// when the body exceeded an http.MaxBytesReader limit, MalformedTokenStatus
+// for a structurally invalid token, 403 otherwise.
+func (p *Protector) statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		return p.cfg.BodyTooLargeStatus
+	case errors.Is(err, ErrMalformedToken):
+		return p.cfg.MalformedTokenStatus
+	default:
+		return http.StatusForbidden
+	}
+}
+
+// headerOnly reports whether the token of r must come from the header:
+// bodyless methods under RequireHeaderForBodyless, and bodies larger than
+// HeaderOnlyAbove, are never form-parsed.
+func (p *Protector) headerOnly(r *http.Request) bool {
+	return p.cfg.RequireHeaderForBodyless && bodylessMethods[r.Method] ||
+		p.cfg.HeaderOnlyAbove > 0 && r.ContentLength > p.cfg.HeaderOnlyAbove
+}
+
+// verify runs the origin and token checks on an unsafe request.
+//
+// Params:
+// - r: incoming unsafe request.
+// - rule: the rule matching r, or nil.
+// - cookieToken: token from (or just set as) the cookie.
+//
+// Returns:
+// - the allowlist entry that accepted the origin ("" when the origin check
+// is disabled), and nil if the request passes; otherwise the rejection
+// reason.
+func (p *Protector) verify(r *http.Request, rule *Rule, cookieToken string) (string, error) {
+	cfg := p.cfg
+
+	// 5) Origin/Referer validation (if enabled)
+	var origin string
+	if cfg.EnforceOriginCheck {
+		entry, err := p.matchOrigin(r)
+		if err != nil {
+			return "", err
+		}
+		origin = entry
+	}
+
+	// in synchronizer token mode, the stored token belongs to a session
+	if p.synchronized() && cookieToken == "" && p.sessionKey(r) == "" {
+		return "", ErrNoSession
+	}
+
+	// 6) extract client-provided token (header or form, under the names
+	// of the matching rule)
+	headerName, formFields := p.tokenNames(rule)
+	headerOnly := p.headerOnly(r)
+	clientToken, err := extractClientToken(r, headerName, formFields, headerOnly)
+	if err != nil {
+		return "", err
+	}
+	if clientToken == "" {
+		if headerOnly {
+			return "", ErrMissingHeaderToken
+		}
+		return "", ErrMissingToken
+	}
+
+	// 7) a structurally invalid client token is a client bug, reported
+	// apart from a mismatch
+	if !p.wellFormed(clientToken) {
+		return "", ErrMalformedToken
+	}
+
+	// decode both tokens and compare the raw bytes in constant time
+	if !p.tokensMatch(clientToken, cookieToken) {
+		return "", ErrTokenMismatch
+	}
+
+	// a token replayed from another device does not carry its binding
+	if !p.deviceBound(r, cookieToken) {
+		return "", ErrDeviceMismatch
+	}
+
+	// 8) a valid but too old token is reported distinctly
+	if p.tokenStale(r, cfg.MaxTokenAge, false) {
+		return "", ErrTokenExpired
+	}
+
+	// 9) sensitive routes demand a recently issued token
+	if p.tokenStale(r, p.freshnessFor(rule), true) {
+		return "", ErrTokenStale
+	}
+
+	// 10) chaos testing: reject valid requests at the injected rate
+	if cfg.FaultInjector.reject() {
+		return "", errInjectedReject
+	}
+	return origin, nil
+}
+
+// fromTrustedNetwork reports whether the client IP of r (resolved with
+// TrustedProxies) belongs to TrustedNetworks.
+//
+// Params:
+// - r: incoming request.
+//
+// Returns:
+// - true if enforcement must be skipped for r.
+func (p *Protector) fromTrustedNetwork(r *http.Request) bool {
+	if len(p.cfg.TrustedNetworks) == 0 {
+		return false
+	}
+	ip := net.ParseIP(clientIP(r, p.cfg.TrustedProxies))
+	return inNetworks(ip, p.cfg.TrustedNetworks)
+}
+
+// admitClient checks the Blocklist and FailureLimiter for the client sending
+// r. When the client must be turned away, it writes the response itself; a
+// client denied by the limiter is also added to the Blocklist (if any). Store
+// calls are bounded by StoreTimeout and their failures handled according to
+// BackendFailurePolicy; while StoreBreaker is open they are skipped. Skipped
+// stores move p down the degradation ladder (see SecurityLevel).
+//
+// Params:
+// - w: response writer for the rejection response.
+// - r: incoming unsafe request.
+//
+// Returns:
+// - true if the request may proceed to validation; false if it was answered.
+func (p *Protector) admitClient(w http.ResponseWriter, r *http.Request) bool {
+	cfg := p.cfg
+	if cfg.FailureLimiter == nil && cfg.Blocklist == nil {
+		return true
+	}
+	if !cfg.StoreBreaker.allow() {
+		if !p.degrade(w, r, "stores") {
+			return false
+		}
+		p.stats.breakerSkipped.Add(1)
+		return true
+	}
+	ctx, cancel := p.storeContext(r)
+	defer cancel()
+	key := clientIP(r, cfg.TrustedProxies)
+	failed := false
+	defer func() {
+		if !failed {
+			p.setLevel(r, LevelStoreBacked)
+		}
+	}()
+
+	if cfg.Blocklist != nil {
+		blocked, err := cfg.Blocklist.Blocked(ctx, key)
+		cfg.StoreBreaker.record(err)
+		failed = err != nil
+		if failed && !p.backendFailed(w, r, "blocklist", err) {
+			return false
+		}
+		if blocked {
+			p.reject(w, r, http.StatusForbidden, ErrBlocked)
+			return false
+		}
+	}
+
+	if cfg.FailureLimiter != nil {
+		ok, err := cfg.FailureLimiter.Allow(ctx, key)
+		cfg.StoreBreaker.record(err)
+		if err != nil {
+			failed = true
+			if !p.backendFailed(w, r, "limiter", err) {
+				return false
+			}
+			ok = true
+		}
+		if !ok {
+			if cfg.Blocklist != nil {
+				until := time.Now().Add(cfg.BlockDuration)
+				if cfg.Blocklist.Block(ctx, key, until) == nil && cfg.OnBlock != nil {
+					cfg.OnBlock(key, until)
+				}
+			}
+			p.tarpit(r)
+			p.reject(w, r, http.StatusTooManyRequests, ErrRateLimited)
+			return false
+		}
+	}
+	return true
+}
+
+// reject counts the rejection, records a CSRF failure for the client (when
+// FailureLimiter is set and the client was not already turned away), notifies
+// OnReject and OnRejectEvent, refreshes the cookie (when
+// RefreshCookieOnFailure is set), stashes the form (when FormStashKey is set),
+// exposes the token minted for a retry (when DeferIssuance is set) and
+// writes the error response, or lets Challenge write it once the client
+// failed ChallengeAfter times.
+//
+// Params:
+//   - w: response writer for the error response.
+//   - r: the rejected request.
+//   - status: HTTP status code to respond with.
+//   - err: rejection reason; its message (without origin diagnostics) is the
+//     plain-text response body.
+func (p *Protector) reject(w http.ResponseWriter, r *http.Request, status int, err error) {
+	r = withFailure(r, err)
+	p.window.addRejection(err)
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		p.stats.limited.Add(1)
+	case errors.Is(err, ErrBlocked):
+		p.stats.blocked.Add(1)
+	default:
+		p.stats.rejected.Add(1)
+		p.countRoute(r, routeRejected)
+		p.captureRejection(r, err)
+		if l := p.cfg.FailureLimiter; l != nil && p.cfg.StoreBreaker.allow() {
+			ctx, cancel := p.storeContext(r)
+			p.cfg.StoreBreaker.record(l.Fail(ctx, clientIP(r, p.cfg.TrustedProxies)))
+			cancel()
+		}
+	}
+	if p.cfg.OnReject != nil {
+		p.cfg.OnReject(r, err)
+	}
+	if p.cfg.OnRejectEvent != nil {
+		p.cfg.OnRejectEvent(p.NewRejectionEvent(r, err))
+	}
+	if p.cfg.RefreshCookieOnFailure && !errors.Is(err, ErrRateLimited) && !errors.Is(err, ErrBlocked) {
+		p.refreshCookie(w, r)
+	}
+	if tokenFailure(err) {
+		p.stashForm(w, r)
+		if tok, ok := p.responseToken(w, r); ok && p.cfg.DeferIssuance {
+			w.Header().Set(p.cfg.HeaderName, tok)
+		}
+	}
+	if !errors.Is(err, ErrRateLimited) && !errors.Is(err, ErrBlocked) && p.challenge(w, r) {
+		return
+	}
+	p.fail(w, r, status, err)
+}
+
+// tokenFailure reports whether err rejects the token itself (as opposed to
+// the origin or the client), i.e. a failure an honest user can hit.
+func tokenFailure(err error) bool {
+	return errors.Is(err, ErrMissingToken) || errors.Is(err, ErrMissingHeaderToken) ||
+		errors.Is(err, ErrTokenMismatch) || errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrTokenStale) ||
+		errors.Is(err, ErrDeviceMismatch)
+}
+
+// refreshCookie mints a new token and sets it on the response, unless the
+// response already carries a CSRF cookie issued during this request.
+// Token generation failures are ignored: the rejection stands either way.
+//
+// Params:
+// - w: response writer of the rejected request.
+// - r: the rejected request.
+func (p *Protector) refreshCookie(w http.ResponseWriter, r *http.Request) {
+	if p.synchronized() {
+		return // the session keeps its token
+	}
+	if _, ok := p.responseToken(w, r); ok {
+		return
+	}
+	tok, err := p.newToken()
+	if err != nil {
+		return
+	}
+	p.setCookie(w, r, tok)
+	p.stats.issued.Add(1)
+	p.window.add(windowIssued)
+	p.countRoute(r, routeIssued)
+}
+
+// tarpit sleeps for FailureTarpit or until the request is canceled.
+//
+// Params:
+// - r: the request being delayed; its context bounds the wait.
+func (p *Protector) tarpit(r *http.Request) {
+	if p.cfg.FailureTarpit <= 0 {
+		return
+	}
+	t := time.NewTimer(p.cfg.FailureTarpit)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-r.Context().Done():
+	}
 }
 
 // ensureCookieToken checks for the CSRF token cookie on the incoming request.
-// If present and looks valid, it returns the cookie value. Otherwise, it generates
+// If present and well-formed, it returns the cookie value. Otherwise, it generates
 // a new random token, sets it as a cookie on the response, and returns the value.
+// On safe methods, a token older than MaxTokenAge, or not bound to the
+// device identifier the request now carries (see DeviceCookie), is replaced
+// as well; unsafe requests keep it so the rejection can say "expired" rather
+// than "bad".
+// A token already set on the response (e.g., by an outer Protect in the same
+// chain) is reused, so double-wrapping never emits duplicate Set-Cookie
+// headers.
 //
 // Params:
 // - w: response writer used to set the cookie when needed.
 // - r: incoming request to inspect cookies from.
 //
+// In synchronizer token mode, the token is stored for the request's session
+// instead of set as a cookie, and requests without a session get none.
+//
 // Returns:
 // - token string on success; empty string and error if token generation fails.
 func (p *Protector) ensureCookieToken(w http.ResponseWriter, r *http.Request) (string, error) {
-	cfg := p.cfg
-
-	if c, err := r.Cookie(cfg.CookieName); err == nil && len(c.Value) >= 16 {
-		return c.Value, nil
+	tok, ok := "", false
+	if p.synchronized() {
+		// a token that could not be read must not be replaced
+		var err error
+		if tok, ok, err = p.sessionToken(r); err != nil {
+			return "", err
+		}
+	} else {
+		tok, ok = p.cookieToken(r)
+	}
+	if ok {
+		if unsafeMethods[r.Method] || !p.tokenStale(r, p.reissueAge(r), true) && !p.unboundDevice(r, tok) {
+			return tok, nil
+		}
+	}
+	if tok, ok := p.responseToken(w, r); ok {
+		return tok, nil
+	}
+	if p.synchronized() && p.sessionKey(r) == "" {
+		return "", nil // no session to hold a token yet
 	}
 
-	tok, err := newToken(cfg.TokenBytes)
+	tok, err := p.mintToken(r)
 	if err != nil {
 		return "", err
 	}
 
-	http.SetCookie(w, &http.Cookie{
+	if err := p.issueToken(w, r, tok); err != nil {
+		return "", err
+	}
+	p.stats.issued.Add(1)
+	p.window.add(windowIssued)
+	p.countRoute(r, routeIssued)
+	return tok, nil
+}
+
+// setCookie adds the CSRF cookie carrying tok to the response, plus the
+// issuance timestamp cookie when TrackIssuedAt is set. The attribute portion
+// is rendered once by New, so only the values are spliced in here. The
+// response is marked for shared caches according to CacheSafety.
+//
+// Params:
+// - w: response writer to add the Set-Cookie header to.
+// - r: current request (for CookieNameFunc).
+// - tok: token value (base64url, always a valid cookie value).
+func (p *Protector) setCookie(w http.ResponseWriter, r *http.Request, tok string) {
+	w.Header().Add("Set-Cookie", p.cookieName(r)+"="+tok+p.cookieAttrs(r))
+	p.markUncacheable(w.Header())
+	if p.cfg.TrackIssuedAt {
+		p.setIssuedAtCookie(w, r)
+	}
+	if p.cfg.DeviceCookie != "" {
+		p.setDeviceCookie(w, r, tok)
+	}
+}
+
+// renderCookieSuffix serializes the static cookie attributes from cfg using
+// net/http so that domain/path validation rules are applied exactly once.
+//
+// Params:
+// - cfg: configuration with defaults already applied.
+//
+// Returns:
+// - the attribute string starting with "; " (or empty when no attributes apply).
+func renderCookieSuffix(cfg Config) string {
+	c := &http.Cookie{
 		Name:     cfg.CookieName,
-		Value:    tok,
+		Value:    "x",
 		Path:     cfg.CookiePath,
 		Domain:   cfg.CookieDomain,
 		MaxAge:   cfg.CookieMaxAge,
 		SameSite: cfg.CookieSameSite,
 		Secure:   cfg.CookieSecure,
 		HttpOnly: cfg.CookieHTTPOnly,
-	})
+	}
+	return strings.TrimPrefix(c.String(), cfg.CookieName+"=x")
+}
 
-	return tok, nil
+// cookieName returns the name of the token cookie for r: the result of
+// CookieNameFunc when it is a valid cookie name, CookieName otherwise, with
+// the suffix of r's cookie scope (see Rule.CookieScope).
+//
+// Params:
+// - r: current request.
+//
+// Returns:
+// - the cookie name.
+func (p *Protector) cookieName(r *http.Request) string {
+	name := p.cfg.CookieName
+	if f := p.cfg.CookieNameFunc; f != nil {
+		if n := f(r); validCookieName(n) {
+			name = n
+		}
+	}
+	if s := p.scopeFor(r); s != nil {
+		name += s.suffix
+	}
+	return name
+}
+
+// validCookieName reports whether name is a non-empty RFC 6265 token.
+func validCookieName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`()<>@,;:\"/[]?={}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// cookieToken returns the token carried by the request cookie, if it is
+// present, decodes to the configured TokenBytes and, for signed tokens,
+// carries a valid signature from an accepted region. In synchronizer token
+// mode, it is the token stored for the request's session instead.
+//
+// Params:
+// - r: incoming request to inspect cookies from.
+//
+// Returns:
+// - token (string) and a boolean indicating whether a usable token was found.
+func (p *Protector) cookieToken(r *http.Request) (string, bool) {
+	if p.synchronized() {
+		tok, ok, _ := p.sessionToken(r)
+		return tok, ok
+	}
+	c, err := r.Cookie(p.cookieName(r))
+	if err != nil || len(c.Value) > p.cfg.MaxCookieBytes || !p.acceptToken(c.Value) {
+		return "", false
+	}
+	return c.Value, true
+}
+
+// responseToken returns the token from a CSRF cookie already added to the
+// response headers by this middleware, if any.
+//
+// Params:
+// - w: response writer whose pending headers are inspected.
+// - r: current request (for CookieNameFunc).
+//
+// Returns:
+// - token (string) and a boolean indicating whether such a cookie was found.
+func (p *Protector) responseToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if p.synchronized() {
+		return "", false
+	}
+	name := p.cookieName(r)
+	for _, line := range w.Header().Values("Set-Cookie") {
+		c, err := http.ParseSetCookie(line)
+		if err == nil && c.Name == name {
+			return c.Value, true
+		}
+	}
+	return "", false
 }
 
 // TokenFromContext returns the CSRF token stored in ctx, if present.
@@ -121,52 +724,80 @@ func TokenFromContext(ctx context.Context) (string, bool) {
 	return tokenFromContext(ctx)
 }
 
+// currentToken resolves the token for r: from the context, then from a
+// cookie already set on the response, then from the request cookie, minting
+// a new one as a last resort (regardless of IssuePredicate).
+//
+// Params:
+// - w: response writer the cookie is set on when minting.
+// - r: current request.
+//
+// Returns:
+// - the token, or an error if token generation fails (ErrNoSession when
+// synchronizer token mode finds no session).
+func (p *Protector) currentToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if tok, ok := TokenFromContext(r.Context()); ok {
+		return tok, nil
+	}
+	if tok, ok := p.responseToken(w, r); ok {
+		return tok, nil
+	}
+	tok, err := p.ensureCookieToken(w, r)
+	if err == nil && tok == "" {
+		err = ErrNoSession
+	}
+	return tok, err
+}
+
 // TokenHandler returns an HTTP handler that writes the current CSRF token.
 // This is useful for SPAs to fetch the token and attach it to subsequent requests.
 //
+// When the token is not in the context (e.g., SkipContextInjection is set), it
+// falls back to the cookie already set on the response, then to the request
+// cookie, and finally mints a new token.
+//
+// When TokenCORSOrigin is set, requests from that origin get credentialed
+// CORS headers so a cross-origin SPA can read the token.
+//
+// Responses carry a weak ETag derived from the token; a request whose
+// If-None-Match lists it gets 304 Not Modified, which keeps SPAs that poll
+// the endpoint (on focus or visibility changes) cheap.
+//
+// TokenEndpointSameSite and TokenEndpointLimiter guard the endpoint against
+// cross-site use and high-volume probing.
+//
 // Returns:
 // - http.Handler that responds with the token in the response body (text/plain).
 func (p *Protector) TokenHandler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if tok, ok := TokenFromContext(r.Context()); ok {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.Write([]byte(tok))
+	return p.guard(nil, func(w http.ResponseWriter, r *http.Request, _ http.Handler) {
+		if !p.guardTokenEndpoint(w, r) {
 			return
 		}
-		http.Error(w, "no token", http.StatusInternalServerError)
+		tok, err := p.currentToken(w, r)
+		if errors.Is(err, ErrNoSession) {
+			p.fail(w, r, http.StatusForbidden, err)
+			return
+		}
+		if err != nil {
+			p.fail(w, r, http.StatusInternalServerError, issueFailure(err))
+			return
+		}
+		if o := p.cfg.TokenCORSOrigin; o != "" {
+			w.Header().Add("Vary", "Origin")
+			if strings.EqualFold(r.Header.Get("Origin"), o) {
+				w.Header().Set("Access-Control-Allow-Origin", o)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Set("Access-Control-Expose-Headers", "ETag")
+			}
+		}
+		etag := tokenETag(tok)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(tok))
 	})
 }
-
-// validateOriginOrReferer checks whether the request is same-site according to
-// the allowed host policy. When allowed is empty, it falls back to r.Host.
-// It prefers the Origin header; if empty, it falls back to Referer.
-//
-// Params:
-//   - r: the incoming request containing Origin/Referer headers.
-//   - allowed: the allowed host (domain[:port]) to be considered same-site;
-//     if empty, r.Host is used.
-//
-// Returns:
-// - nil when origin/referrer is acceptable; otherwise an error describing the issue.
-func validateOriginOrReferer(r *http.Request, allowed string) error {
-	// if allowed is empty, use the current request host as baseline
-	host := allowed
-	if host == "" {
-		host = r.Host
-	}
-
-	// Prefer Origin; if empty, use Referer.
-	origin := r.Header.Get("Origin")
-	ref := r.Header.Get("Referer")
-
-	if origin == "" && ref == "" {
-		return errors.New("no origin/referer")
-	}
-	if origin != "" && !sameSite(origin, host) {
-		return errors.New("bad origin")
-	}
-	if origin == "" && ref != "" && !sameSite(ref, host) {
-		return errors.New("bad referer")
-	}
-	return nil
-}
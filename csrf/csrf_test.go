@@ -314,3 +314,323 @@ func TestRefererCheck(t *testing.T) {
 		t.Fatalf("expected 403 with mismatching referer, got %d", recBad.Code)
 	}
 }
+
+// With SkipContextInjection, TokenHandler still returns the issued cookie token.
+func TestSkipContextInjection(t *testing.T) {
+	cfg := Config{
+		CookieName:           "csrf_token_test",
+		TokenBytes:           16,
+		SkipContextInjection: true,
+	}
+	p := New(cfg)
+
+	var seen bool
+	h := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, seen = TokenFromContext(r.Context())
+		p.TokenHandler().ServeHTTP(w, r)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/csrf-token", nil))
+	res := rec.Result()
+	defer res.Body.Close()
+
+	if seen {
+		t.Fatalf("expected no token in context")
+	}
+	cookies := res.Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected exactly one Set-Cookie, got %d", len(cookies))
+	}
+	body, _ := io.ReadAll(res.Body)
+	if string(body) != cookies[0].Value {
+		t.Fatalf("token mismatch: cookie=%q handler=%q", cookies[0].Value, body)
+	}
+}
+
+// The pre-rendered Set-Cookie header must match what net/http would produce.
+func TestPrecomputedCookieMatchesNetHTTP(t *testing.T) {
+	cfg := Config{
+		CookieName:     "csrf_token_test",
+		CookiePath:     "/app",
+		CookieDomain:   ".example.com",
+		CookieSecure:   true,
+		CookieHTTPOnly: true,
+		CookieSameSite: http.SameSiteStrictMode,
+		CookieMaxAge:   600,
+	}
+	p := New(cfg)
+
+	rec := httptest.NewRecorder()
+	p.setCookie(rec, httptest.NewRequest(http.MethodGet, "/", nil), "abcdefghijklmnopqrstuv")
+
+	want := (&http.Cookie{
+		Name:     cfg.CookieName,
+		Value:    "abcdefghijklmnopqrstuv",
+		Path:     cfg.CookiePath,
+		Domain:   cfg.CookieDomain,
+		MaxAge:   cfg.CookieMaxAge,
+		SameSite: cfg.CookieSameSite,
+		Secure:   true,
+		HttpOnly: true,
+	}).String()
+	if got := rec.Header().Get("Set-Cookie"); got != want {
+		t.Fatalf("Set-Cookie mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+// Tokens are compared on their decoded bytes: padding is tolerated, malformed or
+// wrongly sized tokens are rejected.
+func TestTokensEqual(t *testing.T) {
+	tok, err := newToken(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := newToken(16)
+	long, _ := newToken(32)
+
+	cases := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"equal", tok, tok, true},
+		{"padded", tok + "==", tok, true},
+		{"different", tok, other, false},
+		{"wrong size", long, long, false},
+		{"malformed", "!!!!!!!!!!!!!!!!!!!!!!", tok, false},
+		{"empty", "", "", false},
+	}
+	for _, tc := range cases {
+		if got := tokensEqual(tc.a, tc.b, 16); got != tc.want {
+			t.Errorf("%s: tokensEqual=%v want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// RefreshCookieOnFailure sets a new token cookie on the error response.
+func TestRefreshCookieOnFailure(t *testing.T) {
+	cfg := Config{
+		CookieName:             "csrf_token_test",
+		TokenBytes:             16,
+		RefreshCookieOnFailure: true,
+	}
+	app := appHandler(New(cfg))
+	token, _ := newToken(16)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.AddCookie(&http.Cookie{Name: cfg.CookieName, Value: token})
+	req.Header.Set("X-CSRF-Token", "stale")
+	app.ServeHTTP(rec, req)
+	res := rec.Result()
+
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", res.StatusCode)
+	}
+	c := getCookieByName(res, cfg.CookieName)
+	if c == nil || c.Value == token {
+		t.Fatalf("expected a fresh cookie on failure, got %v", c)
+	}
+
+	// missing cookie: the cookie issued by the middleware is not duplicated
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/submit", nil))
+	if n := len(rec.Result().Cookies()); n != 1 {
+		t.Fatalf("expected exactly one Set-Cookie, got %d", n)
+	}
+}
+
+// TokenHandler emits a weak ETag and answers a matching If-None-Match with 304;
+// "*" is not a match.
+func TestTokenHandlerETag(t *testing.T) {
+	p := New(Config{CookieName: "csrf_token_test", TokenBytes: 16})
+	h := tokenEndpointHandler(p)
+	token, _ := newToken(16)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/csrf-token", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token_test", Value: token})
+	h.ServeHTTP(rec, req)
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) || strings.Contains(etag, token) {
+		t.Fatalf("unexpected ETag %q", etag)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/csrf-token", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token_test", Value: token})
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected empty 304, got %d with %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/csrf-token", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token_test", Value: token})
+	req.Header.Set("If-None-Match", "*")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != token {
+		t.Fatalf("expected 200 with token for *, got %d with %q", rec.Code, rec.Body.String())
+	}
+}
+
+// Body-less requests are never form-parsed; with RequireHeaderForBodyless,
+// DELETE only accepts the header token.
+func TestBodylessRequests(t *testing.T) {
+	token, _ := newToken(16)
+	var parsed bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parsed = r.Form != nil
+	})
+
+	p := New(Config{CookieName: "csrf_token_test", TokenBytes: 16})
+	for _, method := range []string{http.MethodDelete, http.MethodPost} {
+		req := httptest.NewRequest(method, "/submit", nil)
+		req.Body = nil
+		req.AddCookie(&http.Cookie{Name: "csrf_token_test", Value: token})
+		rec := httptest.NewRecorder()
+		p.Protect(next).ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden || req.Form != nil {
+			t.Fatalf("%s: expected 403 without form parsing, got %d (form %v)", method, rec.Code, req.Form)
+		}
+
+		req = httptest.NewRequest(method, "/submit", nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token_test", Value: token})
+		req.Header.Set("X-CSRF-Token", token)
+		rec = httptest.NewRecorder()
+		p.Protect(next).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || parsed {
+			t.Fatalf("%s: expected 200 without form parsing, got %d", method, rec.Code)
+		}
+	}
+
+	p = New(Config{CookieName: "csrf_token_test", TokenBytes: 16, RequireHeaderForBodyless: true})
+	form := url.Values{"csrf_token": {token}}
+	req := httptest.NewRequest(http.MethodDelete, "/submit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "csrf_token_test", Value: token})
+	rec := httptest.NewRecorder()
+	p.Protect(next).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || strings.TrimSpace(rec.Body.String()) != "missing header token" {
+		t.Fatalf("expected header-only DELETE to be refused, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+// Above HeaderOnlyAbove the body is left untouched and the header is required.
+func TestHeaderOnlyAbove(t *testing.T) {
+	token, _ := newToken(16)
+	p := New(Config{CookieName: "csrf_token_test", TokenBytes: 16, HeaderOnlyAbove: 64})
+	app := appHandler(p)
+
+	post := func(body string, header bool) (*httptest.ResponseRecorder, *http.Request) {
+		req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "csrf_token_test", Value: token})
+		if header {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return rec, req
+	}
+
+	small := url.Values{"csrf_token": {token}}.Encode()
+	if rec, _ := post(small, false); rec.Code != http.StatusOK {
+		t.Fatalf("expected small form to pass, got %d", rec.Code)
+	}
+	large := url.Values{"csrf_token": {token}, "file": {strings.Repeat("x", 128)}}.Encode()
+	rec, req := post(large, false)
+	if rec.Code != http.StatusForbidden || strings.TrimSpace(rec.Body.String()) != "missing header token" {
+		t.Fatalf("expected header-only rejection, got %d %q", rec.Code, rec.Body.String())
+	}
+	if req.Form != nil {
+		t.Fatal("expected the large body to stay unread")
+	}
+	if rec, _ := post(large, true); rec.Code != http.StatusOK {
+		t.Fatalf("expected large upload with header token to pass, got %d", rec.Code)
+	}
+}
+
+// Applying Protect twice emits a single CSRF cookie and still validates.
+func TestDoubleProtectSingleCookie(t *testing.T) {
+	p := New(Config{CookieName: "csrf_token_test", TokenBytes: 16})
+	app := p.Protect(appHandler(p))
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/submit", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected exactly one Set-Cookie, got %d", len(cookies))
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.AddCookie(cookies[0])
+	req.Header.Set("X-CSRF-Token", cookies[0].Value)
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || len(rec.Result().Cookies()) != 0 {
+		t.Fatalf("expected 200 without new cookies, got %d %v", rec.Code, rec.Result().Cookies())
+	}
+}
+
+// A nested Protect of the same Protector does no work of its own, while a
+// different Protector still enforces its own checks.
+func TestNestedProtect(t *testing.T) {
+	var rejections int
+	p := New(Config{CookieName: "csrf_token_test", TokenBytes: 16, OnReject: func(*http.Request, error) { rejections++ }})
+	token, _ := newToken(16)
+
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token_test", Value: token})
+	req.Header.Set("X-CSRF-Token", token)
+	var inner *http.Request
+	app := p.Protect(p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = r
+	})))
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || inner == nil || p.stats.validated.Load() != 1 {
+		t.Fatalf("expected a single validation, got %d (validated %d)", rec.Code, p.stats.validated.Load())
+	}
+
+	other := New(Config{CookieName: "other_token", TokenBytes: 16})
+	rec = httptest.NewRecorder()
+	p.Protect(other.Protect(http.NotFoundHandler())).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || rejections != 0 {
+		t.Fatalf("expected the other Protector to reject, got %d", rec.Code)
+	}
+}
+
+// Malformed client tokens get MalformedTokenStatus and their own reason;
+// well-formed mismatches stay 403.
+func TestMalformedTokenStatus(t *testing.T) {
+	var reasons []string
+	p := New(Config{
+		TokenBytes:           16,
+		MalformedTokenStatus: http.StatusBadRequest,
+		OnRejectEvent:        func(e RejectionEvent) { reasons = append(reasons, e.Reason) },
+	})
+	app := appHandler(p)
+	cookie := strings.Repeat("A", 22)
+	for _, tc := range []struct {
+		token string
+		code  int
+	}{
+		{"not-a-token!", http.StatusBadRequest},
+		{strings.Repeat("A", 30), http.StatusBadRequest},
+		{strings.Repeat("B", 22), http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: cookie})
+		req.Header.Set("X-CSRF-Token", tc.token)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("token %q: got %d, want %d", tc.token, rec.Code, tc.code)
+		}
+	}
+	if strings.Join(reasons, ",") != "malformed_token,malformed_token,bad_token" {
+		t.Fatalf("unexpected reasons %v", reasons)
+	}
+}
@@ -0,0 +1,259 @@
+// Package csrftest provides canned CSRF attack requests that application
+// test suites can run against their real routers to prove, end to end, that
+// unsafe routes are protected:
+//
+//	func TestTransferIsProtected(t *testing.T) {
+//	    csrftest.Run(t, app.Router(), csrftest.Target{
+//	        URL:  "https://app.example.com/transfer",
+//	        Form: url.Values{"amount": {"100"}, "to": {"mallory"}},
+//	    })
+//	}
+//
+// Each vector is expected to be rejected with 403 (Target.RejectStatus).
+package csrftest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Target describes the route under attack. Zero fields take the defaults
+// of csrf.Config.
+type Target struct {
+	// Method of the protected route. Default: POST.
+	Method string
+
+	// URL is the absolute URL of the route, e.g.
+	// "https://app.example.com/transfer".
+	URL string
+
+	// Form holds legitimate form fields, sent as an url-encoded body.
+	Form url.Values
+
+	// TokenURL is a page issuing the token cookie on GET, used to obtain
+	// the victim's real cookie. Default: "/" on the URL's host.
+	TokenURL string
+
+	// CookieName, HeaderName and FormField default to "csrf_token",
+	// "X-CSRF-Token" and "csrf_token".
+	CookieName string
+	HeaderName string
+	FormField  string
+
+	// AttackerOrigin is the origin of the attacking site. Default:
+	// "https://attacker.example".
+	AttackerOrigin string
+
+	// RejectStatus is the status expected for every vector. Default: 403.
+	RejectStatus int
+}
+
+// Vector is one canned attack.
+type Vector struct {
+	// Name identifies the vector, e.g. "cross-origin form post".
+	Name string
+
+	// Build returns the attack request against t. It may issue requests to
+	// h first, e.g. to obtain the victim's cookie.
+	Build func(h http.Handler, t Target) (*http.Request, error)
+}
+
+// Result is the outcome of one vector.
+type Result struct {
+	Vector string
+	Status int
+	// Err is set when the request could not be built.
+	Err error
+}
+
+// Blocked reports whether the attack was rejected with want.
+func (r Result) Blocked(want int) bool {
+	return r.Err == nil && r.Status == want
+}
+
+// AttackVectors are the attacks Run and Check perform:
+//   - cross-origin form post: the victim's cookie rides along on a form
+//     posted from AttackerOrigin, without a token.
+//   - missing token: no cookie and no token.
+//   - cookie-only: a same-origin request with the victim's cookie but no
+//     token (the browser attaches the cookie, the attacker cannot read it).
+//   - token mismatch: the victim's cookie and a different, well-formed
+//     token in the header.
+//   - subdomain cookie planting: a sibling subdomain plants a cookie with
+//     a token of its choosing and submits the same token; it is blocked by
+//     the origin check or by signed tokens (csrf.Config.SigningKey).
+//   - null origin: a form posted from a sandboxed frame or a data: URL
+//     (Origin: null) with the victim's cookie.
+var AttackVectors = []Vector{
+	{Name: "cross-origin form post", Build: func(h http.Handler, t Target) (*http.Request, error) {
+		return victimRequest(h, t, t.AttackerOrigin)
+	}},
+	{Name: "missing token", Build: func(_ http.Handler, t Target) (*http.Request, error) {
+		req := formRequest(t, nil)
+		req.Header.Set("Origin", origin(t.URL))
+		return req, nil
+	}},
+	{Name: "cookie-only", Build: func(h http.Handler, t Target) (*http.Request, error) {
+		return victimRequest(h, t, origin(t.URL))
+	}},
+	{Name: "token mismatch", Build: func(h http.Handler, t Target) (*http.Request, error) {
+		req, err := victimRequest(h, t, origin(t.URL))
+		if err != nil {
+			return nil, err
+		}
+		c, _ := req.Cookie(t.CookieName)
+		req.Header.Set(t.HeaderName, mutate(c.Value))
+		return req, nil
+	}},
+	{Name: "subdomain cookie planting", Build: func(_ http.Handler, t Target) (*http.Request, error) {
+		planted := strings.Repeat("A", 43)
+		req := formRequest(t, url.Values{t.FormField: {planted}})
+		req.AddCookie(&http.Cookie{Name: t.CookieName, Value: planted})
+		req.Header.Set("Origin", "https://attacker."+req.URL.Hostname())
+		return req, nil
+	}},
+	{Name: "null origin", Build: func(h http.Handler, t Target) (*http.Request, error) {
+		return victimRequest(h, t, "null")
+	}},
+}
+
+// Run performs every AttackVectors entry against h as a subtest and fails
+// the ones that are not rejected.
+//
+// Params:
+// - t: the test.
+// - h: the application's real router.
+// - target: the route under attack.
+func Run(t *testing.T, h http.Handler, target Target) {
+	t.Helper()
+	target = target.withDefaults()
+	for _, v := range AttackVectors {
+		t.Run(v.Name, func(t *testing.T) {
+			res := check(h, target, v)
+			switch {
+			case res.Err != nil:
+				t.Fatalf("building the attack: %v", res.Err)
+			case !res.Blocked(target.RejectStatus):
+				t.Errorf("%s %s: attack got %d, want %d", target.Method, target.URL, res.Status, target.RejectStatus)
+			}
+		})
+	}
+}
+
+// Check performs every AttackVectors entry against h, for use outside of
+// Go tests (e.g., a smoke-test command).
+//
+// Params:
+// - h: the application's real router.
+// - target: the route under attack.
+//
+// Returns:
+// - one Result per vector, in order.
+func Check(h http.Handler, target Target) []Result {
+	target = target.withDefaults()
+	out := make([]Result, 0, len(AttackVectors))
+	for _, v := range AttackVectors {
+		out = append(out, check(h, target, v))
+	}
+	return out
+}
+
+// check builds and sends one vector.
+func check(h http.Handler, t Target, v Vector) Result {
+	req, err := v.Build(h, t)
+	if err != nil {
+		return Result{Vector: v.Name, Err: err}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return Result{Vector: v.Name, Status: rec.Code}
+}
+
+// withDefaults fills the zero fields of t.
+func (t Target) withDefaults() Target {
+	if t.Method == "" {
+		t.Method = http.MethodPost
+	}
+	if t.TokenURL == "" {
+		t.TokenURL = origin(t.URL) + "/"
+	}
+	if t.CookieName == "" {
+		t.CookieName = "csrf_token"
+	}
+	if t.HeaderName == "" {
+		t.HeaderName = "X-CSRF-Token"
+	}
+	if t.FormField == "" {
+		t.FormField = "csrf_token"
+	}
+	if t.AttackerOrigin == "" {
+		t.AttackerOrigin = "https://attacker.example"
+	}
+	if t.RejectStatus == 0 {
+		t.RejectStatus = http.StatusForbidden
+	}
+	return t
+}
+
+// formRequest returns the attack request carrying t.Form plus extra as an
+// url-encoded body.
+func formRequest(t Target, extra url.Values) *http.Request {
+	form := url.Values{}
+	for k, v := range t.Form {
+		form[k] = v
+	}
+	for k, v := range extra {
+		form[k] = v
+	}
+	req := httptest.NewRequest(t.Method, t.URL, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+// victimRequest returns a form request from originValue carrying the
+// victim's real token cookie, obtained from TokenURL.
+func victimRequest(h http.Handler, t Target, originValue string) (*http.Request, error) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, t.TokenURL, nil))
+	var cookies []*http.Cookie
+	found := false
+	for _, c := range rec.Result().Cookies() {
+		cookies = append(cookies, c)
+		found = found || c.Name == t.CookieName
+	}
+	if !found {
+		return nil, fmt.Errorf("csrftest: GET %s issued no %q cookie", t.TokenURL, t.CookieName)
+	}
+	req := formRequest(t, nil)
+	for _, c := range cookies {
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	req.Header.Set("Origin", originValue)
+	return req, nil
+}
+
+// origin returns the scheme and host of rawURL.
+func origin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// mutate returns tok with its first character changed, keeping it
+// well-formed.
+func mutate(tok string) string {
+	if tok == "" {
+		return strings.Repeat("B", 43)
+	}
+	c := byte('A')
+	if tok[0] == 'A' {
+		c = 'B'
+	}
+	return string(c) + tok[1:]
+}
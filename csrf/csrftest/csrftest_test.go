@@ -0,0 +1,55 @@
+package csrftest
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/JeanGrijp/go-csrf/csrf"
+)
+
+// app returns a router whose /transfer route is reached only through p.
+func app(p *csrf.Protector) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("home")) })
+	mux.HandleFunc("POST /transfer", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("sent")) })
+	return p.Protect(mux)
+}
+
+var target = Target{
+	URL:  "https://app.example.com/transfer",
+	Form: url.Values{"amount": {"100"}},
+}
+
+func TestRunAgainstProtectedRouter(t *testing.T) {
+	Run(t, app(csrf.New(csrf.Config{EnforceOriginCheck: true})), target)
+}
+
+// Without the origin check, plain tokens let a sibling subdomain plant its
+// own cookie; signed tokens close that gap.
+func TestCheckFindsCookiePlanting(t *testing.T) {
+	for _, res := range Check(app(csrf.New(csrf.Config{})), target) {
+		if blocked := res.Blocked(http.StatusForbidden); blocked == (res.Vector == "subdomain cookie planting") {
+			t.Errorf("%s: blocked=%v (status %d, err %v)", res.Vector, blocked, res.Status, res.Err)
+		}
+	}
+
+	signed := app(csrf.New(csrf.Config{SigningKey: make([]byte, 32)}))
+	for _, res := range Check(signed, target) {
+		if !res.Blocked(http.StatusForbidden) {
+			t.Errorf("%s: expected signed tokens to block it, got %d (err %v)", res.Vector, res.Status, res.Err)
+		}
+	}
+}
+
+func TestCheckReportsUnprotectedRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "csrf_token", Value: "x"})
+	})
+	for _, res := range Check(mux, target) {
+		if res.Blocked(http.StatusForbidden) {
+			t.Errorf("%s: expected an unprotected route not to block it", res.Vector)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package csrf
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DebugHandler returns an HTTP handler that reports the effective
+// configuration, the lifetime counters and the signing key usage of p as
+// JSON. Pluggable components (limiter, blocklist, hooks) are reported only
+// as present or absent, and secrets are never written out.
+//
+// The handler is intended for internal networks (admin ports, pod-local
+// listeners); do not mount it on a public route.
+//
+// Returns:
+// - http.Handler that responds with application/json.
+func (p *Protector) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		counters := p.stats.snapshot()
+		counters["poolAvailable"] = int64(p.pool.available())
+		json.NewEncoder(w).Encode(map[string]any{
+			"config":        p.debugConfig(),
+			"counters":      counters,
+			"keyUsage":      p.KeyUsage(),
+			"routes":        p.RouteStats(),
+			"originMatches": p.OriginMatches(),
+			"level":         p.SecurityLevel().String(),
+		})
+	})
+}
+
+// debugConfig returns a JSON-friendly view of the effective configuration.
+func (p *Protector) debugConfig() map[string]any {
+	cfg := p.cfg
+	proxies := make([]string, len(cfg.TrustedProxies))
+	for i, n := range cfg.TrustedProxies {
+		proxies[i] = n.String()
+	}
+	networks := make([]string, len(cfg.TrustedNetworks))
+	for i, n := range cfg.TrustedNetworks {
+		networks[i] = n.String()
+	}
+	return map[string]any{
+		"cookieName":                    cfg.CookieName,
+		"cookieNameFunc":                cfg.CookieNameFunc != nil,
+		"cookiePath":                    cfg.CookiePath,
+		"cookieDomain":                  cfg.CookieDomain,
+		"cookieSecure":                  cfg.CookieSecure,
+		"cookieHTTPOnly":                cfg.CookieHTTPOnly,
+		"cookieSameSite":                sameSiteName(cfg.CookieSameSite),
+		"autoSameSite":                  cfg.AutoSameSite,
+		"cookieMaxAge":                  cfg.CookieMaxAge,
+		"headerName":                    cfg.HeaderName,
+		"formFields":                    cfg.FormFields,
+		"enforceOriginCheck":            cfg.EnforceOriginCheck,
+		"allowedOrigins":                cfg.AllowedOrigins,
+		"originComparator":              cfg.OriginComparator != nil,
+		"allowedOriginPatterns":         cfg.AllowedOriginPatterns,
+		"allowedExtensionIDs":           cfg.AllowedExtensionIDs,
+		"allowFileOrigin":               cfg.AllowFileOrigin,
+		"onOriginMatch":                 cfg.OnOriginMatch != nil,
+		"malformedTokenStatus":          cfg.MalformedTokenStatus,
+		"bodyTooLargeStatus":            cfg.BodyTooLargeStatus,
+		"tokenBytes":                    cfg.TokenBytes,
+		"maxHeaderTokenBytes":           cfg.MaxHeaderTokenBytes,
+		"maxCookieBytes":                cfg.MaxCookieBytes,
+		"maxOriginBytes":                cfg.MaxOriginBytes,
+		"trackIssuedAt":                 cfg.TrackIssuedAt,
+		"maxTokenAge":                   cfg.MaxTokenAge.String(),
+		"requireHeaderForBodyless":      cfg.RequireHeaderForBodyless,
+		"headerOnlyAbove":               cfg.HeaderOnlyAbove,
+		"requestIDHeader":               cfg.RequestIDHeader,
+		"redactEventFields":             cfg.RedactEventFields,
+		"issueOnNavigationOnly":         cfg.IssueOnNavigationOnly,
+		"issuePredicate":                cfg.IssuePredicate != nil,
+		"reportOnly":                    cfg.ReportOnly,
+		"statusHeader":                  cfg.StatusHeader,
+		"forwardAssertion":              cfg.ForwardAssertion,
+		"assertionKey":                  len(cfg.AssertionKey) > 0,
+		"coalesceIssuance":              cfg.CoalesceIssuance.String(),
+		"deferIssuance":                 cfg.DeferIssuance,
+		"skipCookieOnHEAD":              cfg.SkipCookieOnHEAD,
+		"skipCookieOnOPTIONS":           cfg.SkipCookieOnOPTIONS,
+		"skipCookieOnPreflight":         cfg.SkipCookieOnPreflight,
+		"preflightPassthrough":          cfg.PreflightPassthrough,
+		"cacheSafety":                   cfg.CacheSafety.String(),
+		"formStash":                     len(cfg.FormStashKey) > 0,
+		"onSessionRenew":                cfg.OnSessionRenew != nil,
+		"faultInjector":                 cfg.FaultInjector,
+		"originCacheSize":               cfg.OriginCacheSize,
+		"tokenPoolSize":                 cfg.TokenPoolSize,
+		"storeTimeout":                  cfg.StoreTimeout.String(),
+		"backendFailurePolicy":          cfg.BackendFailurePolicy.String(),
+		"storeBreaker":                  cfg.StoreBreaker != nil,
+		"degradationFloor":              cfg.DegradationFloor.String(),
+		"onLevelChange":                 cfg.OnLevelChange != nil,
+		"signedTokens":                  p.keys.ring.Load() != nil,
+		"secretProvider":                cfg.SecretProvider != nil,
+		"secretRefreshInterval":         cfg.SecretRefreshInterval.String(),
+		"signingKeys":                   len(cfg.SigningKeys),
+		"keyRotation":                   p.keys.state(),
+		"region":                        cfg.Region,
+		"peerRegions":                   cfg.PeerRegions,
+		"rules":                         len(cfg.Rules),
+		"deviceCookie":                  cfg.DeviceCookie,
+		"deviceKey":                     len(cfg.DeviceKey) > 0,
+		"tokenStore":                    cfg.TokenStore != nil,
+		"formNonceTTL":                  cfg.FormNonceTTL.String(),
+		"formNonceField":                cfg.FormNonceField,
+		"routePattern":                  cfg.RoutePattern != nil,
+		"maxTokenAgeForSensitiveRoutes": cfg.MaxTokenAgeForSensitiveRoutes.String(),
+		"skipContextInjection":          cfg.SkipContextInjection,
+		"failureLimiter":                cfg.FailureLimiter != nil,
+		"failureTarpit":                 cfg.FailureTarpit.String(),
+		"trustedProxies":                proxies,
+		"trustedNetworks":               networks,
+		"exempt":                        cfg.Exempt != nil,
+		"attestor":                      cfg.Attestor != nil,
+		"blocklist":                     cfg.Blocklist != nil,
+		"blockDuration":                 cfg.BlockDuration.String(),
+		"tokenCORSOrigin":               cfg.TokenCORSOrigin,
+		"tokenEndpointSameSite":         cfg.TokenEndpointSameSite,
+		"tokenEndpointLimiter":          cfg.TokenEndpointLimiter != nil,
+		"onReject":                      cfg.OnReject != nil,
+		"errorHandler":                  cfg.ErrorHandler != nil,
+		"hostResolver":                  cfg.HostResolver != nil,
+		"pushTokenPath":                 cfg.PushTokenPath,
+		"earlyHintsToken":               cfg.EarlyHintsToken,
+		"sessionTokenStore":             cfg.SessionTokenStore != nil,
+		"sessionTokenTTL":               cfg.SessionTokenTTL.String(),
+		"statsWindow":                   cfg.StatsWindow.String(),
+		"challenge":                     cfg.Challenge != nil,
+		"challengeAfter":                cfg.ChallengeAfter,
+		"challengeWindow":               cfg.ChallengeWindow.String(),
+		"refreshCookieOnFailure":        cfg.RefreshCookieOnFailure,
+		"replayCapture":                 cfg.ReplayCapture,
+	}
+}
+
+// sameSiteName returns the attribute value browsers see for s.
+func sameSiteName(s http.SameSite) string {
+	switch s {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	default:
+		return "Default"
+	}
+}
@@ -0,0 +1,84 @@
+package csrf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// DebugHandler reports the effective config and counters as JSON.
+func TestDebugHandler(t *testing.T) {
+	p := New(Config{CookieName: "csrf_token_test", TokenBytes: 16})
+
+	// one issuance and one rejection
+	appHandler(p).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/submit", nil))
+	appHandler(p).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/submit", nil))
+
+	rec := httptest.NewRecorder()
+	p.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/csrf", nil))
+
+	var out struct {
+		Config   map[string]any   `json:"config"`
+		Counters map[string]int64 `json:"counters"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if out.Config["cookieName"] != "csrf_token_test" || out.Config["cookieSameSite"] != "Lax" {
+		t.Fatalf("unexpected config: %v", out.Config)
+	}
+	if out.Counters["issued"] != 2 || out.Counters["rejected"] != 1 {
+		t.Fatalf("unexpected counters: %v", out.Counters)
+	}
+}
+
+// DebugHandler reports enums by name and the key rotation state, whose
+// rotation time moves only when the signing key changes.
+func TestDebugHandlerKeyRotation(t *testing.T) {
+	sp := &stubProvider{current: "a", keys: []SigningKeyEntry{
+		{ID: "a", Secret: bytes.Repeat([]byte{1}, 32)},
+		{ID: "b", Secret: bytes.Repeat([]byte{2}, 32)},
+	}}
+	p := New(Config{SecretProvider: sp, CacheSafety: CacheSafetyVary, BackendFailurePolicy: FailOpen})
+
+	debug := func() map[string]any {
+		rec := httptest.NewRecorder()
+		p.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/csrf", nil))
+		var out struct {
+			Config map[string]any `json:"config"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		return out.Config
+	}
+	const epoch = "1970-01-01T00:00:00Z"
+
+	cfg := debug()
+	if cfg["cacheSafety"] != "vary" || cfg["backendFailurePolicy"] != "fail-open" {
+		t.Fatalf("enums not named: %v, %v", cfg["cacheSafety"], cfg["backendFailurePolicy"])
+	}
+	rot := cfg["keyRotation"].(map[string]any)
+	if rot["signer"] != "a" || fmt.Sprint(rot["keys"]) != "[a b]" || rot["loaded"] == epoch {
+		t.Fatalf("unexpected rotation state: %v", rot)
+	}
+
+	p.keys.rotated.Store(0)
+	if err := p.RefreshSecrets(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if rot := debug()["keyRotation"].(map[string]any); rot["rotated"] != epoch {
+		t.Fatalf("reload without a new signer counted as rotation: %v", rot)
+	}
+	sp.current = "b"
+	if err := p.RefreshSecrets(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if rot := debug()["keyRotation"].(map[string]any); rot["signer"] != "b" || rot["rotated"] == epoch {
+		t.Fatalf("rotation not reported: %v", rot)
+	}
+}
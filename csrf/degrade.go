@@ -0,0 +1,129 @@
+package csrf
+
+import (
+	"net/http"
+)
+
+// SecurityLevel is a rung of the degradation ladder: the protection an
+// unsafe request actually gets when parts of the deployment fail. Higher
+// levels are stronger.
+type SecurityLevel int
+
+const (
+	// LevelDoubleSubmit is plain double-submit validation: the token in the
+	// request must match the cookie. A cookie planted by a sibling
+	// subdomain is accepted.
+	LevelDoubleSubmit SecurityLevel = iota + 1
+
+	// LevelSigned adds signed tokens (SigningKey, SigningKeys or
+	// SecretProvider): only tokens minted by this deployment are accepted,
+	// though a planted cookie may carry one obtained from the site.
+	// Clients are not rate-limited or blocked.
+	LevelSigned
+
+	// LevelStoreBacked adds the Blocklist and FailureLimiter stores:
+	// clients that keep failing are rate-limited and blocked.
+	LevelStoreBacked
+)
+
+// String returns the level name used in logs and DebugHandler; the zero
+// value (no DegradationFloor) is "any".
+func (l SecurityLevel) String() string {
+	switch l {
+	case 0:
+		return "any"
+	case LevelDoubleSubmit:
+		return "double-submit"
+	case LevelSigned:
+		return "signed"
+	case LevelStoreBacked:
+		return "store-backed"
+	default:
+		return "unknown"
+	}
+}
+
+// fullLevel returns the level p provides when everything works.
+func (p *Protector) fullLevel() SecurityLevel {
+	if p.cfg.Blocklist != nil || p.cfg.FailureLimiter != nil {
+		return LevelStoreBacked
+	}
+	return p.statelessLevel()
+}
+
+// statelessLevel returns the level p falls back to without its stores. The
+// key ring lives in memory, so signed tokens never degrade further: a
+// failed secret refresh keeps the current keys.
+func (p *Protector) statelessLevel() SecurityLevel {
+	if p.keys.ring.Load() != nil {
+		return LevelSigned
+	}
+	return LevelDoubleSubmit
+}
+
+// SecurityLevel returns the level the latest unsafe request was validated
+// at: lower than the configured level while stores are failing (see
+// BackendFailurePolicy and StoreBreaker).
+func (p *Protector) SecurityLevel() SecurityLevel {
+	return SecurityLevel(p.level.Load())
+}
+
+// degrade lets r proceed without the stores, one rung down the ladder,
+// unless that would go below DegradationFloor; then it answers 500 itself.
+//
+// Params:
+// - w: response writer for the error response.
+// - r: current request.
+// - what: the unavailable component, used in the response (e.g. "limiter").
+//
+// Returns:
+// - true if the request may proceed at the lower level.
+func (p *Protector) degrade(w http.ResponseWriter, r *http.Request, what string) bool {
+	level := p.statelessLevel()
+	if level < p.cfg.DegradationFloor {
+		p.fail(w, r, http.StatusInternalServerError, unavailable(what))
+		return false
+	}
+	if level == LevelSigned {
+		p.stats.degradedSigned.Add(1)
+	} else {
+		p.stats.degradedDoubleSubmit.Add(1)
+	}
+	p.setLevel(r, level)
+	return true
+}
+
+// setLevel records the current level and reports transitions to Logger
+// and OnLevelChange.
+//
+// Params:
+// - r: the request that caused the transition.
+// - level: the new level.
+func (p *Protector) setLevel(r *http.Request, level SecurityLevel) {
+	from := SecurityLevel(p.level.Swap(int32(level)))
+	if from == level {
+		return
+	}
+	p.stats.levelChanges.Add(1)
+	if level < from {
+		p.cfg.Logger.Warn("csrf: security level degraded",
+			"from", from.String(), "to", level.String(), "lost", lostProperty(from), "path", r.URL.Path)
+	} else {
+		p.cfg.Logger.Info("csrf: security level restored", "from", from.String(), "to", level.String())
+	}
+	if p.cfg.OnLevelChange != nil {
+		p.cfg.OnLevelChange(from, level)
+	}
+}
+
+// lostProperty describes what is lost when dropping below level.
+func lostProperty(level SecurityLevel) string {
+	switch level {
+	case LevelStoreBacked:
+		return "rate limiting and blocking of failing clients"
+	case LevelSigned:
+		return "rejection of tokens not minted by this deployment"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,80 @@
+package csrf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// flakyLimiter fails while down is set.
+type flakyLimiter struct{ down atomic.Bool }
+
+func (l *flakyLimiter) Allow(context.Context, string) (bool, error) {
+	if l.down.Load() {
+		return false, errors.New("store down")
+	}
+	return true, nil
+}
+
+func (l *flakyLimiter) Fail(context.Context, string) error { return nil }
+
+// Failing stores move down the ladder and back, within DegradationFloor.
+func TestDegradationLadder(t *testing.T) {
+	l := &flakyLimiter{}
+	var changes []string
+	p := New(Config{
+		TokenBytes:           16,
+		SigningKey:           make([]byte, 32),
+		FailureLimiter:       l,
+		BackendFailurePolicy: FailOpen,
+		OnLevelChange: func(from, to SecurityLevel) {
+			changes = append(changes, from.String()+">"+to.String())
+		},
+	})
+	if p.SecurityLevel() != LevelStoreBacked {
+		t.Fatalf("initial level %v", p.SecurityLevel())
+	}
+	token, _ := p.newToken()
+	send := func(p *Protector) int {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+		req.Header.Set("X-CSRF-Token", token)
+		rec := httptest.NewRecorder()
+		appHandler(p).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	l.down.Store(true)
+	send(p)
+	send(p)
+	if p.SecurityLevel() != LevelSigned || p.stats.degradedSigned.Load() != 2 {
+		t.Fatalf("degraded: level %v, count %d", p.SecurityLevel(), p.stats.degradedSigned.Load())
+	}
+	l.down.Store(false)
+	send(p)
+	if p.SecurityLevel() != LevelStoreBacked {
+		t.Fatalf("recovered: level %v", p.SecurityLevel())
+	}
+	if len(changes) != 2 || changes[0] != "store-backed>signed" || changes[1] != "signed>store-backed" {
+		t.Fatalf("changes: %v", changes)
+	}
+
+	// unsigned tokens would fall to plain double-submit, below the floor
+	l.down.Store(true)
+	p = New(Config{
+		TokenBytes:           16,
+		FailureLimiter:       l,
+		BackendFailurePolicy: FailOpen,
+		DegradationFloor:     LevelSigned,
+	})
+	token, _ = p.newToken()
+	if code := send(p); code != http.StatusInternalServerError {
+		t.Fatalf("below floor: got %d", code)
+	}
+	if p.SecurityLevel() != LevelStoreBacked {
+		t.Fatalf("level changed below floor: %v", p.SecurityLevel())
+	}
+}
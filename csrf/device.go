@@ -0,0 +1,104 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// deviceSuffix is appended to the token cookie name to name the companion
+// cookie carrying the device binding (see Config.DeviceCookie).
+const deviceSuffix = "_dev"
+
+// ErrDeviceMismatch rejects a token presented by a device other than the
+// one it was bound to (see Config.DeviceCookie).
+var ErrDeviceMismatch = errors.New("CSRF token bound to another device")
+
+// deviceID returns the device identifier carried by r in DeviceCookie.
+func (p *Protector) deviceID(r *http.Request) (string, bool) {
+	c, err := r.Cookie(p.cfg.DeviceCookie)
+	if err != nil || c.Value == "" || len(c.Value) > p.cfg.MaxCookieBytes {
+		return "", false
+	}
+	return c.Value, true
+}
+
+// deviceBinding returns the binding of tok to the device id: the
+// base64url HMAC-SHA256 of both under DeviceKey.
+func (p *Protector) deviceBinding(tok, id string) string {
+	return base64.RawURLEncoding.EncodeToString(tokenMAC(p.cfg.DeviceKey, tok+"\x00"+id))
+}
+
+// deviceBound reports whether tok was issued to the device r comes from.
+// It is always true when DeviceCookie is not set.
+//
+// Params:
+// - r: incoming request carrying the device and binding cookies.
+// - tok: the token from the cookie.
+//
+// Returns:
+// - false when the device cookie or binding is missing, or the binding was
+// computed for another token or device.
+func (p *Protector) deviceBound(r *http.Request, tok string) bool {
+	if p.cfg.DeviceCookie == "" {
+		return true
+	}
+	id, ok := p.deviceID(r)
+	if !ok {
+		return false
+	}
+	c, err := r.Cookie(p.cookieName(r) + deviceSuffix)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(c.Value), []byte(p.deviceBinding(tok, id)))
+}
+
+// setDeviceCookie adds the binding of tok to the device of r, with the
+// attributes of the token cookie plus HttpOnly, so scripts (including
+// injected ones) cannot read it. Nothing is set when r carries no device
+// identifier: the token stays unbound and is replaced on the next safe
+// request that has one.
+//
+// Params:
+// - w: response writer to add the Set-Cookie header to.
+// - r: current request.
+// - tok: the token just set on the response.
+func (p *Protector) setDeviceCookie(w http.ResponseWriter, r *http.Request, tok string) {
+	id, ok := p.deviceID(r)
+	if !ok {
+		return
+	}
+	attrs := p.cookieAttrs(r)
+	if !strings.Contains(attrs, "; HttpOnly") {
+		attrs += "; HttpOnly"
+	}
+	w.Header().Add("Set-Cookie", p.cookieName(r)+deviceSuffix+"="+p.deviceBinding(tok, id)+attrs)
+}
+
+// validateDevice checks that DeviceCookie and DeviceKey are set together
+// and that the key is long enough.
+func validateDevice(cfg Config) error {
+	switch {
+	case cfg.DeviceCookie == "" && len(cfg.DeviceKey) == 0:
+		return nil
+	case !validCookieName(cfg.DeviceCookie):
+		return fmt.Errorf("csrf: DeviceKey needs a valid DeviceCookie, got %q", cfg.DeviceCookie)
+	case len(cfg.DeviceKey) < minSigningKeyBytes:
+		return fmt.Errorf("csrf: DeviceKey must be at least %d bytes, got %d", minSigningKeyBytes, len(cfg.DeviceKey))
+	}
+	return nil
+}
+
+// unboundDevice reports whether tok must be replaced on a safe request: r
+// carries a device identifier but tok is not bound to it.
+func (p *Protector) unboundDevice(r *http.Request, tok string) bool {
+	if p.cfg.DeviceCookie == "" {
+		return false
+	}
+	_, ok := p.deviceID(r)
+	return ok && !p.deviceBound(r, tok)
+}
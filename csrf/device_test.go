@@ -0,0 +1,63 @@
+package csrf
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A token is accepted only with the device identifier it was issued to.
+func TestDeviceBinding(t *testing.T) {
+	p := New(Config{TokenBytes: 16, DeviceCookie: "device_id", DeviceKey: bytes.Repeat([]byte("k"), 32)})
+	app := p.Protect(appHandler(p))
+
+	device := &http.Cookie{Name: "device_id", Value: "victim-device"}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(device)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	tok := getCookieByName(rec.Result(), "csrf_token")
+	binding := getCookieByName(rec.Result(), "csrf_token_dev")
+	if tok == nil || binding == nil || !binding.HttpOnly {
+		t.Fatalf("expected token and HttpOnly binding cookies, got %v", rec.Result().Cookies())
+	}
+
+	post := func(device *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.Header.Set("X-CSRF-Token", tok.Value)
+		req.AddCookie(tok)
+		req.AddCookie(binding)
+		req.AddCookie(device)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := post(device); rec.Code != http.StatusOK {
+		t.Fatalf("same device: got %d", rec.Code)
+	}
+	rec = post(&http.Cookie{Name: "device_id", Value: "attacker-device"})
+	if rec.Code != http.StatusForbidden || strings.TrimSpace(rec.Body.String()) != ErrDeviceMismatch.Error() {
+		t.Fatalf("other device: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// a safe request from another device replaces the token
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(tok)
+	req.AddCookie(&http.Cookie{Name: "device_id", Value: "attacker-device"})
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if c := getCookieByName(rec.Result(), "csrf_token"); c == nil || c.Value == tok.Value {
+		t.Fatalf("unbound token not replaced: %v", c)
+	}
+}
+
+func TestDeviceBindingValidation(t *testing.T) {
+	if err := (Config{DeviceCookie: "device_id", DeviceKey: []byte("short")}).Validate(); err == nil {
+		t.Fatal("short DeviceKey accepted")
+	}
+	if err := (Config{DeviceKey: bytes.Repeat([]byte("k"), 32)}).Validate(); err == nil {
+		t.Fatal("DeviceKey without DeviceCookie accepted")
+	}
+}
@@ -15,8 +15,8 @@
 // All behavior is driven by Config. Key fields include:
 //   - CookieName, CookiePath, CookieDomain, CookieSecure, CookieHTTPOnly, CookieSameSite, CookieMaxAge
 //   - HeaderName (default: "X-CSRF-Token")
-//   - FormField (default: "csrf_token")
-//   - EnforceOriginCheck and AllowedOrigin (empty means use the request host)
+//   - FormFields (default: ["csrf_token"])
+//   - EnforceOriginCheck and AllowedOrigins (empty means use the request host)
 //   - TokenBytes (default: 32)
 //
 // HttpOnly note
@@ -0,0 +1,27 @@
+package csrf
+
+import "net/http"
+
+// sendEarlyHints writes a 103 Early Hints response carrying the token in
+// HeaderName (and the Link preload of PushTokenPath, if any) ahead of an
+// HTML page, so a frontend can start mutations before the page arrived.
+// Set-Cookie headers are held back for the final response. HTTP/1.0
+// clients, which cannot receive 1xx responses, are skipped.
+//
+// Params:
+// - w: response writer of the page, before anything was written.
+// - r: safe page request.
+// - token: the request's token ("" when none was issued).
+func (p *Protector) sendEarlyHints(w http.ResponseWriter, r *http.Request, token string) {
+	if !p.cfg.EarlyHintsToken || token == "" || r.Method != http.MethodGet ||
+		!r.ProtoAtLeast(1, 1) || !isNavigation(r) {
+		return
+	}
+	h := w.Header()
+	h.Set(p.cfg.HeaderName, token)
+	cookies := h.Values("Set-Cookie")
+	h.Del("Set-Cookie")
+	w.WriteHeader(http.StatusEarlyHints)
+	h["Set-Cookie"] = cookies
+	p.stats.earlyHints.Add(1)
+}
@@ -0,0 +1,60 @@
+package csrf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestEarlyHintsToken(t *testing.T) {
+	p := New(Config{EarlyHintsToken: true, PushTokenPath: "/csrf-token"})
+	srv := httptest.NewServer(p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>"))
+	})))
+	defer srv.Close()
+
+	var hints []textproto.MIMEHeader
+	trace := &httptrace.ClientTrace{Got1xxResponse: func(code int, h textproto.MIMEHeader) error {
+		if code == http.StatusEarlyHints {
+			hints = append(hints, h)
+		}
+		return nil
+	}}
+	req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, srv.URL+"/", nil)
+	req.Header.Set("Sec-Fetch-Mode", "navigate")
+	req.Header.Set("Sec-Fetch-Dest", "document")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	c := getCookieByName(resp, "csrf_token")
+	if c == nil || len(hints) != 1 {
+		t.Fatalf("cookie %v, %d early hints", c, len(hints))
+	}
+	h := hints[0]
+	if h.Get("X-CSRF-Token") != c.Value || !strings.Contains(h.Get("Link"), "</csrf-token>") {
+		t.Fatalf("early hints = %v", h)
+	}
+	if h.Get("Set-Cookie") != "" {
+		t.Fatalf("early hints carry Set-Cookie: %v", h)
+	}
+
+	// fetches get no hints
+	hints = nil
+	req, _ = http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, srv.URL+"/", nil)
+	req.Header.Set("Sec-Fetch-Mode", "cors")
+	resp, err = srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(hints) != 0 {
+		t.Fatalf("early hints sent for a fetch: %v", hints)
+	}
+}
@@ -0,0 +1,73 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Failures of the middleware itself, as opposed to rejections of the
+// request, passed to ErrorHandler with a 500 status.
+var (
+	// ErrCookieFailed: no token could be generated for the cookie.
+	ErrCookieFailed = errors.New("failed to set CSRF cookie")
+	// ErrRotateFailed: RotateHandler could not generate a new token.
+	ErrRotateFailed = errors.New("failed to rotate CSRF token")
+	// ErrUnavailable: a required store (limiter, blocklist, nonce store,
+	// ...) failed and BackendFailurePolicy or DegradationFloor did not let
+	// the request proceed. The error passed to handlers names the store
+	// and wraps it.
+	ErrUnavailable = errors.New("CSRF store unavailable")
+	// ErrPanic: the middleware or one of its hooks panicked; the request
+	// is rejected with a plain 500 that bypasses ErrorHandler, after
+	// OnReject and OnRejectEvent are notified.
+	ErrPanic = errors.New("CSRF check failed")
+)
+
+// unavailable returns the error for a required component that failed
+// (e.g. "limiter" gives "CSRF limiter unavailable"). It wraps
+// ErrUnavailable.
+func unavailable(what string) error {
+	return &unavailableError{what: what}
+}
+
+// unavailableError names the component behind ErrUnavailable.
+type unavailableError struct {
+	what string
+}
+
+// Error returns "CSRF <what> unavailable".
+func (e *unavailableError) Error() string {
+	return "CSRF " + e.what + " unavailable"
+}
+
+// Unwrap returns ErrUnavailable.
+func (e *unavailableError) Unwrap() error {
+	return ErrUnavailable
+}
+
+// issueFailure returns the error reported when no token could be issued:
+// err itself when a store is unavailable, ErrCookieFailed otherwise.
+func issueFailure(err error) error {
+	if errors.Is(err, ErrUnavailable) {
+		return err
+	}
+	return ErrCookieFailed
+}
+
+// fail writes the error response for a rejected or failed request: through
+// ErrorHandler when set, as a plain-text http.Error with the public reason
+// otherwise.
+//
+// Params:
+// - w: response writer.
+// - r: current request.
+// - status: response status code.
+// - err: the rejection reason or internal failure.
+func (p *Protector) fail(w http.ResponseWriter, r *http.Request, status int, err error) {
+	r = withFailure(r, err)
+	if h := p.cfg.ErrorHandler; h != nil {
+		h(w, r, status, err)
+		return
+	}
+	http.Error(w, publicReason(err).Error(), status)
+}
@@ -0,0 +1,68 @@
+package csrf
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorHandlerWritesCustomResponse(t *testing.T) {
+	var gotStatus int
+	var gotErr error
+	p := New(Config{ErrorHandler: func(w http.ResponseWriter, r *http.Request, status int, err error) {
+		gotStatus, gotErr = status, err
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": ReasonCode(err)})
+	}})
+	h := p.Protect(appHandler(p))
+
+	req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader("a=b"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden || gotStatus != http.StatusForbidden {
+		t.Fatalf("status = %d (handler saw %d), want 403", rec.Code, gotStatus)
+	}
+	if !errors.Is(gotErr, ErrMissingToken) {
+		t.Fatalf("err = %v, want missing token", gotErr)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["error"] != "missing_token" {
+		t.Fatalf("body = %v (%v), want missing_token envelope", body, err)
+	}
+}
+
+func TestErrorHandlerReceivesInternalFailures(t *testing.T) {
+	var gotStatus int
+	var gotErr error
+	p := New(Config{ErrorHandler: func(w http.ResponseWriter, r *http.Request, status int, err error) {
+		gotStatus, gotErr = status, err
+		w.WriteHeader(status)
+	}})
+
+	rec := httptest.NewRecorder()
+	p.fail(rec, httptest.NewRequest(http.MethodPost, "/", nil), http.StatusInternalServerError, unavailable("limiter"))
+	if gotStatus != http.StatusInternalServerError || ReasonCode(gotErr) != "unavailable" {
+		t.Fatalf("got %d %q, want 500 unavailable", gotStatus, ReasonCode(gotErr))
+	}
+	if gotErr.Error() != "CSRF limiter unavailable" {
+		t.Fatalf("err = %q", gotErr)
+	}
+}
+
+func TestDefaultErrorResponseIsPlainText(t *testing.T) {
+	p := New(Config{})
+	rec := httptest.NewRecorder()
+	p.fail(rec, httptest.NewRequest(http.MethodPost, "/", nil), http.StatusForbidden, ErrTokenMismatch)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), ErrTokenMismatch.Error()) {
+		t.Fatalf("got %d %q", rec.Code, rec.Body.String())
+	}
+}
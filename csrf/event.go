@@ -0,0 +1,292 @@
+package csrf
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RejectionEvent describes a request rejected by the middleware. It is the
+// single payload handed to observers (OnRejectEvent, audit sinks, webhooks),
+// a plain struct so it can be serialized directly (e.g. as JSON) or formatted
+// for SIEM ingestion with CEF and LEEF.
+type RejectionEvent struct {
+	Time        time.Time `json:"time"`
+	Reason      string    `json:"reason"` // short code, e.g. "bad_token"
+	Message     string    `json:"message"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	ClientIP    string    `json:"client_ip"`
+	Origin      string    `json:"origin,omitempty"`
+	Referer     string    `json:"referer,omitempty"`
+	RefererHost string    `json:"referer_host,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	RequestID   string    `json:"request_id,omitempty"`
+	Source      string    `json:"source,omitempty"` // "client" for ReportHandler reports
+}
+
+// NewRejectionEvent builds a RejectionEvent from a rejected request and its
+// reason, typically from within Config.OnReject. The client IP is resolved
+// using TrustedProxies, the request ID is read from RequestIDHeader and the
+// fields listed in RedactEventFields are blanked.
+//
+// Params:
+// - r: the rejected request.
+// - err: the rejection reason passed to OnReject.
+//
+// Returns:
+// - the populated RejectionEvent, timestamped now.
+func (p *Protector) NewRejectionEvent(r *http.Request, err error) RejectionEvent {
+	e := RejectionEvent{
+		Time:      time.Now(),
+		Reason:    reasonCode(err),
+		Message:   err.Error(),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		ClientIP:  clientIP(r, p.cfg.TrustedProxies),
+		Origin:    r.Header.Get("Origin"),
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		RequestID: r.Header.Get(p.cfg.RequestIDHeader),
+	}
+	if u, err := url.Parse(e.Referer); err == nil {
+		e.RefererHost = u.Host
+	}
+	return e.Redact(p.cfg.RedactEventFields...)
+}
+
+// Redact returns a copy of e with the named fields blanked. Names are the
+// JSON keys listed in redactableFields. Redacting "referer" keeps
+// "referer_host", which rarely carries sensitive data. Redacting "origin",
+// "referer" or "referer_host" also replaces Message, which may quote the
+// header (e.g. an OriginError), with the bare message of the reason code.
+// Unknown names are ignored; Config.Validate rejects them in
+// RedactEventFields.
+//
+// Params:
+// - fields: JSON names of the fields to blank.
+//
+// Returns:
+// - the redacted copy.
+func (e RejectionEvent) Redact(fields ...string) RejectionEvent {
+	for _, f := range fields {
+		switch f {
+		case "path":
+			e.Path = ""
+		case "client_ip":
+			e.ClientIP = ""
+		case "origin":
+			e.Origin = ""
+			e.Message = reasonMessage(e.Reason)
+		case "referer":
+			e.Referer = ""
+			e.Message = reasonMessage(e.Reason)
+		case "referer_host":
+			e.RefererHost = ""
+			e.Message = reasonMessage(e.Reason)
+		case "user_agent":
+			e.UserAgent = ""
+		case "request_id":
+			e.RequestID = ""
+		}
+	}
+	return e
+}
+
+// redactableFields lists the JSON names accepted by RejectionEvent.Redact.
+var redactableFields = []string{"path", "client_ip", "origin", "referer", "referer_host", "user_agent", "request_id"}
+
+// validateRedactFields rejects RedactEventFields entries Redact does not
+// know, so a typo fails at startup instead of leaking the field.
+//
+// Params:
+// - cfg: configuration to check.
+//
+// Returns:
+// - nil, or an error naming the unknown field and the valid ones.
+func validateRedactFields(cfg Config) error {
+	for _, f := range cfg.RedactEventFields {
+		if !slices.Contains(redactableFields, f) {
+			return fmt.Errorf("csrf: unknown RedactEventFields entry %q (valid: %s)", f, strings.Join(redactableFields, ", "))
+		}
+	}
+	return nil
+}
+
+// reasonMessage returns the message of the sentinel behind a reason code,
+// free of request data.
+//
+// Params:
+// - code: the short reason code.
+//
+// Returns:
+// - the sentinel's message, or "" for unknown codes.
+func reasonMessage(code string) string {
+	for _, rc := range reasonCodes {
+		if rc.code == code {
+			return rc.err.Error()
+		}
+	}
+	return ""
+}
+
+// ReasonCode returns the short, stable code of an error passed to OnReject
+// or ErrorHandler, the Reason of its RejectionEvent (e.g. "bad_token",
+// "bad_origin", "rate_limited", "unavailable").
+//
+// Params:
+// - err: rejection reason or failure.
+//
+// Returns:
+// - the code, or "other" for errors not raised by the middleware.
+func ReasonCode(err error) string {
+	return reasonCode(err)
+}
+
+// reasonCode maps a rejection error to a short, stable code.
+//
+// Params:
+// - err: rejection reason.
+//
+// Returns:
+// - the code (e.g. "bad_token"), or "other" for unknown errors.
+func reasonCode(err error) string {
+	for _, rc := range reasonCodes {
+		if errors.Is(err, rc.err) {
+			return rc.code
+		}
+	}
+	return "other"
+}
+
+// reasonCodes maps rejection reasons to their short codes.
+var reasonCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrMissingHeaderToken, "missing_header_token"},
+	{ErrMissingToken, "missing_token"},
+	{ErrMalformedToken, "malformed_token"},
+	{ErrTokenMismatch, "bad_token"},
+	{ErrTokenExpired, "token_expired"},
+	{ErrTokenStale, "token_stale"},
+	{ErrDeviceMismatch, "device_mismatch"},
+	{ErrNoOrigin, "no_origin"},
+	{ErrBadOrigin, "bad_origin"},
+	{ErrBadReferer, "bad_referer"},
+	{ErrRateLimited, "rate_limited"},
+	{ErrBlocked, "blocked"},
+	{ErrHeaderTooLarge, "header_too_large"},
+	{ErrBodyTooLarge, "body_too_large"},
+	{ErrCrossSiteTokenRequest, "cross_site_token_request"},
+	{ErrBadNonce, "bad_nonce"},
+	{ErrNoSession, "no_session"},
+	{ErrCookieFailed, "cookie_failed"},
+	{ErrRotateFailed, "rotate_failed"},
+	{ErrUnavailable, "unavailable"},
+	{ErrPanic, "panic"},
+}
+
+// Identification of this package in CEF/LEEF headers.
+const (
+	eventVendor  = "JeanGrijp"
+	eventProduct = "go-csrf"
+	eventVersion = "1.0"
+)
+
+// CEF formats e as an ArcSight Common Event Format record (without syslog
+// prefix). Rate limiting and blocking are reported with a higher severity.
+//
+// Returns:
+// - the CEF line.
+func (e RejectionEvent) CEF() string {
+	severity := "5"
+	if e.Reason == "rate_limited" || e.Reason == "blocked" {
+		severity = "7"
+	}
+	var b strings.Builder
+	b.WriteString("CEF:0|")
+	for _, f := range []string{eventVendor, eventProduct, eventVersion, e.Reason, "CSRF request rejected", severity} {
+		b.WriteString(cefHeaderEscaper.Replace(f))
+		b.WriteByte('|')
+	}
+	ext := [][2]string{
+		{"rt", strconv.FormatInt(e.Time.UnixMilli(), 10)},
+		{"src", e.ClientIP},
+		{"requestMethod", e.Method},
+		{"request", e.Path},
+		{"requestClientApplication", e.UserAgent},
+		{"reason", e.Message},
+		{"cs1Label", "origin"},
+		{"cs1", e.Origin},
+		{"cs2Label", "referer"},
+		{"cs2", e.Referer},
+		{"cs3Label", "requestId"},
+		{"cs3", e.RequestID},
+	}
+	first := true
+	for _, kv := range ext {
+		if kv[1] == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		b.WriteString(kv[0])
+		b.WriteByte('=')
+		b.WriteString(cefExtEscaper.Replace(kv[1]))
+	}
+	return b.String()
+}
+
+// LEEF formats e as an IBM QRadar Log Event Extended Format 1.0 record with
+// tab-separated attributes.
+//
+// Returns:
+// - the LEEF line.
+func (e RejectionEvent) LEEF() string {
+	var b strings.Builder
+	b.WriteString("LEEF:1.0|")
+	for _, f := range []string{eventVendor, eventProduct, eventVersion, e.Reason} {
+		b.WriteString(leefEscaper.Replace(f))
+		b.WriteByte('|')
+	}
+	attrs := [][2]string{
+		{"devTime", e.Time.UTC().Format("Jan 02 2006 15:04:05.000")},
+		{"devTimeFormat", "MMM dd yyyy HH:mm:ss.SSS"},
+		{"src", e.ClientIP},
+		{"method", e.Method},
+		{"url", e.Path},
+		{"reason", e.Message},
+		{"origin", e.Origin},
+		{"referer", e.Referer},
+		{"userAgent", e.UserAgent},
+		{"requestId", e.RequestID},
+	}
+	first := true
+	for _, kv := range attrs {
+		if kv[1] == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte('\t')
+		}
+		first = false
+		b.WriteString(kv[0])
+		b.WriteByte('=')
+		b.WriteString(leefEscaper.Replace(kv[1]))
+	}
+	return b.String()
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtEscaper    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefEscaper      = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ", "|", `\|`)
+)
@@ -0,0 +1,94 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Rejection events carry the reason and format as escaped CEF/LEEF records.
+func TestRejectionEventFormats(t *testing.T) {
+	var ev RejectionEvent
+	var p *Protector
+	p = New(Config{OnReject: func(r *http.Request, err error) {
+		ev = p.NewRejectionEvent(r, err)
+	}})
+
+	req := httptest.NewRequest(http.MethodPost, "/pay", nil)
+	req.RemoteAddr = "198.51.100.4:5555"
+	req.Header.Set("User-Agent", "evil=bot|1")
+	p.Protect(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	if ev.Reason != "missing_token" || ev.ClientIP != "198.51.100.4" || ev.Path != "/pay" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+
+	ev.Time = time.UnixMilli(1700000000000)
+	cef := ev.CEF()
+	if !strings.HasPrefix(cef, "CEF:0|JeanGrijp|go-csrf|1.0|missing_token|CSRF request rejected|5|rt=1700000000000 ") {
+		t.Fatalf("unexpected CEF header: %s", cef)
+	}
+	if !strings.Contains(cef, `requestClientApplication=evil\=bot|1`) {
+		t.Fatalf("CEF extension not escaped: %s", cef)
+	}
+
+	leef := ev.LEEF()
+	if !strings.HasPrefix(leef, "LEEF:1.0|JeanGrijp|go-csrf|1.0|missing_token|devTime=") {
+		t.Fatalf("unexpected LEEF header: %s", leef)
+	}
+	if !strings.Contains(leef, "\tsrc=198.51.100.4\t") {
+		t.Fatalf("LEEF attributes not tab-separated: %q", leef)
+	}
+}
+
+// OnRejectEvent receives the event with the request ID, referer host and
+// configured redactions applied.
+func TestOnRejectEventRedaction(t *testing.T) {
+	var ev RejectionEvent
+	p := New(Config{
+		RedactEventFields: []string{"referer", "user_agent"},
+		OnRejectEvent:     func(e RejectionEvent) { ev = e },
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/pay", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	req.Header.Set("Referer", "https://app.example.com/account?secret=1")
+	req.Header.Set("User-Agent", "browser")
+	p.Protect(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	if ev.Reason != "missing_token" || ev.RequestID != "req-42" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	if ev.Referer != "" || ev.UserAgent != "" || ev.RefererHost != "app.example.com" {
+		t.Fatalf("expected referer/user agent redacted and host kept: %+v", ev)
+	}
+	if !strings.Contains(ev.CEF(), "cs3=req-42") {
+		t.Fatalf("expected request ID in CEF: %s", ev.CEF())
+	}
+}
+
+// Redacting the origin scrubs it from Message too, and unknown field names
+// fail Validate.
+func TestRedactOriginMessage(t *testing.T) {
+	p := New(Config{RedactEventFields: []string{"origin"}})
+
+	req := httptest.NewRequest(http.MethodPost, "/pay", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	ev := p.NewRejectionEvent(req, &OriginError{Reason: ErrBadOrigin, Header: "Origin", Got: "evil.example"})
+
+	if ev.Reason != "bad_origin" || ev.Origin != "" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	if strings.Contains(ev.Message, "evil.example") || ev.Message != ErrBadOrigin.Error() {
+		t.Fatalf("origin leaked through Message: %q", ev.Message)
+	}
+
+	if err := (Config{RedactEventFields: []string{"clientip"}}).Validate(); err == nil {
+		t.Fatal("expected Validate to reject an unknown field")
+	}
+	if got := ev.Redact("clientip"); got != ev {
+		t.Fatalf("unknown field changed the event: %+v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package csrf
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// fileOrigin is the Origin sent by pages loaded from the local file system,
+// e.g. by Electron apps.
+const fileOrigin = "file://"
+
+// extensionSchemes are the origin schemes of browser extension pages.
+var extensionSchemes = []string{"chrome-extension", "moz-extension", "safari-web-extension"}
+
+// webScheme reports whether scheme is one whose origins the host comparison
+// applies to. Other schemes (extensions, file, custom app protocols) carry
+// IDs or nothing in place of a host and are only accepted through
+// AllowedExtensionIDs and AllowFileOrigin.
+func webScheme(scheme string) bool {
+	return strings.EqualFold(scheme, "https") || strings.EqualFold(scheme, "http")
+}
+
+// appOriginMatch returns the entry accepting value as the origin of an
+// allowed browser extension ("extension:" + the AllowedExtensionIDs entry)
+// or, with AllowFileOrigin, the file origin ("file://").
+//
+// Params:
+// - value: Origin or Referer header value.
+//
+// Returns:
+// - the matched entry, or "" if value is not accepted by the extension and
+// file policies.
+func (p *Protector) appOriginMatch(value string) string {
+	if value == fileOrigin || strings.HasPrefix(value, fileOrigin+"/") {
+		if p.cfg.AllowFileOrigin {
+			return fileOrigin
+		}
+		return ""
+	}
+	if len(p.cfg.AllowedExtensionIDs) == 0 {
+		return ""
+	}
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok || !slices.Contains(extensionSchemes, strings.ToLower(scheme)) {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	if id == "" {
+		return ""
+	}
+	for _, allowed := range p.cfg.AllowedExtensionIDs {
+		if strings.EqualFold(allowed, id) {
+			return extensionEntry(allowed)
+		}
+	}
+	return ""
+}
+
+// extensionEntry names the allowlist entry of an extension ID.
+func extensionEntry(id string) string {
+	return "extension:" + id
+}
+
+// validateExtensionIDs rejects AllowedExtensionIDs entries that cannot be
+// an extension ID (empty, or with characters other than letters, digits
+// and "-"), typically full origins pasted by mistake.
+func validateExtensionIDs(cfg Config) error {
+	for _, id := range cfg.AllowedExtensionIDs {
+		if id == "" || strings.Trim(id, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-") != "" {
+			return fmt.Errorf("csrf: invalid extension ID %q (use the bare ID, not the origin)", id)
+		}
+	}
+	return nil
+}
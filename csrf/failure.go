@@ -0,0 +1,78 @@
+package csrf
+
+import (
+	"context"
+	"net/http"
+)
+
+const failureKey ctxKey = "csrf_failure_ctx"
+
+// failureRecord holds the reason a request failed the CSRF checks.
+type failureRecord struct {
+	err error
+}
+
+// TrackFailure returns a shallow copy of r whose context records the reason
+// the middleware rejects (or, in report-only mode, reports) it with, so
+// logging middleware wrapping Protect can read it once the handler
+// returned:
+//
+//	func logging(next http.Handler) http.Handler {
+//		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//			r = csrf.TrackFailure(r)
+//			next.ServeHTTP(w, r)
+//			if err := csrf.FailureReasonFromContext(r.Context()); err != nil {
+//				slog.Info("csrf failure", "reason", csrf.ReasonCode(err))
+//			}
+//		})
+//	}
+//	handler := logging(p.Protect(app))
+//
+// Params:
+// - r: incoming request.
+//
+// Returns:
+// - r with a failure record in its context.
+func TrackFailure(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(failureKey).(*failureRecord); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), failureKey, &failureRecord{}))
+}
+
+// FailureReasonFromContext returns the reason the request failed the CSRF
+// checks, for errors.Is against the exported sentinels (ErrMissingToken,
+// ErrTokenMismatch, ErrBadOrigin, ...) or ReasonCode. It is set in the
+// context of the requests passed to ErrorHandler, Challenge and, in
+// report-only mode, to the protected handler; TrackFailure makes it visible
+// to outer middleware too.
+//
+// Params:
+// - ctx: request context.
+//
+// Returns:
+// - the failure, or nil if the request did not fail (or was not seen by
+// the middleware).
+func FailureReasonFromContext(ctx context.Context) error {
+	if rec, ok := ctx.Value(failureKey).(*failureRecord); ok {
+		return rec.err
+	}
+	return nil
+}
+
+// withFailure records err as the failure of r, in the record installed by
+// TrackFailure when there is one.
+//
+// Params:
+// - r: the failing request.
+// - err: the reason.
+//
+// Returns:
+// - the request to hand to hooks and handlers.
+func withFailure(r *http.Request, err error) *http.Request {
+	if rec, ok := r.Context().Value(failureKey).(*failureRecord); ok {
+		rec.err = err
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), failureKey, &failureRecord{err: err}))
+}
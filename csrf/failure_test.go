@@ -0,0 +1,50 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFailureReasonFromContext(t *testing.T) {
+	var inHandler error
+	p := New(Config{ErrorHandler: func(w http.ResponseWriter, r *http.Request, status int, err error) {
+		inHandler = FailureReasonFromContext(r.Context())
+		w.WriteHeader(status)
+	}})
+	var outer error
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = TrackFailure(r)
+		p.Protect(appHandler(p)).ServeHTTP(w, r)
+		outer = FailureReasonFromContext(r.Context())
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/submit", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", rec.Code)
+	}
+	if !errors.Is(inHandler, ErrMissingToken) || !errors.Is(outer, ErrMissingToken) {
+		t.Fatalf("handler saw %v, outer saw %v; want ErrMissingToken", inHandler, outer)
+	}
+
+	outer = errors.New("unset")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/submit", nil))
+	if outer != nil {
+		t.Fatalf("safe request failure = %v, want nil", outer)
+	}
+}
+
+func TestFailureReasonInReportOnly(t *testing.T) {
+	p := New(Config{ReportOnly: true})
+	var got error
+	h := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FailureReasonFromContext(r.Context())
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	if !errors.Is(got, ErrMissingToken) {
+		t.Fatalf("got %v, want ErrMissingToken", got)
+	}
+}
@@ -0,0 +1,42 @@
+package csrf
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+)
+
+// FaultInjector forces CSRF failures at configured rates, so teams can verify
+// their error handling, alerting and client retry logic under failure
+// conditions (chaos testing). It must never be enabled in production.
+type FaultInjector struct {
+	// TokenFailureRate is the fraction (0..1) of token generations that fail,
+	// as if the system's randomness source were unavailable.
+	TokenFailureRate float64
+
+	// RejectRate is the fraction (0..1) of otherwise valid unsafe requests
+	// rejected as carrying a bad token.
+	RejectRate float64
+}
+
+var (
+	errInjectedTokenFailure = errors.New("csrf: injected token generation failure")
+	errInjectedReject       = fmt.Errorf("%w (injected fault)", ErrTokenMismatch)
+)
+
+// failToken reports whether the next token generation must fail. A nil
+// FaultInjector never fires.
+func (f *FaultInjector) failToken() bool {
+	return f != nil && hit(f.TokenFailureRate)
+}
+
+// reject reports whether the current valid request must be rejected. A nil
+// FaultInjector never fires.
+func (f *FaultInjector) reject() bool {
+	return f != nil && hit(f.RejectRate)
+}
+
+// hit reports whether an event with the given rate fires.
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
@@ -0,0 +1,40 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Injected faults surface as token generation errors and bad-token
+// rejections of otherwise valid requests.
+func TestFaultInjector(t *testing.T) {
+	var reason string
+	p := New(Config{
+		TokenBytes:    16,
+		FaultInjector: &FaultInjector{TokenFailureRate: 1, RejectRate: 1},
+		OnReject:      func(_ *http.Request, err error) { reason = reasonCode(err) },
+	})
+	app := appHandler(p)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/submit", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected injected token failure, got %d", rec.Code)
+	}
+
+	token, _ := newToken(16)
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+	req.Header.Set("X-CSRF-Token", token)
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || reason != "bad_token" || !strings.Contains(rec.Body.String(), "injected") {
+		t.Fatalf("expected injected rejection, got %d %q (%s)", rec.Code, rec.Body.String(), reason)
+	}
+
+	if !strings.Contains(strings.Join(p.SelfCheck(), "\n"), "FaultInjector") {
+		t.Fatal("expected SelfCheck to warn about the injector")
+	}
+}
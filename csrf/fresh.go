@@ -0,0 +1,38 @@
+package csrf
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequireFresh wraps next so that unsafe requests are accepted only with a
+// token issued within maxAge. Stale tokens (and tokens of unknown age) are
+// rejected with 403 "CSRF token stale", reason code "token_stale", which a
+// frontend can react to by obtaining a new token (e.g., via RotateHandler or
+// a page reload) and retrying. Safe requests pass through unchanged.
+//
+// RequireFresh does not validate the token itself: mount the result inside
+// Protect. It relies on the issuance timestamp cookie and panics if
+// TrackIssuedAt is off, like New does for invalid configurations.
+//
+// Params:
+// - next: handler performing the sensitive action.
+// - maxAge: maximum accepted token age (must be positive).
+//
+// Returns:
+// - http.Handler enforcing the freshness requirement.
+func (p *Protector) RequireFresh(next http.Handler, maxAge time.Duration) http.Handler {
+	if !p.cfg.TrackIssuedAt {
+		panic("csrf: RequireFresh needs TrackIssuedAt")
+	}
+	if maxAge <= 0 {
+		panic("csrf: RequireFresh needs a positive maxAge")
+	}
+	return p.guard(next, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		if unsafeMethods[r.Method] && p.tokenStale(r, maxAge, true) {
+			p.reject(w, r, http.StatusForbidden, ErrTokenStale)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
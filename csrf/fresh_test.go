@@ -0,0 +1,57 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RequireFresh turns away unsafe requests carrying an old token with the
+// token_stale reason, and lets fresh ones and safe requests through.
+func TestRequireFresh(t *testing.T) {
+	var reason string
+	p := New(Config{TokenBytes: 16, TrackIssuedAt: true, OnReject: func(r *http.Request, err error) {
+		reason = reasonCode(err)
+	}})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	app := p.Protect(p.RequireFresh(ok, time.Minute))
+
+	tok := &http.Cookie{Name: "csrf_token", Value: strings.Repeat("A", 22)}
+	send := func(method string, issued time.Time) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/delete", nil)
+		req.AddCookie(tok)
+		req.AddCookie(&http.Cookie{Name: "csrf_token_iat", Value: strconv.FormatInt(issued.Unix(), 10)})
+		req.Header.Set("X-CSRF-Token", tok.Value)
+		app.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send(http.MethodPost, time.Now()); rec.Code != http.StatusOK {
+		t.Fatalf("expected fresh token to pass, got %d", rec.Code)
+	}
+	rec := send(http.MethodPost, time.Now().Add(-time.Hour))
+	if rec.Code != http.StatusForbidden || strings.TrimSpace(rec.Body.String()) != "CSRF token stale" {
+		t.Fatalf("expected stale rejection, got %d %q", rec.Code, rec.Body.String())
+	}
+	if reason != "token_stale" {
+		t.Fatalf("expected reason token_stale, got %q", reason)
+	}
+	if rec := send(http.MethodGet, time.Now().Add(-time.Hour)); rec.Code != http.StatusOK {
+		t.Fatalf("expected safe request to pass, got %d", rec.Code)
+	}
+}
+
+func TestRequireFreshNeedsIssuedAt(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic without TrackIssuedAt")
+		}
+	}()
+	New(Config{}).RequireFresh(http.NotFoundHandler(), time.Minute)
+}
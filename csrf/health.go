@@ -0,0 +1,123 @@
+package csrf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// healthKey is the client key used to probe stores in Healthy.
+const healthKey = "csrf-health-probe"
+
+// Pinger is implemented by stores (BlockStore, Limiter, TokenStore,
+// SessionTokenStore) that can check their connectivity cheaply. Healthy calls Ping when available and otherwise
+// probes the store with a read-only lookup.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Healthy reports whether p can serve traffic: the system random source
+// works, the signing keys (when used) include a signing key and, for a
+// SecretProvider, still pass validation, and the Blocklist, FailureLimiter,
+// TokenStore and SessionTokenStore stores answer within StoreTimeout. Wire
+// it into readiness probes so an instance with a broken store does not take
+// traffic.
+//
+// Params:
+// - ctx: bounds the store checks.
+//
+// Returns:
+// - nil when healthy; otherwise every failed check joined with errors.Join.
+func (p *Protector) Healthy(ctx context.Context) error {
+	var errs []error
+	if _, err := newToken(p.cfg.TokenBytes); err != nil {
+		errs = append(errs, fmt.Errorf("csrf: entropy unavailable: %w", err))
+	}
+	if err := p.keysHealthy(); err != nil {
+		errs = append(errs, err)
+	}
+	if s := p.cfg.Blocklist; s != nil {
+		errs = append(errs, p.probeStore(ctx, "blocklist", s, func(ctx context.Context) error {
+			_, err := s.Blocked(ctx, healthKey)
+			return err
+		}))
+	}
+	if l := p.cfg.FailureLimiter; l != nil {
+		errs = append(errs, p.probeStore(ctx, "limiter", l, func(ctx context.Context) error {
+			_, err := l.Allow(ctx, healthKey)
+			return err
+		}))
+	}
+	if s := p.cfg.TokenStore; s != nil {
+		errs = append(errs, p.probeStore(ctx, "token store", s, func(ctx context.Context) error {
+			_, err := s.Take(ctx, healthKey)
+			return err
+		}))
+	}
+	if s := p.cfg.SessionTokenStore; s != nil {
+		errs = append(errs, p.probeStore(ctx, "session token store", s, func(ctx context.Context) error {
+			_, _, err := s.Get(ctx, healthKey)
+			return err
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// keysHealthy checks the current signing key ring.
+func (p *Protector) keysHealthy() error {
+	if sp := p.cfg.SecretProvider; sp != nil {
+		if err := validateKeys(secretSnapshot(sp)); err != nil {
+			return fmt.Errorf("csrf: secret provider: %w", err)
+		}
+	}
+	if kr := p.keys.ring.Load(); kr != nil && kr.signer == nil {
+		return errors.New("csrf: no signing key in the key ring")
+	}
+	return nil
+}
+
+// probeStore checks one store with Ping, or with lookup when the store is
+// not a Pinger, bounded by StoreTimeout.
+//
+// Params:
+// - ctx: caller context.
+// - what: the store name used in the error (e.g. "blocklist").
+// - store: the store.
+// - lookup: read-only fallback probe.
+//
+// Returns:
+// - nil when the store answered; otherwise the wrapped store error.
+func (p *Protector) probeStore(ctx context.Context, what string, store any, lookup func(context.Context) error) error {
+	if p.cfg.StoreTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.StoreTimeout)
+		defer cancel()
+	}
+	var err error
+	if pg, ok := store.(Pinger); ok {
+		err = pg.Ping(ctx)
+	} else {
+		err = lookup(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("csrf: %s unavailable: %w", what, err)
+	}
+	return nil
+}
+
+// HealthHandler returns a readiness probe handler: 200 "ok" when Healthy
+// returns nil, 503 with the failures otherwise.
+//
+// Returns:
+// - http.Handler for a readiness route.
+func (p *Protector) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if err := p.Healthy(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+}
@@ -0,0 +1,44 @@
+package csrf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pingBlockStore is a MemoryBlockStore whose Ping returns err.
+type pingBlockStore struct {
+	*MemoryBlockStore
+	err error
+}
+
+func (s pingBlockStore) Ping(context.Context) error { return s.err }
+
+func TestHealthy(t *testing.T) {
+	p := New(Config{TokenBytes: 16, Blocklist: NewMemoryBlockStore(), FailureLimiter: NewMemoryLimiter(5, time.Minute)})
+	if err := p.Healthy(context.Background()); err != nil {
+		t.Fatalf("expected healthy, got %v", err)
+	}
+
+	down := errors.New("connection refused")
+	p = New(Config{
+		TokenBytes:     16,
+		Blocklist:      pingBlockStore{NewMemoryBlockStore(), down},
+		FailureLimiter: hangingLimiter{},
+		StoreTimeout:   10 * time.Millisecond,
+	})
+	err := p.Healthy(context.Background())
+	if !errors.Is(err, down) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected both store failures, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "blocklist unavailable") {
+		t.Fatalf("got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,129 @@
+package csrf
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+)
+
+// errNoProtector is returned by helpers called outside of Protect.
+var errNoProtector = errors.New("csrf: no Protector in request context")
+
+// TemplateField returns a hidden form input carrying the request's token,
+// named after the first form field of the Protector that handled the request
+// (the matching Rule's FormFields, if set, otherwise Config.FormFields):
+//
+//	<form method="post">{{ .CSRFField }} ...</form>
+//
+// It returns an empty value when the request did not pass through Protect.
+//
+// Params:
+// - r: current request.
+//
+// Returns:
+// - the escaped <input type="hidden"> element as template.HTML.
+func TemplateField(r *http.Request) template.HTML {
+	p, ok := ProtectorFromContext(r.Context())
+	if !ok {
+		return ""
+	}
+	tok, _ := TokenFromContext(r.Context())
+	_, fields := p.tokenNames(p.ruleFor(r))
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(fields[0]) +
+		`" value="` + template.HTMLEscapeString(tok) + `">`)
+}
+
+// RotateToken rotates the token using the Protector that handled r. See
+// Protector.RotateToken.
+//
+// Params:
+// - w: response writer to set the new cookie on.
+// - r: current request (must have passed through Protect).
+//
+// Returns:
+// - the new token, or an error if no Protector is in the context or generation fails.
+func RotateToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	p, ok := ProtectorFromContext(r.Context())
+	if !ok {
+		return "", errNoProtector
+	}
+	return p.RotateToken(w, r)
+}
+
+// RefreshContextToken returns a shallow copy of r whose context carries
+// token, so TokenFromContext and TemplateField see a token rotated inside
+// the handler (RotateToken, RenewSession) instead of the one stored by the
+// middleware:
+//
+//	tok, err := csrf.RotateToken(w, r)
+//	r = csrf.RefreshContextToken(r, tok)
+//	tmpl.Execute(w, map[string]any{"CSRFField": csrf.TemplateField(r)})
+//
+// The Protector stored in the context is kept; r is returned unchanged when
+// it did not pass through Protect.
+//
+// Params:
+// - r: current request.
+// - token: the new token.
+//
+// Returns:
+// - the request to use for the rest of the handler.
+func RefreshContextToken(r *http.Request, token string) *http.Request {
+	p, ok := ProtectorFromContext(r.Context())
+	if !ok {
+		return r
+	}
+	return r.WithContext(contextWithToken(r.Context(), token, p))
+}
+
+// errNoToken is returned by PropagateToken when src carries no token.
+var errNoToken = errors.New("csrf: no token to propagate")
+
+// PropagateToken copies the CSRF credentials of src, a request that passed
+// through Protect, onto dst, a server-side subrequest to a sibling service
+// running this middleware with the same cookie settings (e.g., an internal
+// fetch or a request built for httputil.ReverseProxy): the token cookie
+// (and issuance cookie) plus the token header, and the Origin header when
+// src has one. The sibling then validates the subrequest like the browser
+// request it stems from:
+//
+//	out, _ := http.NewRequestWithContext(r.Context(), http.MethodPost, billingURL, body)
+//	if err := csrf.PropagateToken(out, r); err != nil { ... }
+//
+// Params:
+// - dst: outbound request.
+// - src: inbound request.
+//
+// Returns:
+// - nil, or an error when src did not pass through Protect or has no token.
+func PropagateToken(dst, src *http.Request) error {
+	p, ok := ProtectorFromContext(src.Context())
+	if !ok {
+		return errNoProtector
+	}
+	tok, ok := TokenFromContext(src.Context())
+	if !ok {
+		if tok, ok = p.cookieToken(src); !ok {
+			return errNoToken
+		}
+	}
+	name := p.cookieName(src)
+	if c, err := dst.Cookie(name); err != nil || c.Value != tok {
+		dst.AddCookie(&http.Cookie{Name: name, Value: tok})
+	}
+	for _, companion := range []string{name + issuedAtSuffix, name + deviceSuffix, p.cfg.DeviceCookie} {
+		if companion == "" {
+			continue
+		}
+		if c, err := src.Cookie(companion); err == nil {
+			if _, err := dst.Cookie(companion); err != nil {
+				dst.AddCookie(&http.Cookie{Name: companion, Value: c.Value})
+			}
+		}
+	}
+	dst.Header.Set(p.cfg.HeaderName, tok)
+	if o := src.Header.Get("Origin"); o != "" {
+		dst.Header.Set("Origin", o)
+	}
+	return nil
+}
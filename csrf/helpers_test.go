@@ -0,0 +1,75 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Helpers resolve the Protector from the request context.
+func TestContextHelpers(t *testing.T) {
+	p := New(Config{FormField: "_csrf", TokenBytes: 16})
+
+	var field string
+	var rotated, tok, refreshed string
+	h := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, ok := ProtectorFromContext(r.Context()); !ok || got != p {
+			t.Errorf("expected Protector in context")
+		}
+		tok, _ = TokenFromContext(r.Context())
+		field = string(TemplateField(r))
+		rotated, _ = RotateToken(w, r)
+		refreshed = string(TemplateField(RefreshContextToken(r, rotated)))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if field != `<input type="hidden" name="_csrf" value="`+tok+`">` {
+		t.Fatalf("unexpected field %q", field)
+	}
+	if rotated == "" || rotated == tok {
+		t.Fatalf("expected rotated token, got %q", rotated)
+	}
+	if refreshed != `<input type="hidden" name="_csrf" value="`+rotated+`">` {
+		t.Fatalf("expected the refreshed context to carry the rotated token, got %q", refreshed)
+	}
+
+	bare := httptest.NewRequest(http.MethodGet, "/", nil)
+	if RefreshContextToken(bare, "x") != bare {
+		t.Fatalf("expected the request unchanged outside Protect")
+	}
+	if TemplateField(bare) != "" {
+		t.Fatalf("expected empty field outside Protect")
+	}
+	if _, err := RotateToken(httptest.NewRecorder(), bare); err == nil || !strings.Contains(err.Error(), "no Protector") {
+		t.Fatalf("expected error outside Protect, got %v", err)
+	}
+}
+
+// A subrequest built from a validated request passes the sibling's check.
+func TestPropagateToken(t *testing.T) {
+	sibling := appHandler(New(Config{TokenBytes: 16}))
+	var code int
+	edge := New(Config{TokenBytes: 16}).Protect(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		out := httptest.NewRequest(http.MethodPost, "http://billing.internal/submit", nil)
+		if err := PropagateToken(out, r); err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		sibling.ServeHTTP(rec, out)
+		code = rec.Code
+	}))
+
+	tok, _ := newToken(16)
+	req := httptest.NewRequest(http.MethodPost, "/checkout", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tok})
+	req.Header.Set("X-CSRF-Token", tok)
+	edge.ServeHTTP(httptest.NewRecorder(), req)
+	if code != http.StatusOK {
+		t.Fatalf("subrequest got %d", code)
+	}
+
+	if err := PropagateToken(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRequest(http.MethodPost, "/", nil)); err != errNoProtector {
+		t.Fatalf("expected errNoProtector, got %v", err)
+	}
+}
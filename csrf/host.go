@@ -0,0 +1,76 @@
+package csrf
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// requestHost returns the host the request was addressed to, as seen by
+// the client: HostResolver's answer when set and not empty, r.Host
+// otherwise.
+//
+// Params:
+// - r: incoming request.
+//
+// Returns:
+// - host, optionally with ":port".
+func (p *Protector) requestHost(r *http.Request) string {
+	if resolve := p.cfg.HostResolver; resolve != nil {
+		if h := resolve(r); h != "" {
+			return h
+		}
+	}
+	return r.Host
+}
+
+// ForwardedHostResolver returns a Config.HostResolver for apps behind
+// reverse proxies that rewrite the Host header: when the direct peer
+// belongs to trusted, the host is taken from the RFC 7239 Forwarded header
+// ("host=" of its last element) or else from X-Forwarded-Host (its last
+// value), i.e. from what the nearest proxy recorded. Requests from other
+// peers, or without either header, resolve to r.Host.
+//
+// Params:
+// - trusted: networks of reverse proxies allowed to set the headers
+// (usually Config.TrustedProxies).
+//
+// Returns:
+// - the resolver.
+func ForwardedHostResolver(trusted []net.IPNet) func(*http.Request) string {
+	return func(r *http.Request) string {
+		peer, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			peer = r.RemoteAddr
+		}
+		if !inNetworks(net.ParseIP(peer), trusted) {
+			return r.Host
+		}
+		if h := forwardedHost(r.Header.Values("Forwarded")); h != "" {
+			return h
+		}
+		if vs := r.Header.Values("X-Forwarded-Host"); len(vs) > 0 {
+			hops := strings.Split(vs[len(vs)-1], ",")
+			if h := strings.TrimSpace(hops[len(hops)-1]); h != "" {
+				return h
+			}
+		}
+		return r.Host
+	}
+}
+
+// forwardedHost returns the host parameter of the last element of the
+// Forwarded header values, unquoted, or "" if it has none.
+func forwardedHost(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	elems := strings.Split(values[len(values)-1], ",")
+	for _, pair := range strings.Split(elems[len(elems)-1], ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(k, "host") {
+			return strings.Trim(v, `"`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package csrf
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// The origin check baseline follows HostResolver when AllowedOrigins is
+// empty.
+func TestHostResolverOriginBaseline(t *testing.T) {
+	_, proxy, _ := net.ParseCIDR("10.0.0.0/8")
+	p := New(Config{EnforceOriginCheck: true, HostResolver: ForwardedHostResolver([]net.IPNet{*proxy})})
+
+	check := func(remote, forwarded string) error {
+		req := httptest.NewRequest(http.MethodPost, "http://backend.internal/submit", nil)
+		req.RemoteAddr = remote + ":1234"
+		req.Header.Set("Origin", "https://app.example.com")
+		if forwarded != "" {
+			req.Header.Set("Forwarded", forwarded)
+		}
+		return p.validateOriginOrReferer(req)
+	}
+	if err := check("10.1.2.3", `for=1.2.3.4;host="app.example.com";proto=https`); err != nil {
+		t.Fatalf("trusted proxy: %v", err)
+	}
+	if err := check("203.0.113.9", "host=app.example.com"); err == nil {
+		t.Fatal("Forwarded from an untrusted peer was honored")
+	}
+	if err := check("10.1.2.3", ""); err == nil {
+		t.Fatal("expected the Host header fallback to reject the origin")
+	}
+}
+
+func TestForwardedHostResolver(t *testing.T) {
+	_, proxy, _ := net.ParseCIDR("10.0.0.0/8")
+	resolve := ForwardedHostResolver([]net.IPNet{*proxy})
+	cases := []struct {
+		forwarded, xfh, want string
+	}{
+		{"host=evil.example; proto=https, for=1.2.3.4;host=app.example.com", "", "app.example.com"},
+		{"for=1.2.3.4", "evil.example, app.example.com:8443", "app.example.com:8443"},
+		{"", "", "backend.internal"},
+	}
+	for i, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "http://backend.internal/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if tc.forwarded != "" {
+			req.Header.Set("Forwarded", tc.forwarded)
+		}
+		if tc.xfh != "" {
+			req.Header.Set("X-Forwarded-Host", tc.xfh)
+		}
+		if got := resolve(req); got != tc.want {
+			t.Errorf("case %d: got %q, want %q", i, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package csrf
+
+import (
+	"net/http"
+	"strings"
+)
+
+// probeAgents are User-Agent fragments (lowercase) of well-known health
+// checkers and uptime monitors.
+var probeAgents = []string{
+	"kube-probe",
+	"elb-healthchecker",
+	"googlehc",
+	"amazon-route53-health-check",
+	"googlestackdrivermonitoring",
+	"consul health check",
+	"blackbox exporter",
+	"pingdom",
+	"uptimerobot",
+	"statuscake",
+	"datadog",
+	"newrelicpinger",
+	"site24x7",
+	"better uptime",
+	"zabbix",
+}
+
+// DefaultIssuePredicate is used when Config.IssuePredicate is nil. It skips
+// token issuance for well-known health-check and monitoring user agents
+// (kube-probe, ELB-HealthChecker, GoogleHC, Pingdom, UptimeRobot, ...).
+//
+// Params:
+// - r: incoming safe request.
+//
+// Returns:
+// - false for synthetic traffic; true otherwise.
+func DefaultIssuePredicate(r *http.Request) bool {
+	ua := strings.ToLower(r.UserAgent())
+	if ua == "" {
+		return true
+	}
+	for _, probe := range probeAgents {
+		if strings.Contains(ua, probe) {
+			return false
+		}
+	}
+	return true
+}
+
+// shouldIssue reports whether a safe request may mint a new token.
+//
+// Params:
+// - r: incoming safe request.
+//
+// Returns:
+//   - false when DeferIssuance is set, when r is a HEAD, OPTIONS or
+//     preflight request excluded by the SkipCookieOn settings, when
+//     IssuePredicate (or DefaultIssuePredicate) declines r, or when
+//     IssueOnNavigationOnly is set and r is not an HTML navigation; true
+//     otherwise.
+func (p *Protector) shouldIssue(r *http.Request) bool {
+	switch {
+	case p.cfg.DeferIssuance,
+		r.Method == http.MethodHead && p.cfg.SkipCookieOnHEAD,
+		r.Method == http.MethodOptions && p.cfg.SkipCookieOnOPTIONS,
+		p.cfg.SkipCookieOnPreflight && isPreflight(r):
+		return false
+	}
+	pred := p.cfg.IssuePredicate
+	if pred == nil {
+		pred = DefaultIssuePredicate
+	}
+	if !pred(r) {
+		return false
+	}
+	return !p.cfg.IssueOnNavigationOnly || isNavigation(r)
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// isNavigation reports whether r looks like a browser loading an HTML page.
+// Fetch metadata is authoritative when present; older browsers are
+// recognized by an Accept header listing text/html.
+func isNavigation(r *http.Request) bool {
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		dest := r.Header.Get("Sec-Fetch-Dest")
+		return mode == "navigate" || dest == "document" || dest == "iframe"
+	}
+	for _, v := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(v, ",") {
+			mt, _, _ := strings.Cut(part, ";")
+			if strings.EqualFold(strings.TrimSpace(mt), "text/html") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// With IssueOnNavigationOnly, only HTML navigations mint a cookie.
+func TestIssueOnNavigationOnly(t *testing.T) {
+	p := New(Config{TokenBytes: 16, IssueOnNavigationOnly: true})
+	app := appHandler(p)
+
+	cases := []struct {
+		name   string
+		header map[string]string
+		issue  bool
+	}{
+		{"api", map[string]string{"Accept": "application/json"}, false},
+		{"probe", nil, false},
+		{"fetch", map[string]string{"Sec-Fetch-Mode": "cors", "Accept": "text/html"}, false},
+		{"navigate", map[string]string{"Sec-Fetch-Mode": "navigate", "Sec-Fetch-Dest": "document"}, true},
+		{"legacy browser", map[string]string{"Accept": "text/html,application/xhtml+xml;q=0.9"}, true},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/submit", nil)
+		for k, v := range tc.header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if got := getCookieByName(rec.Result(), "csrf_token") != nil; got != tc.issue {
+			t.Errorf("%s: issued=%v, want %v", tc.name, got, tc.issue)
+		}
+	}
+
+	// the token endpoint always issues
+	rec := httptest.NewRecorder()
+	tokenEndpointHandler(p).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/csrf-token", nil))
+	if getCookieByName(rec.Result(), "csrf_token") == nil {
+		t.Fatal("expected the token endpoint to issue a cookie")
+	}
+}
+
+// Probes are skipped by default; a custom IssuePredicate replaces the default.
+func TestIssuePredicate(t *testing.T) {
+	issued := func(p *Protector, ua string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/submit", nil)
+		req.Header.Set("User-Agent", ua)
+		rec := httptest.NewRecorder()
+		appHandler(p).ServeHTTP(rec, req)
+		return getCookieByName(rec.Result(), "csrf_token") != nil
+	}
+
+	p := New(Config{TokenBytes: 16})
+	if issued(p, "kube-probe/1.29") || issued(p, "ELB-HealthChecker/2.0") {
+		t.Fatal("expected probes to get no cookie")
+	}
+	if !issued(p, "Mozilla/5.0 (X11; Linux x86_64)") {
+		t.Fatal("expected browsers to get a cookie")
+	}
+
+	p = New(Config{TokenBytes: 16, IssuePredicate: func(*http.Request) bool { return true }})
+	if !issued(p, "kube-probe/1.29") {
+		t.Fatal("expected the custom predicate to replace the default")
+	}
+}
+
+// The SkipCookieOn settings keep Set-Cookie off HEAD, OPTIONS and preflight
+// responses.
+func TestSkipCookieOnMethods(t *testing.T) {
+	preflight := func(req *http.Request) {
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	cases := []struct {
+		name   string
+		cfg    Config
+		method string
+		header func(*http.Request)
+		issue  bool
+	}{
+		{"HEAD by default", Config{}, http.MethodHead, nil, true},
+		{"HEAD skipped", Config{SkipCookieOnHEAD: true}, http.MethodHead, nil, false},
+		{"OPTIONS skipped", Config{SkipCookieOnOPTIONS: true}, http.MethodOptions, nil, false},
+		{"preflight skipped", Config{SkipCookieOnPreflight: true}, http.MethodOptions, preflight, false},
+		{"plain OPTIONS with preflight skip", Config{SkipCookieOnPreflight: true}, http.MethodOptions, nil, true},
+		{"GET unaffected", Config{SkipCookieOnHEAD: true, SkipCookieOnOPTIONS: true}, http.MethodGet, nil, true},
+	}
+	for _, tc := range cases {
+		tc.cfg.TokenBytes = 16
+		req := httptest.NewRequest(tc.method, "/", nil)
+		if tc.header != nil {
+			tc.header(req)
+		}
+		rec := httptest.NewRecorder()
+		appHandler(New(tc.cfg)).ServeHTTP(rec, req)
+		if got := getCookieByName(rec.Result(), "csrf_token") != nil; got != tc.issue {
+			t.Errorf("%s: issued=%v, want %v", tc.name, got, tc.issue)
+		}
+	}
+}
+
+// With PreflightPassthrough, preflights reach the inner handler untouched.
+func TestPreflightPassthrough(t *testing.T) {
+	p := New(Config{TokenBytes: 16, PreflightPassthrough: true, StatusHeader: "X-CSRF-Protected"})
+	var inContext bool
+	h := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, inContext = ProtectorFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/api", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || inContext || rec.Header().Get("Set-Cookie") != "" || rec.Header().Get("X-CSRF-Protected") != "" {
+		t.Fatalf("preflight was processed: %d context=%v headers=%v", rec.Code, inContext, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/api", nil))
+	if !inContext || rec.Header().Get("Set-Cookie") == "" {
+		t.Fatal("plain OPTIONS should still be handled")
+	}
+}
+
+// With DeferIssuance, pages stay cookie-free and the first unsafe request
+// hands out the token for a single retry.
+func TestDeferIssuance(t *testing.T) {
+	p := New(Config{TokenBytes: 16, DeferIssuance: true})
+	app := appHandler(p)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(rec.Result().Cookies()) != 0 {
+		t.Fatal("expected a cookie-free page")
+	}
+
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/submit", nil))
+	c := getCookieByName(rec.Result(), "csrf_token")
+	tok := rec.Header().Get("X-CSRF-Token")
+	if rec.Code != http.StatusForbidden || c == nil || tok != c.Value {
+		t.Fatalf("expected 403 with the new token in cookie and header, got %d, cookie %v, header %q", rec.Code, c, tok)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.AddCookie(c)
+	req.Header.Set("X-CSRF-Token", tok)
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the retry to pass, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	tokenEndpointHandler(p).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/csrf-token", nil))
+	if getCookieByName(rec.Result(), "csrf_token") == nil {
+		t.Fatal("expected the token endpoint to issue")
+	}
+}
+
+// CookieNameFunc gives each tenant its own cookie; a token minted for one
+// tenant is not accepted by another.
+func TestCookieNameFunc(t *testing.T) {
+	p := New(Config{TokenBytes: 16, CookieNameFunc: func(r *http.Request) string {
+		tenant, _, _ := strings.Cut(r.Host, ".")
+		return "csrf_" + tenant
+	}})
+	app := appHandler(p)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://acme.example.com/", nil))
+	c := getCookieByName(rec.Result(), "csrf_acme")
+	if c == nil {
+		t.Fatalf("expected csrf_acme cookie, got %v", rec.Result().Cookies())
+	}
+
+	post := func(host string) int {
+		req := httptest.NewRequest(http.MethodPost, "http://"+host+"/submit", nil)
+		req.AddCookie(c)
+		req.Header.Set("X-CSRF-Token", c.Value)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post("acme.example.com"); code != http.StatusOK {
+		t.Fatalf("same tenant: got %d", code)
+	}
+	if code := post("globex.example.com"); code != http.StatusForbidden {
+		t.Fatalf("other tenant: got %d", code)
+	}
+
+	bad := New(Config{TokenBytes: 16, CookieNameFunc: func(*http.Request) string { return "bad name;" }})
+	rec = httptest.NewRecorder()
+	appHandler(bad).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if getCookieByName(rec.Result(), "csrf_token") == nil {
+		t.Fatal("invalid names should fall back to CookieName")
+	}
+}
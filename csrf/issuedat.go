@@ -0,0 +1,65 @@
+package csrf
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// issuedAtSuffix is appended to CookieName to name the companion cookie that
+// records when the token was issued.
+const issuedAtSuffix = "_iat"
+
+// IssuedAt returns when the token carried by r was issued, as recorded in the
+// companion cookie (CookieName + "_iat") set when TrackIssuedAt is enabled.
+// Clients can read the same cookie to observe rotation windows.
+//
+// Params:
+// - r: incoming request.
+//
+// Returns:
+// - the issuance time and whether a well-formed companion cookie was present.
+func (p *Protector) IssuedAt(r *http.Request) (time.Time, bool) {
+	c, err := r.Cookie(p.cookieName(r) + issuedAtSuffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(c.Value, 10, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// tokenStale reports whether the token carried by r is older than maxAge.
+// Without a companion cookie the age is unknown and strict decides: true
+// treats it as stale, false gives it the benefit of the doubt.
+//
+// Params:
+// - r: incoming request.
+// - maxAge: maximum accepted age (0 disables the check).
+// - strict: result when the issuance time is unknown.
+//
+// Returns:
+// - true if the token must be considered stale.
+func (p *Protector) tokenStale(r *http.Request, maxAge time.Duration, strict bool) bool {
+	if maxAge <= 0 {
+		return false
+	}
+	iat, ok := p.IssuedAt(r)
+	if !ok {
+		return strict
+	}
+	return time.Since(iat) > maxAge
+}
+
+// setIssuedAtCookie adds the companion cookie recording that a token was
+// issued now, with the same attributes as the token cookie.
+//
+// Params:
+// - w: response writer to add the Set-Cookie header to.
+// - r: current request (for CookieNameFunc).
+func (p *Protector) setIssuedAtCookie(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Set-Cookie", p.cookieName(r)+issuedAtSuffix+"="+
+		strconv.FormatInt(time.Now().Unix(), 10)+p.cookieAttrs(r))
+}
@@ -0,0 +1,53 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The companion cookie records issuance; old tokens are rejected as expired
+// on unsafe requests and replaced on safe ones.
+func TestIssuedAtAndMaxTokenAge(t *testing.T) {
+	p := New(Config{TokenBytes: 16, MaxTokenAge: time.Hour})
+	app := appHandler(p)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/submit", nil))
+	res := rec.Result()
+	tok := getCookieByName(res, "csrf_token")
+	iat := getCookieByName(res, "csrf_token_iat")
+	if tok == nil || iat == nil {
+		t.Fatalf("expected token and issued-at cookies, got %v", res.Cookies())
+	}
+
+	post := func(issued time.Time) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(tok)
+		req.AddCookie(&http.Cookie{Name: "csrf_token_iat", Value: strconv.FormatInt(issued.Unix(), 10)})
+		req.Header.Set("X-CSRF-Token", tok.Value)
+		app.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := post(time.Now()); rec.Code != http.StatusOK {
+		t.Fatalf("expected fresh token to pass, got %d", rec.Code)
+	}
+	rec = post(time.Now().Add(-2 * time.Hour))
+	if rec.Code != http.StatusForbidden || strings.TrimSpace(rec.Body.String()) != "CSRF token expired" {
+		t.Fatalf("expected expired rejection, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// a safe request with a stale token gets a new one
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/submit", nil)
+	req.AddCookie(tok)
+	req.AddCookie(&http.Cookie{Name: "csrf_token_iat", Value: strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)})
+	app.ServeHTTP(rec, req)
+	if c := getCookieByName(rec.Result(), "csrf_token"); c == nil || c.Value == tok.Value {
+		t.Fatalf("expected stale token to be replaced on GET, got %v", c)
+	}
+}
@@ -0,0 +1,222 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// SigningKeyEntry is one key of the signing key ring (Config.SigningKeys).
+type SigningKeyEntry struct {
+	// ID names the key in metrics (e.g., "2024-06").
+	ID string
+
+	// Secret is the HMAC-SHA256 key (at least 32 bytes).
+	Secret []byte
+
+	// VerifyOnly marks a retired key: tokens it signed are still accepted,
+	// but no new token is signed with it.
+	VerifyOnly bool
+}
+
+// KeyUsage reports how often a ring key was used since the Protector was
+// built: Signed counts minted tokens, Verified validated unsafe requests.
+type KeyUsage struct {
+	Signed     int64 `json:"signed"`
+	Verified   int64 `json:"verified"`
+	VerifyOnly bool  `json:"verifyOnly"`
+}
+
+// ringKey is a SigningKeyEntry with its usage counters.
+type ringKey struct {
+	SigningKeyEntry
+	signed, verified atomic.Int64
+}
+
+// keyring holds the keys used for signed tokens. One key signs; all keys
+// verify.
+type keyring struct {
+	signer *ringKey
+	keys   []*ringKey
+}
+
+// keySource holds the current key ring, swapped atomically by
+// RefreshSecrets, and when it was last loaded and rotated.
+type keySource struct {
+	ring atomic.Pointer[keyring]
+
+	// loaded is when the last load started, throttling background
+	// refreshes; refreshed is when a ring was last stored and rotated when
+	// its signing key last changed. All are in Unix nanoseconds.
+	loaded, refreshed, rotated atomic.Int64
+}
+
+// store swaps in kr, noting when it was loaded and, if its signing key
+// differs from the previous ring's, rotated.
+func (ks *keySource) store(kr *keyring) {
+	now := time.Now().UnixNano()
+	if prev := ks.ring.Swap(kr); prev == nil || kr == nil || prev.signer.ID != kr.signer.ID {
+		ks.rotated.Store(now)
+	}
+	ks.refreshed.Store(now)
+}
+
+// state returns the rotation state reported by DebugHandler: the signing
+// and verifying key IDs and when the ring was last loaded and rotated, or
+// nil when tokens are not signed.
+func (ks *keySource) state() map[string]any {
+	kr := ks.ring.Load()
+	if kr == nil {
+		return nil
+	}
+	ids := make([]string, len(kr.keys))
+	for i, k := range kr.keys {
+		ids[i] = k.ID
+	}
+	return map[string]any{
+		"signer":  kr.signer.ID,
+		"keys":    ids,
+		"loaded":  time.Unix(0, ks.refreshed.Load()).UTC().Format(time.RFC3339),
+		"rotated": time.Unix(0, ks.rotated.Load()).UTC().Format(time.RFC3339),
+	}
+}
+
+// newKeySource loads the ring from cfg's SecretProvider or static keys.
+func newKeySource(cfg Config) *keySource {
+	ks := &keySource{}
+	if sp := cfg.SecretProvider; sp != nil {
+		all, current := secretSnapshot(sp)
+		ks.store(newKeyring(all, current, nil))
+	} else {
+		ks.store(newKeyring(configKeys(cfg), "", nil))
+	}
+	ks.loaded.Store(ks.refreshed.Load())
+	return ks
+}
+
+// configKeys returns the static keys of cfg: SigningKey (if set) under the
+// ID "default", followed by SigningKeys.
+func configKeys(cfg Config) []SigningKeyEntry {
+	var entries []SigningKeyEntry
+	if len(cfg.SigningKey) > 0 {
+		entries = append(entries, SigningKeyEntry{ID: "default", Secret: cfg.SigningKey})
+	}
+	return append(entries, cfg.SigningKeys...)
+}
+
+// newKeyring builds a ring from entries. The key named signerID signs, or
+// the first key not marked VerifyOnly when signerID is empty. Usage counters
+// of keys already present in prev carry over.
+//
+// Params:
+// - entries: the keys.
+// - signerID: ID of the signing key, or "".
+// - prev: the ring being replaced, or nil.
+//
+// Returns:
+// - the ring, or nil when entries is empty.
+func newKeyring(entries []SigningKeyEntry, signerID string, prev *keyring) *keyring {
+	if len(entries) == 0 {
+		return nil
+	}
+	kr := &keyring{}
+	for _, e := range entries {
+		k := &ringKey{SigningKeyEntry: e}
+		if prev != nil {
+			for _, old := range prev.keys {
+				if old.ID == e.ID {
+					k.signed.Store(old.signed.Load())
+					k.verified.Store(old.verified.Load())
+				}
+			}
+		}
+		kr.keys = append(kr.keys, k)
+		if kr.signer == nil && !e.VerifyOnly && (signerID == "" || e.ID == signerID) {
+			kr.signer = k
+		}
+	}
+	return kr
+}
+
+// sign returns the MAC of body under the signing key.
+func (kr *keyring) sign(body string) []byte {
+	kr.signer.signed.Add(1)
+	return tokenMAC(kr.signer.Secret, body)
+}
+
+// match returns the ring key mac is the MAC of body under, or nil. It
+// counts nothing: a cookie is checked several times per request, so
+// verifications are counted once, by countVerified.
+func (kr *keyring) match(body string, mac []byte) *ringKey {
+	for _, k := range kr.keys {
+		if hmac.Equal(mac, tokenMAC(k.Secret, body)) {
+			return k
+		}
+	}
+	return nil
+}
+
+// validateKeyring checks the keys of cfg: long enough, uniquely named, and
+// at least one allowed to sign. Keys from a SecretProvider are checked the
+// same way, and cannot be combined with static keys.
+func validateKeyring(cfg Config) error {
+	if n := len(cfg.SigningKey); n > 0 && n < minSigningKeyBytes {
+		return fmt.Errorf("csrf: SigningKey must be at least %d bytes, got %d", minSigningKeyBytes, n)
+	}
+	if sp := cfg.SecretProvider; sp != nil {
+		if len(cfg.SigningKey) > 0 || len(cfg.SigningKeys) > 0 {
+			return fmt.Errorf("csrf: SecretProvider cannot be combined with SigningKey or SigningKeys")
+		}
+		return validateKeys(secretSnapshot(sp))
+	}
+	if len(cfg.SigningKeys) == 0 {
+		return nil
+	}
+	return validateKeys(configKeys(cfg), "")
+}
+
+// validateKeys checks entries: long enough, uniquely named, and with a
+// signing key (the one named signerID, when not empty).
+func validateKeys(entries []SigningKeyEntry, signerID string) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("csrf: no signing keys")
+	}
+	seen := map[string]bool{}
+	canSign := false
+	for _, k := range entries {
+		switch {
+		case k.ID == "":
+			return fmt.Errorf("csrf: signing key without ID")
+		case seen[k.ID]:
+			return fmt.Errorf("csrf: duplicate signing key ID %q", k.ID)
+		case len(k.Secret) < minSigningKeyBytes:
+			return fmt.Errorf("csrf: signing key %q must be at least %d bytes, got %d", k.ID, minSigningKeyBytes, len(k.Secret))
+		}
+		seen[k.ID] = true
+		canSign = canSign || !k.VerifyOnly && (signerID == "" || k.ID == signerID)
+	}
+	if !canSign {
+		return fmt.Errorf("csrf: no signing key may sign (all VerifyOnly or current key missing)")
+	}
+	return nil
+}
+
+// KeyUsage returns the usage counters of each signing key by ID (tokens
+// signed, and unsafe requests validated with a token it signed), so
+// operators can confirm a VerifyOnly key no longer verifies anything before
+// deleting it. It is empty when tokens are not signed.
+//
+// Returns:
+// - usage per key ID.
+func (p *Protector) KeyUsage() map[string]KeyUsage {
+	out := map[string]KeyUsage{}
+	kr := p.keys.ring.Load()
+	if kr == nil {
+		return out
+	}
+	for _, k := range kr.keys {
+		out[k.ID] = KeyUsage{Signed: k.signed.Load(), Verified: k.verified.Load(), VerifyOnly: k.VerifyOnly}
+	}
+	return out
+}
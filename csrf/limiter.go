@@ -0,0 +1,176 @@
+package csrf
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter tracks CSRF failures per client and decides whether a client may
+// keep attempting unsafe requests. Implementations must be safe for
+// concurrent use; the key is the client IP resolved by the middleware.
+type Limiter interface {
+	// Allow reports whether the client identified by key may proceed.
+	Allow(ctx context.Context, key string) (bool, error)
+
+	// Fail records one CSRF failure for the client identified by key.
+	Fail(ctx context.Context, key string) error
+}
+
+// MemoryLimiter is an in-memory token-bucket Limiter. Every failure consumes
+// one token from the client's bucket; buckets refill at a steady rate. A client
+// whose bucket is empty is denied until a token becomes available again.
+type MemoryLimiter struct {
+	burst float64
+	every time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	ops     int
+
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// sweepEvery is the number of Fail calls between sweeps of full buckets.
+const sweepEvery = 1024
+
+// NewMemoryLimiter returns a MemoryLimiter allowing burst failures per client,
+// regaining one failure allowance every interval.
+//
+// Params:
+// - burst: number of failures tolerated before the client is denied (min 1).
+// - every: time needed to regain one failure allowance (default 1s when <= 0).
+//
+// Returns:
+// - *MemoryLimiter ready for use.
+func NewMemoryLimiter(burst int, every time.Duration) *MemoryLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	if every <= 0 {
+		every = time.Second
+	}
+	return &MemoryLimiter{
+		burst:   float64(burst),
+		every:   every,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow reports whether key still has at least one failure allowance left.
+func (l *MemoryLimiter) Allow(_ context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return true, nil
+	}
+	l.refill(b, l.now())
+	return b.tokens >= 1, nil
+}
+
+// Fail consumes one failure allowance from key's bucket.
+func (l *MemoryLimiter) Fail(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+	if b.tokens >= 1 {
+		b.tokens--
+	} else {
+		b.tokens = 0
+	}
+
+	l.ops++
+	if l.ops >= sweepEvery {
+		l.ops = 0
+		l.sweep(now)
+	}
+	return nil
+}
+
+// refill adds the allowances regained since b.last, capped at burst.
+func (l *MemoryLimiter) refill(b *bucket, now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += float64(elapsed) / float64(l.every)
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+}
+
+// sweep drops buckets that have fully refilled; they carry no state.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// clientIP returns the IP address of the client that sent r. When the direct
+// peer is one of the trusted proxies, X-Forwarded-For is walked from right to
+// left and the first address not belonging to a trusted proxy is returned.
+//
+// Params:
+// - r: incoming request.
+// - trusted: networks of reverse proxies allowed to set X-Forwarded-For.
+//
+// Returns:
+// - the client IP as a string (or RemoteAddr verbatim if it cannot be parsed).
+func clientIP(r *http.Request, trusted []net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if len(trusted) == 0 || !inNetworks(net.ParseIP(host), trusted) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			break
+		}
+		host = hop
+		if !inNetworks(ip, trusted) {
+			break
+		}
+	}
+	return host
+}
+
+// inNetworks reports whether ip belongs to any of nets.
+func inNetworks(ip net.IP, nets []net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package csrf
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// The memory limiter denies a client after burst failures and refills over time.
+func TestMemoryLimiterTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	l := NewMemoryLimiter(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow(ctx, "1.2.3.4"); !ok {
+			t.Fatalf("expected allow before failure %d", i+1)
+		}
+		l.Fail(ctx, "1.2.3.4")
+	}
+	if ok, _ := l.Allow(ctx, "1.2.3.4"); ok {
+		t.Fatalf("expected deny after burst failures")
+	}
+	if ok, _ := l.Allow(ctx, "5.6.7.8"); !ok {
+		t.Fatalf("other clients must not be affected")
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := l.Allow(ctx, "1.2.3.4"); !ok {
+		t.Fatalf("expected allow after refill")
+	}
+}
+
+// Clients exhausting the limiter get 429 even with a valid token.
+func TestProtectFailureLimiter(t *testing.T) {
+	cfg := Config{
+		CookieName:     "csrf_token_test",
+		TokenBytes:     16,
+		FailureLimiter: NewMemoryLimiter(1, time.Hour),
+	}
+	p := New(cfg)
+	app := appHandler(p)
+	token, _ := newToken(16)
+
+	post := func(header string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		req.AddCookie(&http.Cookie{Name: cfg.CookieName, Value: token})
+		req.Header.Set("X-CSRF-Token", header)
+		app.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post("wrong"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 on first failure, got %d", code)
+	}
+	if code := post(token); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once limited, got %d", code)
+	}
+}
+
+// X-Forwarded-For is honored only when the peer is a trusted proxy.
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := []net.IPNet{*proxies}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.1.2.3:443"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 10.9.9.9")
+	if got := clientIP(req, trusted); got != "198.51.100.1" {
+		t.Fatalf("expected forwarded client, got %q", got)
+	}
+	if got := clientIP(req, nil); got != "10.1.2.3" {
+		t.Fatalf("expected peer without trusted proxies, got %q", got)
+	}
+
+	req.RemoteAddr = "192.0.2.1:443"
+	if got := clientIP(req, trusted); got != "192.0.2.1" {
+		t.Fatalf("untrusted peer must not be overridden, got %q", got)
+	}
+}
+
+// Requests from TrustedNetworks bypass enforcement; others do not.
+func TestTrustedNetworksBypass(t *testing.T) {
+	_, vpc, _ := net.ParseCIDR("10.0.0.0/8")
+	p := New(Config{TrustedNetworks: []net.IPNet{*vpc}})
+	app := appHandler(p)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.RemoteAddr = "10.20.30.40:9999"
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected trusted network to bypass, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.RemoteAddr = "203.0.113.1:9999"
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected enforcement outside trusted networks, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,37 @@
+package csrf
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrHeaderTooLarge rejects requests whose CSRF-relevant headers exceed the
+// configured limits.
+var ErrHeaderTooLarge = errors.New("CSRF header too large")
+
+// checkLengths rejects unsafe requests whose token header, token cookie,
+// Origin or Referer exceed MaxHeaderTokenBytes, MaxCookieBytes or
+// MaxOriginBytes, before anything is decoded or parsed.
+//
+// Params:
+// - r: incoming unsafe request.
+//
+// Returns:
+// - nil, or ErrHeaderTooLarge wrapped with the offending header's name.
+func (p *Protector) checkLengths(r *http.Request) error {
+	cfg := p.cfg
+	headerName, _ := p.tokenNames(p.ruleFor(r))
+	if len(r.Header.Get(headerName)) > cfg.MaxHeaderTokenBytes {
+		return fmt.Errorf("%w: %s", ErrHeaderTooLarge, headerName)
+	}
+	if c, err := r.Cookie(p.cookieName(r)); err == nil && len(c.Value) > cfg.MaxCookieBytes {
+		return fmt.Errorf("%w: cookie %s", ErrHeaderTooLarge, c.Name)
+	}
+	for _, h := range []string{"Origin", "Referer"} {
+		if len(r.Header.Get(h)) > cfg.MaxOriginBytes {
+			return fmt.Errorf("%w: %s", ErrHeaderTooLarge, h)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Oversized headers are rejected with 431 and a distinct reason before any
+// parsing.
+func TestHeaderLengthLimits(t *testing.T) {
+	var reasons []string
+	p := New(Config{
+		TokenBytes:          16,
+		MaxHeaderTokenBytes: 64,
+		OnRejectEvent:       func(e RejectionEvent) { reasons = append(reasons, e.Reason) },
+	})
+	app := appHandler(p)
+	tok := strings.Repeat("A", 22)
+	long := strings.Repeat("A", 100)
+
+	cases := []struct {
+		name string
+		set  func(*http.Request)
+		code int
+	}{
+		{"header", func(r *http.Request) { r.Header.Set("X-CSRF-Token", long) }, http.StatusRequestHeaderFieldsTooLarge},
+		{"cookie", func(r *http.Request) {
+			r.Header.Set("Cookie", "csrf_token="+strings.Repeat("A", MaxTokenLength+1))
+		}, http.StatusRequestHeaderFieldsTooLarge},
+		{"origin", func(r *http.Request) {
+			r.Header.Set("Origin", "https://"+strings.Repeat("a", MaxOriginLength))
+		}, http.StatusRequestHeaderFieldsTooLarge},
+		{"within limits", func(*http.Request) {}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tok})
+		req.Header.Set("X-CSRF-Token", tok)
+		tc.set(req)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("%s: got %d, want %d", tc.name, rec.Code, tc.code)
+		}
+	}
+	if len(reasons) != 3 || reasons[0] != "header_too_large" {
+		t.Fatalf("unexpected reasons %v", reasons)
+	}
+}
+
+// A form body cut off by http.MaxBytesHandler yields 413, not "missing CSRF
+// token".
+func TestMaxBytesHandlerBody(t *testing.T) {
+	p := New(Config{TokenBytes: 16})
+	app := http.MaxBytesHandler(appHandler(p), 16)
+	tok := strings.Repeat("A", 22)
+
+	body := "note=" + strings.Repeat("x", 64) + "&csrf_token=" + tok
+	req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tok})
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), ErrBodyTooLarge.Error()) {
+		t.Fatalf("expected 413 %q, got %d %q", ErrBodyTooLarge, rec.Code, rec.Body.String())
+	}
+}
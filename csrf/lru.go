@@ -0,0 +1,74 @@
+package csrf
+
+import (
+	"container/list"
+	"sync"
+)
+
+// stringLRU is a small, concurrency-safe LRU cache of string results. A nil
+// *stringLRU is a valid, always-empty cache.
+type stringLRU struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // front = most recently used; values are *lruEntry
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	val string
+}
+
+// newStringLRU returns a cache holding up to size entries, or nil when size is
+// not positive (caching disabled).
+func newStringLRU(size int) *stringLRU {
+	if size <= 0 {
+		return nil
+	}
+	return &stringLRU{size: size, order: list.New(), items: make(map[string]*list.Element, size)}
+}
+
+// get returns the cached value for key and whether it was present.
+func (c *stringLRU) get(key string) (val string, ok bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).val, true
+}
+
+// put stores val for key, evicting the least recently used entry when full.
+func (c *stringLRU) put(key string, val string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).val = val
+		c.order.MoveToFront(el)
+		return
+	}
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, val: val})
+}
+
+// len returns the number of cached entries.
+func (c *stringLRU) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
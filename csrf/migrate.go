@@ -0,0 +1,55 @@
+package csrf
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// migration maps a superseded Config field onto its replacement.
+type migration struct {
+	// field and replacement name the old and new fields in warnings.
+	field, replacement string
+
+	// apply moves the old value into the new field and clears it, reporting
+	// whether the old field was set.
+	apply func(cfg *Config) bool
+}
+
+// migrations lists the superseded Config fields, applied in order by New
+// before defaults, so upgrades keep the configured behavior.
+var migrations = []migration{
+	{"AllowedOrigin", "AllowedOrigins", func(cfg *Config) bool {
+		if cfg.AllowedOrigin == "" {
+			return false
+		}
+		if !slices.Contains(cfg.AllowedOrigins, cfg.AllowedOrigin) {
+			cfg.AllowedOrigins = append([]string{cfg.AllowedOrigin}, cfg.AllowedOrigins...)
+		}
+		cfg.AllowedOrigin = ""
+		return true
+	}},
+	{"FormField", "FormFields", func(cfg *Config) bool {
+		if cfg.FormField == "" {
+			return false
+		}
+		if !slices.Contains(cfg.FormFields, cfg.FormField) {
+			cfg.FormFields = append([]string{cfg.FormField}, cfg.FormFields...)
+		}
+		cfg.FormField = ""
+		return true
+	}},
+}
+
+// migrate applies migrations to cfg, logging a deprecation warning for each
+// superseded field in use.
+//
+// Params:
+// - cfg: configuration to rewrite in place.
+// - logger: destination of the warnings.
+func migrate(cfg *Config, logger *slog.Logger) {
+	for _, m := range migrations {
+		if m.apply(cfg) {
+			logger.Warn("csrf: deprecated Config field", "field", m.field, "replacement", m.replacement)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package csrf
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Deprecated fields keep their behavior, are moved to their replacements
+// and produce one warning each.
+func TestConfigMigration(t *testing.T) {
+	var logs bytes.Buffer
+	p := New(Config{
+		TokenBytes:         16,
+		Logger:             slog.New(slog.NewTextHandler(&logs, nil)),
+		EnforceOriginCheck: true,
+		AllowedOrigin:      "app.example.com",
+		AllowedOrigins:     []string{"admin.example.com"},
+		FormField:          "_csrf",
+	})
+
+	cfg := p.Config()
+	if cfg.AllowedOrigin != "" || strings.Join(cfg.AllowedOrigins, ",") != "app.example.com,admin.example.com" {
+		t.Fatalf("unexpected AllowedOrigins %v", cfg.AllowedOrigins)
+	}
+	if cfg.FormField != "" || strings.Join(cfg.FormFields, ",") != "_csrf" {
+		t.Fatalf("unexpected FormFields %v", cfg.FormFields)
+	}
+	for _, field := range []string{"field=AllowedOrigin replacement=AllowedOrigins", "field=FormField replacement=FormFields"} {
+		if !strings.Contains(logs.String(), field) {
+			t.Fatalf("expected a deprecation warning for %q, got %s", field, logs.String())
+		}
+	}
+
+	// the old values still drive validation
+	token, _ := newToken(16)
+	for _, origin := range []string{"https://app.example.com", "https://admin.example.com"} {
+		req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(url.Values{"_csrf": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", origin)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+		rec := httptest.NewRecorder()
+		appHandler(p).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", origin, rec.Code)
+		}
+	}
+
+	// a migrated config is silent when reused
+	logs.Reset()
+	New(cfg)
+	if logs.Len() != 0 {
+		t.Fatalf("expected no warnings for a migrated config, got %s", logs.String())
+	}
+}
@@ -0,0 +1,40 @@
+package csrf
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Paths, relative to the Mount prefix, of the endpoints registered by Mount.
+const (
+	TokenPath  = "/csrf-token"
+	RotatePath = "/csrf-token/rotate"
+)
+
+// Mount protects the subtree of mux under prefix and registers the token
+// endpoint at prefix+TokenPath and the rotation endpoint (POST) at
+// prefix+RotatePath. It returns the sub-mux serving that subtree:
+// register the application routes on it using full paths (the prefix is not
+// stripped).
+//
+//	api := p.Mount(mux, "/api")
+//	api.HandleFunc("POST /api/transfer", transfer)
+//
+// An empty prefix or "/" mounts at the root.
+//
+// Params:
+// - mux: the parent mux to register the protected subtree on.
+// - prefix: path prefix of the subtree (e.g., "/api").
+//
+// Returns:
+// - *http.ServeMux for the protected subtree.
+func (p *Protector) Mount(mux *http.ServeMux, prefix string) *http.ServeMux {
+	prefix = strings.TrimRight(prefix, "/")
+
+	sub := http.NewServeMux()
+	sub.Handle("GET "+prefix+TokenPath, p.TokenHandler())
+	// the subtree is already protected; avoid validating twice
+	sub.Handle("POST "+prefix+RotatePath, p.rotateHandler())
+	mux.Handle(prefix+"/", p.Protect(sub))
+	return sub
+}
@@ -0,0 +1,57 @@
+package csrf
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Mount registers the token endpoint and protects routes under the prefix only.
+func TestMount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /public", func(w http.ResponseWriter, r *http.Request) {})
+
+	p := New(Config{CookieName: "csrf_token_test", TokenBytes: 16})
+	api := p.Mount(mux, "/api/")
+	api.HandleFunc("POST /api/transfer", func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/csrf-token", nil))
+	res := rec.Result()
+	body, _ := io.ReadAll(res.Body)
+	c := getCookieByName(res, "csrf_token_test")
+	if res.StatusCode != http.StatusOK || c == nil || c.Value != string(body) {
+		t.Fatalf("token endpoint: status=%d cookie=%v body=%q", res.StatusCode, c, body)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/transfer", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected protected route to reject, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/transfer", nil)
+	req.AddCookie(c)
+	req.Header.Set("X-CSRF-Token", c.Value)
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected valid token to pass, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/csrf-token/rotate", nil)
+	req.AddCookie(c)
+	req.Header.Set("X-CSRF-Token", c.Value)
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || getCookieByName(rec.Result(), "csrf_token_test") == nil {
+		t.Fatalf("expected rotation endpoint to issue a new cookie, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/public", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("routes outside the prefix must not be protected, got %d", rec.Code)
+	}
+}
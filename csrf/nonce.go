@@ -0,0 +1,144 @@
+package csrf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// defaultFormNonceTTL is the default FormNonceTTL.
+const defaultFormNonceTTL = 10 * time.Minute
+
+// formNonceHeader carries the nonce for script-driven submissions.
+const formNonceHeader = "X-CSRF-Nonce"
+
+var errNoTokenStore = errors.New("csrf: form nonces need Config.TokenStore")
+
+// ErrBadNonce rejects a form nonce that is unknown, expired, already used
+// or issued for another purpose.
+var ErrBadNonce = errors.New("invalid or reused form nonce")
+
+// IssueFormNonce mints a single-use nonce for one rendering of an
+// ultra-sensitive form (e.g., wire transfer confirmation) and records it in
+// Config.TokenStore for FormNonceTTL. The nonce is bound to purpose and to
+// the client's CSRF token, and complements the regular token rather than
+// replacing it: embed both in the form.
+//
+// Params:
+// - r: the request rendering the form (inside Protect).
+// - purpose: the form the nonce is for (e.g., "wire-transfer").
+//
+// Returns:
+// - the nonce, or an error if no TokenStore is set or the store fails.
+func (p *Protector) IssueFormNonce(r *http.Request, purpose string) (string, error) {
+	if p.cfg.TokenStore == nil {
+		return "", errNoTokenStore
+	}
+	nonce, err := newToken(p.cfg.TokenBytes)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := p.storeContext(r)
+	defer cancel()
+	if err := p.cfg.TokenStore.Put(ctx, p.nonceKey(r, nonce, purpose), p.cfg.FormNonceTTL); err != nil {
+		return "", err
+	}
+	return nonce, nil
+}
+
+// FormNonceField returns a hidden input carrying a new nonce for purpose,
+// named FormNonceField, or an empty value when it cannot be issued.
+//
+// Params:
+// - r: the request rendering the form.
+// - purpose: the form the nonce is for.
+//
+// Returns:
+// - the escaped <input type="hidden"> element as template.HTML.
+func (p *Protector) FormNonceField(r *http.Request, purpose string) template.HTML {
+	nonce, err := p.IssueFormNonce(r, purpose)
+	if err != nil {
+		return ""
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(p.cfg.FormNonceField) +
+		`" value="` + template.HTMLEscapeString(nonce) + `">`)
+}
+
+// ConsumeFormNonce checks the nonce sent by r (header X-CSRF-Nonce or form
+// field FormNonceField) for purpose and removes it from the store,
+// so it cannot be used twice.
+//
+// Params:
+// - r: the form submission.
+// - purpose: the form the nonce was issued for.
+//
+// Returns:
+// - nil if the nonce was valid; ErrBadNonce ("invalid or reused form
+// nonce") if missing, unknown, expired, already used or issued for another
+// purpose or client; or the store error.
+func (p *Protector) ConsumeFormNonce(r *http.Request, purpose string) error {
+	if p.cfg.TokenStore == nil {
+		return errNoTokenStore
+	}
+	nonce := r.Header.Get(formNonceHeader)
+	if nonce == "" {
+		nonce = r.PostFormValue(p.cfg.FormNonceField)
+	}
+	if nonce == "" || len(nonce) > MaxTokenLength {
+		return ErrBadNonce
+	}
+	ctx, cancel := p.storeContext(r)
+	defer cancel()
+	ok, err := p.cfg.TokenStore.Take(ctx, p.nonceKey(r, nonce, purpose))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrBadNonce
+	}
+	return nil
+}
+
+// RequireFormNonce wraps next so that unsafe requests must carry a valid
+// nonce issued for purpose; others get 403 "invalid or reused form nonce"
+// (reason code "bad_nonce"), or 500 when the store fails. Safe requests
+// pass through. Mount the result inside Protect.
+//
+// Params:
+// - next: handler performing the sensitive action.
+// - purpose: the form the nonce is issued for.
+//
+// Returns:
+// - http.Handler enforcing the nonce. It panics if TokenStore is not set.
+func (p *Protector) RequireFormNonce(next http.Handler, purpose string) http.Handler {
+	if p.cfg.TokenStore == nil {
+		panic(errNoTokenStore.Error())
+	}
+	return p.guard(next, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		if unsafeMethods[r.Method] {
+			switch err := p.ConsumeFormNonce(r, purpose); {
+			case errors.Is(err, ErrBadNonce):
+				p.reject(w, r, http.StatusForbidden, err)
+				return
+			case err != nil:
+				p.fail(w, r, http.StatusInternalServerError, unavailable("nonce store"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// nonceKey derives the store key for nonce, binding it to purpose and to
+// the CSRF token of r's client.
+func (p *Protector) nonceKey(r *http.Request, nonce, purpose string) string {
+	tok, ok := TokenFromContext(r.Context())
+	if !ok {
+		tok, _ = p.cookieToken(r)
+	}
+	sum := sha256.Sum256([]byte(nonce + "\x00" + purpose + "\x00" + tok))
+	return "csrf-nonce:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,51 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// A form nonce is accepted once, for its purpose and client only.
+func TestFormNonce(t *testing.T) {
+	p := New(Config{TokenBytes: 16, TokenStore: NewMemoryTokenStore()})
+	var nonce string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /transfer", func(_ http.ResponseWriter, r *http.Request) {
+		nonce, _ = p.IssueFormNonce(r, "wire-transfer")
+	})
+	mux.Handle("POST /transfer", p.RequireFormNonce(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), "wire-transfer"))
+	mux.Handle("POST /other", p.RequireFormNonce(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), "other"))
+	app := p.Protect(mux)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transfer", nil))
+	cookie := rec.Result().Cookies()[0]
+
+	submit := func(path string, c *http.Cookie, nonce string) int {
+		form := url.Values{"csrf_token": {c.Value}, "csrf_nonce": {nonce}}
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(c)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	other, _ := newToken(16)
+	otherCookie := &http.Cookie{Name: "csrf_token", Value: other}
+
+	if code := submit("/other", cookie, nonce); code != http.StatusForbidden {
+		t.Fatalf("other purpose: got %d", code)
+	}
+	if code := submit("/transfer", otherCookie, nonce); code != http.StatusForbidden {
+		t.Fatalf("other client: got %d", code)
+	}
+	if code := submit("/transfer", cookie, nonce); code != http.StatusOK {
+		t.Fatalf("valid nonce: got %d", code)
+	}
+	if code := submit("/transfer", cookie, nonce); code != http.StatusForbidden {
+		t.Fatalf("reused nonce: got %d", code)
+	}
+}
@@ -1,7 +1,17 @@
 // Package csrf provides a lightweight double-submit-cookie CSRF protection middleware.
 package csrf
 
-import "net/http"
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
 
 // Config holds cookie attributes, token transport options and security flags
 // used by the CSRF protector. New applies sensible defaults when fields are
@@ -13,7 +23,8 @@ import "net/http"
 //     with HttpOnly=false by design.
 //   - Defaults applied by New when zero values are provided:
 //     CookieName="csrf_token", CookiePath="/", CookieSameSite=http.SameSiteLaxMode,
-//     HeaderName="X-CSRF-Token", FormField="csrf_token", TokenBytes=32.
+//     HeaderName="X-CSRF-Token", FormFields=["csrf_token"], TokenBytes=32,
+//     Logger=slog.Default().
 type Config struct {
 	// CookieName is the name of the CSRF token cookie.
 	// Default: "csrf_token".
@@ -37,9 +48,17 @@ type Config struct {
 	CookieHTTPOnly bool
 
 	// CookieSameSite sets the SameSite attribute of the CSRF cookie.
-	// Default: http.SameSiteLaxMode.
+	// http.SameSiteNoneMode forces CookieSecure, since browsers reject
+	// None cookies without Secure. Default: http.SameSiteLaxMode.
 	CookieSameSite http.SameSite
 
+	// AutoSameSite, when true and CookieSameSite is left zero, picks SameSite
+	// from the deployment shape: None (forcing Secure) when TokenCORSOrigin
+	// serves a cross-origin SPA, Strict when the cookie is host-only
+	// (CookieDomain empty) and Lax when CookieDomain shares it with sibling
+	// subdomains. The decision is visible via Protector.Config and SelfCheck.
+	AutoSameSite bool
+
 	// CookieMaxAge is the Max-Age attribute in seconds.
 	// 0 means a session cookie (no Max-Age attribute). Negative values are not set by this package.
 	CookieMaxAge int // in seconds
@@ -49,9 +68,16 @@ type Config struct {
 	// Default: "X-CSRF-Token".
 	HeaderName string
 
-	// FormField is the form field name (application/x-www-form-urlencoded or
-	// multipart/form-data) from which the client may provide the token.
-	// Default: "csrf_token".
+	// FormFields are the form field names (application/x-www-form-urlencoded
+	// or multipart/form-data) from which the client may provide the token,
+	// tried in order; the first one is used by TemplateField.
+	// Default: ["csrf_token"].
+	FormFields []string
+
+	// FormField is the single form field name used before FormFields.
+	//
+	// Deprecated: Use FormFields. New moves it to the front of FormFields
+	// and logs a warning.
 	FormField string
 
 	// EnforceOriginCheck, when true, validates that unsafe requests originate
@@ -59,25 +85,595 @@ type Config struct {
 	// Referer header.
 	EnforceOriginCheck bool
 
-	// AllowedOrigin is the allowed site (host) for same-site checks when
-	// EnforceOriginCheck is enabled. If empty, the current request host (r.Host)
-	// is used.
-	// Example: "app.example.com"
+	// AllowedOrigins are the allowed sites (hosts) for same-site checks when
+	// EnforceOriginCheck is enabled; a request is accepted if its Origin (or
+	// Referer) matches any of them. If empty, the current request host
+	// (r.Host, or HostResolver's answer) is used. For tenant subdomains that
+	// cannot be listed, see AllowedOriginPatterns.
+	// Example: []string{"app.example.com", "admin.example.com"}
+	AllowedOrigins []string
+
+	// AllowedOrigin is the single allowed host used before AllowedOrigins.
+	//
+	// Deprecated: Use AllowedOrigins. New moves it to the front of
+	// AllowedOrigins and logs a warning.
 	AllowedOrigin string
 
+	// OriginComparator, when set, replaces the built-in host comparison of
+	// the origin check: it receives the parsed Origin (or Referer) and the
+	// request and reports whether they are same-site. Use it for topologies
+	// the host check cannot express (dev tunnels, generated preview hosts).
+	// Unparseable values are rejected without calling it.
+	OriginComparator func(origin *url.URL, r *http.Request) bool
+
+	// AllowedOriginPatterns lists additional host patterns accepted by the
+	// origin check, aimed at ephemeral preview deployments, e.g.
+	// "pr-*.preview.example.com" or "myapp-*.vercel.app". "*" matches within
+	// a single label; a leading "**" label matches subdomains at any depth
+	// ("**.example.com" accepts a.example.com and a.b.example.com, not
+	// example.com). Overly broad patterns ("*.com", "*.vercel.app") are
+	// rejected by Validate.
+	AllowedOriginPatterns []string
+
+	// AllowedExtensionIDs lists browser extensions whose pages may call the
+	// API, by bare ID (e.g., "abcdefghijklmnopabcdefghijklmnop"): origins
+	// "chrome-extension://<id>", "moz-extension://<id>" and
+	// "safari-web-extension://<id>" are accepted by the origin check.
+	// Extension origins are rejected by default; the host comparison only
+	// applies to http and https origins.
+	AllowedExtensionIDs []string
+
+	// AllowFileOrigin accepts the "file://" origin sent by pages loaded
+	// from disk, such as Electron apps. Any local HTML file opened in a
+	// browser shares that origin, so enable it only for APIs meant for such
+	// an app. The opaque "null" origin is never accepted.
+	AllowFileOrigin bool
+
+	// OnOriginMatch, if set, is called for every unsafe request accepted
+	// with the origin check on, with the allowlist entry that matched its
+	// Origin or Referer (see OriginMatches for the entry names), to tune
+	// the allowlist from production traffic.
+	OnOriginMatch func(r *http.Request, entry string)
+
+	// TokenPoolSize, when positive, keeps up to this many tokens
+	// pre-generated in memory, refilled in the background, so bursts of
+	// first-visit traffic do not serialize on crypto/rand. Each pooled token
+	// is used once. Pool hits and misses are reported by DebugHandler.
+	TokenPoolSize int
+
+	// OriginCacheSize, when positive, caches the result of the origin check
+	// per Origin/Referer value in an LRU of this many entries, sparing the
+	// URL parsing on high-traffic APIs that see the same few origins. It is
+	// not used when OriginComparator is set.
+	OriginCacheSize int
+
+	// SigningKey, when set (at least 32 bytes), switches to signed tokens:
+	// "<random>.<region>.<HMAC-SHA256>". Cookies whose signature does not
+	// verify are ignored and replaced, so an attacker able to plant cookies
+	// (e.g., from a sibling subdomain) cannot make up a token. Tokens are
+	// not bound to a session, though: the attacker can still plant a valid
+	// token obtained from the site itself. Use DeviceCookie or
+	// SessionTokenStore against planted cookies. Deployments sharing the
+	// key accept each other's tokens.
+	SigningKey []byte
+
+	// SigningKeys is a key ring for signed tokens, after SigningKey (which
+	// joins it under the ID "default"). The first key not marked VerifyOnly
+	// signs new tokens; every key verifies, so retired keys can be kept as
+	// VerifyOnly until Protector.KeyUsage shows they are unused.
+	SigningKeys []SigningKeyEntry
+
+	// Region identifies the cluster minting tokens (e.g., "eu-west-1");
+	// it is embedded in signed tokens and must not contain ".".
+	Region string
+
+	// PeerRegions, when not empty, restricts accepted signed tokens to
+	// those minted in Region or one of these regions; when empty, any
+	// region signed with SigningKey is accepted, so users whose requests
+	// fail over between regions are not rejected.
+	PeerRegions []string
+
+	// SecretProvider, when set, supplies the signing keys at runtime instead
+	// of SigningKey/SigningKeys (which must then be empty). See
+	// EnvSecretProvider, FileSecretProvider and VaultSecretProvider.
+	SecretProvider SecretProvider
+
+	// SecretRefreshInterval, when > 0, reloads the keys from SecretProvider
+	// in the background once this much time has passed since the last load.
+	// Failed reloads are logged and keep the current keys.
+	SecretRefreshInterval time.Duration
+
+	// MaxHeaderTokenBytes, MaxCookieBytes and MaxOriginBytes cap the length
+	// of the token header, the token cookie and the Origin/Referer headers.
+	// Unsafe requests exceeding them are rejected with 431 and the reason
+	// "CSRF header too large" (code header_too_large) before any parsing;
+	// oversized cookies on safe requests are ignored and replaced. Defaults
+	// (and useful maximums, as the parsers refuse longer values):
+	// MaxTokenLength, MaxTokenLength and MaxOriginLength.
+	MaxHeaderTokenBytes int
+	MaxCookieBytes      int
+	MaxOriginBytes      int
+
+	// BodyTooLargeStatus is the status returned when reading the form
+	// token hits an http.MaxBytesReader limit (e.g., the application wraps
+	// handlers with http.MaxBytesHandler), instead of a misleading "missing
+	// CSRF token" 403. Default: 413.
+	BodyTooLargeStatus int
+
+	// MalformedTokenStatus is the status for a structurally invalid client
+	// token (bad encoding, wrong length), typically a client bug, as opposed
+	// to a well-formed but mismatched one, typical of attacks. Set it to 400
+	// so API clients and monitoring can tell them apart; the reason
+	// "bad CSRF token (malformed)" (code malformed_token) is distinct either
+	// way and still matches bad-token checks. Default: 403.
+	MalformedTokenStatus int
+
 	// TokenBytes is the number of random bytes used to generate the token
 	// before base64url encoding (no padding).
 	// Default: 32.
 	TokenBytes int
+
+	// SkipContextInjection, when true, stops the middleware from storing the
+	// token in the request context. API-only deployments that never call
+	// TokenFromContext avoid the r.WithContext allocation on every request.
+	// TokenHandler still works because it falls back to the request cookie.
+	SkipContextInjection bool
+
+	// FailureLimiter, when set, records CSRF failures per client IP and
+	// rejects unsafe requests from clients that exhausted their allowance
+	// with 429 Too Many Requests. See NewMemoryLimiter for the default
+	// in-memory token bucket.
+	FailureLimiter Limiter
+
+	// FailureTarpit delays responses to clients denied by FailureLimiter,
+	// slowing down brute-force attempts and scanners. 0 disables the delay.
+	FailureTarpit time.Duration
+
+	// Blocklist, when set together with FailureLimiter, blocks a client as
+	// soon as the limiter denies it, i.e. once it used up the limiter's
+	// failure budget. Blocked clients get 403 on unsafe requests until the
+	// block expires. See NewMemoryBlockStore.
+	Blocklist BlockStore
+
+	// BlockDuration is how long a client stays on the Blocklist.
+	// Default: 15 minutes.
+	BlockDuration time.Duration
+
+	// OnBlock, when set, is called each time a client is added to the
+	// Blocklist so the event can be surfaced to ops tooling.
+	OnBlock func(key string, until time.Time)
+
+	// StoreTimeout bounds each call to an external store (Blocklist,
+	// FailureLimiter, TokenStore) on top of the request context's own deadline. Default:
+	// 1s when a store is configured; negative disables the extra bound.
+	StoreTimeout time.Duration
+
+	// BackendFailurePolicy decides what happens when a store call fails or
+	// times out: FailClosed (default) answers 500, FailOpen skips the
+	// failed check with a warning on Logger, FailOpenIdempotent does so only
+	// for idempotent-looking requests.
+	BackendFailurePolicy BackendFailurePolicy
+
+	// StoreBreaker, if set, stops calling the stores after repeated
+	// failures, degrading to the stateless double-submit validation until
+	// they recover. See CircuitBreaker.
+	StoreBreaker *CircuitBreaker
+
+	// DegradationFloor is the lowest SecurityLevel requests may be served
+	// at while stores fail: below it, requests get 500 regardless of
+	// BackendFailurePolicy and StoreBreaker. E.g. LevelStoreBacked never
+	// skips the stores, LevelSigned fails closed when tokens are unsigned.
+	// Zero allows any level.
+	DegradationFloor SecurityLevel
+
+	// OnLevelChange, if set, is called when the level requests are
+	// validated at changes (stores failing or recovering). It must not
+	// block. Transitions are also logged and counted.
+	OnLevelChange func(from, to SecurityLevel)
+
+	// Challenge, when set, is called instead of writing the 403 response
+	// once a client (by IP) has failed validation ChallengeAfter times
+	// within ChallengeWindow, with the failure count. It may send the
+	// client to a challenge flow (captcha page, step-up auth), e.g. with a
+	// redirect, and returns true if it wrote the response; false keeps the
+	// plain rejection. Counts are kept in memory, per instance, and cleared
+	// when the client passes validation.
+	Challenge func(w http.ResponseWriter, r *http.Request, failures int) bool
+
+	// ChallengeAfter is the failure count that triggers Challenge.
+	// Default: 5.
+	ChallengeAfter int
+
+	// ChallengeWindow is the window failures are counted in.
+	// Default: 10 minutes.
+	ChallengeWindow time.Duration
+
+	// OnReject, when set, is called for every request the middleware turns
+	// away, with the reason (e.g. missing or bad token, bad origin, rate
+	// limited), before the error response is written. Use it for logging
+	// and auditing.
+	OnReject func(r *http.Request, err error)
+
+	// OnRejectEvent, if set, receives a RejectionEvent for every rejection,
+	// after OnReject. Prefer it over building events by hand so all observers
+	// see the same redacted payload.
+	OnRejectEvent func(RejectionEvent)
+
+	// StatsWindow is the span of the rolling counts returned by Stats.
+	// Default: 1 hour.
+	StatsWindow time.Duration
+
+	// ErrorHandler, if set, writes the error responses of the middleware
+	// instead of the default plain-text http.Error, e.g. to return the
+	// application's JSON error envelope. It receives the status (403 for
+	// CSRF failures, 429 when rate limited, 500 when the cookie cannot be
+	// set or a required store is unavailable, ...) and the reason, after
+	// OnReject and OnRejectEvent ran. Use ReasonCode(err) for a stable
+	// code; err may carry diagnostics (see OriginError) and should not be
+	// echoed to clients as is.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+	// RequestIDHeader names the header carrying the request ID recorded in
+	// rejection events (default "X-Request-ID").
+	RequestIDHeader string
+
+	// RedactEventFields lists RejectionEvent fields (JSON names, e.g.
+	// "client_ip", "user_agent", "referer") blanked in every event. Unknown
+	// names are rejected by Validate. Redacting "origin", "referer" or
+	// "referer_host" also reduces Message to the bare reason.
+	RedactEventFields []string
+
+	// RefreshCookieOnFailure, when true, sets a freshly minted token cookie
+	// on the error response of a rejected request (unless the response already
+	// carries a new cookie), so the page the user retries from immediately has
+	// a valid token. Rate-limited and blocked clients never get a new cookie.
+	RefreshCookieOnFailure bool
+
+	// TrustedProxies lists the networks of reverse proxies whose
+	// X-Forwarded-For header is honored when resolving the client IP.
+	// When empty, the client IP is always taken from r.RemoteAddr.
+	TrustedProxies []net.IPNet
+
+	// HostResolver, if set, returns the host the client addressed, used
+	// wherever the middleware needs the request's host (the origin check
+	// baseline when AllowedOrigins is empty, captured replay URLs) in place
+	// of r.Host, for proxies that rewrite Host. An empty answer falls back
+	// to r.Host. ForwardedHostResolver(TrustedProxies) reads Forwarded and
+	// X-Forwarded-Host from trusted proxies; never trust those headers from
+	// arbitrary clients.
+	HostResolver func(*http.Request) string
+
+	// TrustedNetworks lists networks whose requests skip CSRF enforcement,
+	// e.g. internal cron callers and smoke tests inside the VPC. Matching uses
+	// the client IP resolved with TrustedProxies. The cookie is still issued.
+	TrustedNetworks []net.IPNet
+
+	// Exempt, when set, is consulted for unsafe requests; those for which it
+	// returns true skip CSRF enforcement. Use it for requests that are
+	// authenticated by other means, e.g. WebhookVerifier.Exempt for signed
+	// webhook deliveries.
+	Exempt func(r *http.Request) bool
+
+	// Attestor, when set, lets unsafe requests from native mobile apps that
+	// carry a valid attestation (App Attest, Play Integrity, verified by
+	// the application) skip the cookie-token check. Requests with browser
+	// fetch metadata (Sec-Fetch-*) are never exempted this way.
+	Attestor Attestor
+
+	// PushTokenPath, when set, is the path TokenHandler is mounted at (e.g.
+	// "/csrf-token"), advertised alongside HTML pages so SPAs get the token
+	// without an extra round trip after startup: page navigations get a
+	// "Link: <path>; rel=preload; as=fetch" header and, over HTTP/2 with
+	// server push, the endpoint response is pushed. Pushes are counted as
+	// "tokenPushed". See SameOriginSPA.
+	PushTokenPath string
+
+	// EarlyHintsToken, when true, sends a 103 Early Hints response ahead of
+	// page navigations that carry a token, with the token in HeaderName (and
+	// the PushTokenPath preload link), so frontends reading Early Hints can
+	// start mutations as soon as possible. The page response keeps the
+	// header. Counted as "earlyHints".
+	EarlyHintsToken bool
+
+	// TokenCORSOrigin, when set, is the SPA origin (scheme://host[:port])
+	// allowed to read TokenHandler responses cross-origin with credentials.
+	// See CrossSubdomainSPA.
+	TokenCORSOrigin string
+
+	// TokenEndpointSameSite, when true, refuses TokenHandler requests sent
+	// by other sites (Sec-Fetch-Site: cross-site, or a foreign Origin or
+	// Referer) with 403 "cross-site token request", so third-party pages
+	// cannot make browsers churn the token cookie. TokenCORSOrigin is still
+	// allowed.
+	TokenEndpointSameSite bool
+
+	// TokenEndpointLimiter, when set, rate-limits TokenHandler per client
+	// IP: every request served counts as one Fail, and clients the limiter
+	// denies get 429. Use a separate limiter from FailureLimiter, e.g.
+	// NewMemoryLimiter(30, 2*time.Second).
+	TokenEndpointLimiter Limiter
+
+	// TrackIssuedAt, when true, sets a companion cookie (CookieName + "_iat")
+	// holding the token's issuance time in Unix seconds, with the same
+	// attributes as the token cookie. See Protector.IssuedAt.
+	TrackIssuedAt bool
+
+	// MaxTokenAge, when positive, bounds the token lifetime everywhere (it
+	// implies TrackIssuedAt). Safe requests get a fresh token once it is exceeded;
+	// unsafe requests presenting an older token are rejected with a distinct
+	// "CSRF token expired" reason, so the UI can say "page expired" instead
+	// of "invalid token". The timestamp cookie is not signed: it supports
+	// UX, not security.
+	MaxTokenAge time.Duration
+
+	// RequireHeaderForBodyless makes body-less methods (DELETE) accept the
+	// token only from HeaderName: the form fallback is never tried for them,
+	// even when a body is present, and a missing header is rejected as
+	// "missing header token". Requests without a body are never form-parsed
+	// regardless of this flag.
+	RequireHeaderForBodyless bool
+
+	// HeaderOnlyAbove, when positive, is a Content-Length (in bytes) above
+	// which the token must come from HeaderName: the body is never read and
+	// a missing header is rejected as "missing header token", so large
+	// uploads without a token are turned away cheaply. Requests of unknown
+	// length (chunked) are not affected.
+	HeaderOnlyAbove int64
+
+	// IssueOnNavigationOnly restricts token minting on safe requests to HTML
+	// navigations (Sec-Fetch-Mode: navigate, Sec-Fetch-Dest: document or
+	// iframe, or an Accept header listing text/html), so API GETs and health
+	// checks do not cause pointless token generation and Set-Cookie headers.
+	// Other safe requests still see an existing token in the context.
+	// TokenHandler always issues.
+	IssueOnNavigationOnly bool
+
+	// IssuePredicate decides whether a safe request may mint a token
+	// (default DefaultIssuePredicate, which skips well-known health-check and
+	// monitoring user agents). It is combined with IssueOnNavigationOnly;
+	// TokenHandler always issues.
+	IssuePredicate func(*http.Request) bool
+
+	// ReportOnly runs the origin and token checks but lets failing unsafe
+	// requests through (Blocklist and FailureLimiter still apply):
+	// failures are only passed to OnReject and OnRejectEvent and counted
+	// as "reported", for rolling the middleware out without breaking
+	// clients. Rule.ReportOnly does the same for a group of routes.
+	ReportOnly bool
+
+	// StatusHeader, when set (e.g., "X-CSRF-Protected"), names a response
+	// header reporting the protection applied to the request: "enforce",
+	// "report-only" or "skipped", so QA and scanners can verify coverage.
+	// It reveals configuration; enable it in internal environments only.
+	StatusHeader string
+
+	// ForwardAssertion, when set (e.g., "X-CSRF-Assertion"), names a
+	// request header carrying the middleware's verdict to the next handler
+	// ("safe", "validated", "skipped" or "reported"), for a
+	// backend-for-frontend proxying to internal services that verify it
+	// with BFFVerifier. Any incoming copy of the header is removed first.
+	ForwardAssertion string
+
+	// AssertionKey, when set, signs the ForwardAssertion value with
+	// HMAC-SHA256 as "<verdict>.<unix>.<mac>", bound to the request method
+	// and path, for upstreams using VerifyForwardedAssertion. Share it only
+	// with those services.
+	AssertionKey []byte
+
+	// DeviceCookie, when set, names a cookie the application sets with a
+	// stable device identifier (ideally HttpOnly, long-lived). Tokens are
+	// then bound to it: on issuance an HttpOnly companion cookie
+	// (CookieName + "_dev") carries an HMAC of the token and the device
+	// identifier under DeviceKey, and unsafe requests whose token was
+	// issued to another device get 403 "CSRF token bound to another
+	// device" (reason "device_mismatch"). A token stolen by script on a
+	// sibling subdomain can thus not be replayed from another browser
+	// profile. Safe requests carrying an unbound token are given a new one.
+	DeviceCookie string
+
+	// DeviceKey is the HMAC key for DeviceCookie bindings, at least 32
+	// bytes; share it across instances.
+	DeviceKey []byte
+
+	// CookieNameFunc, when set, names the token cookie per request (e.g.,
+	// "csrf_" + tenant ID) so tenants or apps sharing a parent domain each
+	// get their own token. Results that are not valid cookie names, or "",
+	// fall back to CookieName. It must be cheap and deterministic: it runs
+	// several times per request. Companion cookies (issuance time, form
+	// stash) follow the same name.
+	CookieNameFunc func(r *http.Request) string
+
+	// CoalesceIssuance, when positive, is the window in which concurrent
+	// requests of one client replacing its stale cookie (e.g. after a key
+	// rotation) share a single new token instead of each minting its own
+	// and racing their Set-Cookie headers. Clients are recognized by the
+	// stale cookie they sent, with their IP and User-Agent, across
+	// connections; requests without a cookie are never coalesced, since
+	// users behind one NAT would share a token. Typical: 2s.
+	CoalesceIssuance time.Duration
+
+	// DeferIssuance keeps safe responses cookie-free so HTML pages stay
+	// cacheable at the edge: tokens are minted only by TokenHandler or by
+	// the first unsafe request. A request rejected for its token then
+	// carries the newly minted token in the HeaderName response header, so
+	// the client can retry once with it (see README, "Deferred issuance").
+	DeferIssuance bool
+
+	// SkipCookieOnHEAD and SkipCookieOnOPTIONS stop HEAD and OPTIONS
+	// requests from minting a token, so no Set-Cookie ends up on responses
+	// CDNs may cache. SkipCookieOnPreflight does the same for CORS
+	// preflights only (OPTIONS with Origin and
+	// Access-Control-Request-Method). An existing token is still placed in
+	// the context.
+	SkipCookieOnHEAD      bool
+	SkipCookieOnOPTIONS   bool
+	SkipCookieOnPreflight bool
+
+	// PreflightPassthrough, when true, hands CORS preflights (OPTIONS with
+	// Origin and Access-Control-Request-Method) straight to the next
+	// handler, typically a CORS middleware inside Protect: no cookie, no
+	// context, no status header. Preflights carry no credentials and never
+	// reach application code, so there is nothing to protect.
+	PreflightPassthrough bool
+
+	// CacheSafety marks responses on which the middleware sets a token
+	// cookie so shared caches do not serve them to other users:
+	// CacheSafetyPrivate sets Cache-Control: private, CacheSafetyVary
+	// appends Vary: Cookie. Default: CacheSafetyOff.
+	CacheSafety CacheSafety
+
+	// FormStashKey, when set (16, 24 or 32 bytes), enables form
+	// re-population: a form post rejected for a missing, bad, expired or
+	// stale token has its non-sensitive fields (not the token, nor names
+	// suggesting passwords, cards or codes) stashed for five minutes in an
+	// AES-GCM encrypted cookie "<CookieName>_form", which the retry page
+	// reads with StashedForm. Only same-site posts are stashed.
+	FormStashKey []byte
+
+	// FormStashMaxBytes caps the encrypted stash cookie (default 2048);
+	// larger forms are not stashed.
+	FormStashMaxBytes int
+
+	// OnSessionRenew rotates the application's session (e.g., regenerates
+	// the session ID and sets its cookie). RenewSession calls it before
+	// rotating the CSRF token so both change together on authentication.
+	OnSessionRenew func(w http.ResponseWriter, r *http.Request) error
+
+	// FaultInjector, for chaos testing only, forces token generation
+	// failures and rejections of valid requests at configured rates.
+	FaultInjector *FaultInjector
+
+	// Logger receives operational warnings, such as the use of deprecated
+	// fields (default slog.Default()).
+	Logger *slog.Logger
+
+	// Rules customize enforcement per route group; the first matching rule
+	// applies. See Rule.
+	Rules []Rule
+
+	// MaxTokenAgeForSensitiveRoutes is the maximum token age accepted on
+	// routes matched by a Rule with Sensitive set (and no MaxTokenAge of its
+	// own), forcing a fresh page load before destructive actions. Tokens of
+	// unknown age are refused on those routes. It implies TrackIssuedAt.
+	MaxTokenAgeForSensitiveRoutes time.Duration
+
+	// RoutePattern, when set, labels the issued, validated and rejected
+	// counters by route template (see RouteStats and DebugHandler). It must
+	// return a low-cardinality template such as "POST /items/{id}", never
+	// the raw path; use MuxPattern for a ServeMux. Requests it returns ""
+	// for are counted as RouteUnmatched.
+	RoutePattern func(*http.Request) string
+
+	// TokenStore keeps the single-use form nonces of IssueFormNonce and
+	// ConsumeFormNonce. See NewMemoryTokenStore; use shared storage when
+	// several instances serve the same users.
+	TokenStore TokenStore
+
+	// SessionTokenStore, when set, switches to the synchronizer token
+	// pattern: tokens are kept server-side, one per session (as identified
+	// by SessionID), and unsafe requests are checked against the stored
+	// token instead of the cookie, which is no longer set. Clients get the
+	// token from the request context (form field, template), TokenHandler
+	// or PushTokenPath. Requests without a session get no token and unsafe
+	// ones are rejected with ErrNoSession. Store failures, and calls
+	// skipped while StoreBreaker is open, answer 500 with ErrUnavailable
+	// whatever BackendFailurePolicy says, since the stored token is the
+	// check itself. Cannot be combined with DeviceCookie, TrackIssuedAt or
+	// MaxTokenAge. See NewMemoryTokenStore.
+	SessionTokenStore SessionTokenStore
+
+	// SessionID returns the session of a request for SessionTokenStore
+	// (e.g., the session ID from the application's session middleware), or
+	// "" when there is none. Never derive it from client-chosen values
+	// that are not authenticated by the session layer.
+	SessionID func(*http.Request) string
+
+	// SessionTokenTTL is how long a stored session token lives; issuing a
+	// token for the session renews it. Default: 24 hours.
+	SessionTokenTTL time.Duration
+
+	// FormNonceTTL is how long an issued form nonce stays valid.
+	// Default: 10 minutes.
+	FormNonceTTL time.Duration
+
+	// FormNonceField is the form field carrying the nonce.
+	// Default: "csrf_nonce".
+	FormNonceField string
+
+	// ReplayCapture, when positive, keeps a sanitized copy of this many most
+	// recent rejected requests for ReplayHandler, which exports them as HAR
+	// or curl commands. Only CSRF-relevant values are kept (token cookies,
+	// header and fields, Origin, Referer, Sec-Fetch-*, Content-Type,
+	// User-Agent); all other query, header, cookie and form values are
+	// redacted. It is a debugging aid; leave it off in production unless
+	// ReplayHandler is reachable from internal networks only.
+	ReplayCapture int
+
+	// Profiles holds named alternative configurations (e.g. "dev", "staging",
+	// "prod") selected with NewProfile or NewFromEnv. A selected profile
+	// replaces the whole Config; profiles are not merged with the base.
+	Profiles map[string]Config
 }
 
 type Protector struct {
 	cfg Config
+
+	// sameSiteReason explains how CookieSameSite was chosen (for SelfCheck).
+	sameSiteReason string
+
+	// cookieSuffix is the pre-rendered attribute portion of the Set-Cookie
+	// header (everything after "name=value").
+	cookieSuffix string
+
+	// scopes are the cookie scopes of CookieScope rules.
+	scopes []cookieScope
+
+	// originPatterns are the compiled AllowedOriginPatterns.
+	originPatterns []originPattern
+
+	// keys holds the signing key ring; it is shared with clones that keep
+	// the same keys (see Clone).
+	keys *keySource
+
+	// pool holds pre-generated tokens (nil when TokenPoolSize is 0).
+	pool *tokenPool
+
+	// originCounts counts accepted requests per allowlist entry (see
+	// OriginMatches).
+	originCounts originCounts
+
+	// originCache memoizes origin check results (nil when disabled).
+	originCache *stringLRU
+
+	stats counters
+
+	// level is the current SecurityLevel (see degrade).
+	level atomic.Int32
+
+	// coalescer shares tokens minted concurrently for one client (nil when
+	// CoalesceIssuance is unset).
+	coalescer *issueCoalescer
+
+	// failures counts failures per client for Challenge (nil when unset).
+	failures *failureCounter
+
+	// routes holds the per-route counters (see RoutePattern).
+	routes routeTable
+
+	// replay keeps the captured rejected requests (nil when ReplayCapture
+	// is unset).
+	replay *replayLog
+
+	// window holds the rolling-window counts of Stats.
+	window *statsWindow
 }
 
 // New receives a Config (cfg) with cookie, transport and security settings,
 // applies reasonable defaults when fields are empty, and returns a configured
-// *Protector ready to be used as middleware. It never returns nil.
+// *Protector ready to be used as middleware. It never returns nil; it panics
+// if cfg.Validate reports an error (too short signing keys, overly broad
+// origin patterns, ...), since that is a programming mistake. Use TryNew
+// for configurations loaded at runtime.
 //
 // Params:
 // - cfg: configuration values (cookie options, header/form names, security flags).
@@ -85,6 +681,29 @@ type Protector struct {
 // Returns:
 // - *Protector with defaults applied.
 func New(cfg Config) *Protector {
+	p, err := TryNew(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// TryNew is New returning the error of cfg.Validate instead of panicking,
+// for configurations read from files, flags or the environment.
+//
+// Params:
+// - cfg: configuration values.
+//
+// Returns:
+// - *Protector with defaults applied, or nil and the validation error.
+func TryNew(cfg Config) (*Protector, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	migrate(&cfg, cfg.Logger)
 	// reasonable defaults
 	if cfg.CookieName == "" {
 		cfg.CookieName = "csrf_token"
@@ -92,8 +711,23 @@ func New(cfg Config) *Protector {
 	if cfg.HeaderName == "" {
 		cfg.HeaderName = "X-CSRF-Token"
 	}
-	if cfg.FormField == "" {
-		cfg.FormField = "csrf_token"
+	if len(cfg.FormFields) == 0 {
+		cfg.FormFields = []string{"csrf_token"}
+	}
+	if cfg.FormNonceTTL <= 0 {
+		cfg.FormNonceTTL = defaultFormNonceTTL
+	}
+	if cfg.FormNonceField == "" {
+		cfg.FormNonceField = "csrf_nonce"
+	}
+	if cfg.FormStashMaxBytes <= 0 {
+		cfg.FormStashMaxBytes = defaultFormStashMaxBytes
+	}
+	if cfg.StatsWindow <= 0 {
+		cfg.StatsWindow = defaultStatsWindow
+	}
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = "X-Request-ID"
 	}
 	if cfg.CookiePath == "" {
 		cfg.CookiePath = "/"
@@ -101,9 +735,237 @@ func New(cfg Config) *Protector {
 	if cfg.TokenBytes <= 0 {
 		cfg.TokenBytes = 32
 	}
-	// modern web security: SameSite=Lax is a good baseline
-	if cfg.CookieSameSite == 0 {
+	if cfg.MalformedTokenStatus == 0 {
+		cfg.MalformedTokenStatus = http.StatusForbidden
+	}
+	if cfg.BodyTooLargeStatus == 0 {
+		cfg.BodyTooLargeStatus = http.StatusRequestEntityTooLarge
+	}
+	if cfg.MaxHeaderTokenBytes <= 0 {
+		cfg.MaxHeaderTokenBytes = MaxTokenLength
+	}
+	if cfg.MaxCookieBytes <= 0 {
+		cfg.MaxCookieBytes = MaxTokenLength
+	}
+	if cfg.MaxOriginBytes <= 0 {
+		cfg.MaxOriginBytes = MaxOriginLength
+	}
+	if cfg.needsIssuedAt() {
+		cfg.TrackIssuedAt = true
+	}
+	if cfg.SessionTokenStore != nil && cfg.SessionTokenTTL <= 0 {
+		cfg.SessionTokenTTL = defaultSessionTokenTTL
+	}
+	if (cfg.Blocklist != nil || cfg.FailureLimiter != nil || cfg.TokenStore != nil || cfg.SessionTokenStore != nil) && cfg.StoreTimeout == 0 {
+		cfg.StoreTimeout = defaultStoreTimeout
+	}
+	if cfg.Challenge != nil && cfg.ChallengeAfter <= 0 {
+		cfg.ChallengeAfter = defaultChallengeAfter
+	}
+	if cfg.Challenge != nil && cfg.ChallengeWindow <= 0 {
+		cfg.ChallengeWindow = defaultChallengeWindow
+	}
+	if cfg.Blocklist != nil && cfg.BlockDuration <= 0 {
+		cfg.BlockDuration = 15 * time.Minute
+	}
+	sameSiteReason := "configured explicitly"
+	switch {
+	case cfg.CookieSameSite != 0:
+	case cfg.AutoSameSite && cfg.TokenCORSOrigin != "":
+		cfg.CookieSameSite = http.SameSiteNoneMode
+		cfg.CookieSecure = true
+		sameSiteReason = "auto: cross-origin SPA (Secure forced)"
+	case cfg.AutoSameSite && cfg.CookieDomain == "":
+		cfg.CookieSameSite = http.SameSiteStrictMode
+		sameSiteReason = "auto: host-only cookie"
+	case cfg.AutoSameSite:
+		cfg.CookieSameSite = http.SameSiteLaxMode
+		sameSiteReason = "auto: cookie shared via CookieDomain"
+	default:
+		// modern web security: SameSite=Lax is a good baseline
 		cfg.CookieSameSite = http.SameSiteLaxMode
+		sameSiteReason = "default"
+	}
+	if cfg.CookieSameSite == http.SameSiteNoneMode && !cfg.CookieSecure {
+		// browsers drop SameSite=None cookies without Secure
+		cfg.CookieSecure = true
+		sameSiteReason += "; Secure forced by SameSite=None"
+		cfg.Logger.Warn("csrf: CookieSameSite=None requires CookieSecure; enabling it")
+	}
+	p := &Protector{
+		cfg:            cfg,
+		sameSiteReason: sameSiteReason,
+		cookieSuffix:   renderCookieSuffix(cfg),
+		scopes:         compileScopes(cfg),
+		coalescer:      newIssueCoalescer(cfg.CoalesceIssuance),
+		originCache:    newStringLRU(cfg.OriginCacheSize),
+		originCounts:   newOriginCounts(cfg),
+		pool:           newTokenPool(cfg.TokenPoolSize, cfg.TokenBytes),
+		keys:           newKeySource(cfg),
+		replay:         newReplayLog(cfg.ReplayCapture),
+		window:         newStatsWindow(cfg.StatsWindow),
+	}
+	for _, raw := range cfg.AllowedOriginPatterns {
+		pat, _ := compileOriginPattern(raw) // checked by Validate
+		p.originPatterns = append(p.originPatterns, pat)
+	}
+	p.level.Store(int32(p.fullLevel()))
+	if cfg.Challenge != nil {
+		p.failures = newFailureCounter(cfg.ChallengeWindow)
+	}
+	return p, nil
+}
+
+// Validate reports configuration errors that New refuses to accept, such as
+// overly broad AllowedOriginPatterns.
+//
+// Returns:
+// - nil if cfg is acceptable; otherwise the first error found.
+func (cfg Config) Validate() error {
+	if err := validateKeyring(cfg); err != nil {
+		return err
+	}
+	if strings.Contains(cfg.Region, ".") {
+		return fmt.Errorf("csrf: Region %q must not contain \".\"", cfg.Region)
+	}
+	switch len(cfg.FormStashKey) {
+	case 0, 16, 24, 32:
+	default:
+		return fmt.Errorf("csrf: FormStashKey must be 16, 24 or 32 bytes, got %d", len(cfg.FormStashKey))
 	}
-	return &Protector{cfg: cfg}
+	for _, raw := range cfg.AllowedOriginPatterns {
+		if _, err := compileOriginPattern(raw); err != nil {
+			return err
+		}
+	}
+	if err := validateExtensionIDs(cfg); err != nil {
+		return err
+	}
+	if err := validateDevice(cfg); err != nil {
+		return err
+	}
+	if err := validateSynchronizer(cfg); err != nil {
+		return err
+	}
+	if err := validateRedactFields(cfg); err != nil {
+		return err
+	}
+	return validateScopes(cfg)
+}
+
+// CrossSubdomainSPA returns a Config for a single-page app served from
+// spaHost (e.g., "app.example.com") calling an API on another subdomain of
+// parentDomain (e.g., "example.com"). It combines the settings that must
+// agree for this topology:
+//   - the cookie is scoped to parentDomain so both hosts share it;
+//   - SameSite=None with Secure (via AutoSameSite);
+//   - the origin check accepts only the SPA host;
+//   - TokenHandler answers the SPA origin with credentialed CORS headers.
+//
+// The result can be adjusted before passing it to New.
+//
+// Params:
+// - parentDomain: cookie Domain shared by the SPA and API hosts.
+// - spaHost: host[:port] the SPA is served from (HTTPS is assumed).
+//
+// Returns:
+// - the preset Config.
+func CrossSubdomainSPA(parentDomain, spaHost string) Config {
+	return Config{
+		CookieDomain:       parentDomain,
+		CookieSecure:       true,
+		AutoSameSite:       true,
+		EnforceOriginCheck: true,
+		AllowedOrigins:     []string{spaHost},
+		TokenCORSOrigin:    "https://" + spaHost,
+	}
+}
+
+// SameOriginSPA returns a preset Config for a single-page app served from
+// the same origin as its API: the cookie is HttpOnly, the origin is
+// checked, and the token endpoint at tokenPath (mount TokenHandler there)
+// is advertised with each page, so the app reads the token from it without
+// waiting for an extra round trip (see PushTokenPath).
+//
+// The result can be adjusted before passing it to New.
+//
+// Params:
+// - tokenPath: path TokenHandler is mounted at (e.g., "/csrf-token").
+//
+// Returns:
+// - the preset Config.
+func SameOriginSPA(tokenPath string) Config {
+	return Config{
+		CookieSecure:       true,
+		CookieHTTPOnly:     true,
+		AutoSameSite:       true,
+		EnforceOriginCheck: true,
+		PushTokenPath:      tokenPath,
+	}
+}
+
+// NewProfile returns a Protector built from the profile called name in
+// cfg.Profiles. When name is empty, cfg itself is used. Like New for an
+// invalid configuration, it panics when name is not in cfg.Profiles, so a
+// misspelled environment never silently falls back to the base hardening;
+// TryNewProfile returns the error instead.
+//
+// Params:
+// - cfg: base configuration carrying the Profiles map.
+// - name: profile name (e.g., "prod").
+//
+// Returns:
+// - *Protector built with New from the selected configuration.
+func NewProfile(cfg Config, name string) *Protector {
+	p, err := TryNewProfile(cfg, name)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// TryNewProfile is NewProfile returning an error for an unknown profile
+// name or an invalid configuration instead of panicking.
+//
+// Params:
+// - cfg: base configuration carrying the Profiles map.
+// - name: profile name, or "" for cfg itself.
+//
+// Returns:
+// - *Protector built with TryNew from the selected configuration, or nil
+// and the error.
+func TryNewProfile(cfg Config, name string) (*Protector, error) {
+	if name != "" {
+		prof, ok := cfg.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("csrf: unknown profile %q", name)
+		}
+		cfg = prof
+	}
+	cfg.Profiles = nil
+	return TryNew(cfg)
+}
+
+// NewFromEnv is like NewProfile, taking the profile name from the
+// environment variable envVar (e.g., "APP_ENV"). An unset or empty
+// variable selects the base configuration; an unknown name panics (use
+// TryNewProfile(cfg, os.Getenv(envVar)) to get an error instead).
+//
+// Params:
+// - cfg: base configuration carrying the Profiles map.
+// - envVar: name of the environment variable holding the profile name.
+//
+// Returns:
+// - *Protector built from the selected configuration.
+func NewFromEnv(cfg Config, envVar string) *Protector {
+	return NewProfile(cfg, os.Getenv(envVar))
+}
+
+// Config returns the effective configuration of p, i.e. the Config passed to
+// New with all defaults and automatic decisions applied.
+//
+// Returns:
+// - a deep copy of the effective Config; changing it does not affect p.
+func (p *Protector) Config() Config {
+	return cloneConfig(p.cfg)
 }
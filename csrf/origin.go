@@ -0,0 +1,202 @@
+package csrf
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// OriginError is returned (wrapped in the rejection reason passed to OnReject)
+// when the Origin or Referer check fails. It records what was observed and
+// what was expected so operators can tell exactly what to add to the
+// allowlist. Use errors.As to access it.
+type OriginError struct {
+	// Reason is the underlying rejection reason (bad origin or bad referer).
+	Reason error
+	// Header is the header that was checked: "Origin" or "Referer".
+	Header string
+	// Got is the host observed in Header (or the raw value if unparseable).
+	Got string
+	// Expected lists the hosts that would have been accepted; it is empty
+	// when OriginComparator made the decision.
+	Expected []string
+}
+
+// Error describes the mismatch, e.g.:
+// bad origin: got "evil.com", expected "app.example.com".
+func (e *OriginError) Error() string {
+	if len(e.Expected) == 0 {
+		return fmt.Sprintf("%s: got %q", e.Reason, e.Got)
+	}
+	return fmt.Sprintf("%s: got %q, expected %q", e.Reason, e.Got, strings.Join(e.Expected, ", "))
+}
+
+// Unwrap returns Reason so errors.Is matches the rejection reason.
+func (e *OriginError) Unwrap() error { return e.Reason }
+
+// validateOriginOrReferer checks whether the request is same-site according to
+// the allowed host policy. When AllowedOrigins is empty, it falls back to
+// the request host (see HostResolver). When OriginComparator is set, it
+// decides instead of the host comparison. Extension and file origins are
+// accepted only as configured by AllowedExtensionIDs and AllowFileOrigin. It
+// prefers the Origin header; if empty, it falls back to Referer.
+//
+// Params:
+//   - r: the incoming request containing Origin/Referer headers.
+//
+// Returns:
+//   - nil when origin/referrer is acceptable; ErrNoOrigin when both are absent;
+//     otherwise an *OriginError describing the mismatch.
+func (p *Protector) validateOriginOrReferer(r *http.Request) error {
+	_, err := p.matchOrigin(r)
+	return err
+}
+
+// matchOrigin runs the check of validateOriginOrReferer and names the
+// allowlist entry that accepted the request (see OriginMatches).
+//
+// Params:
+//   - r: the incoming request containing Origin/Referer headers.
+//
+// Returns:
+//   - the matched entry when acceptable; otherwise the error of
+//     validateOriginOrReferer.
+func (p *Protector) matchOrigin(r *http.Request) (string, error) {
+	// if allowed is empty, use the current request host as baseline
+	reqHost := p.requestHost(r)
+	hosts := p.cfg.AllowedOrigins
+	if len(hosts) == 0 {
+		hosts = []string{reqHost}
+	}
+
+	// Prefer Origin; if empty, use Referer.
+	header, value := "Origin", r.Header.Get("Origin")
+	reason := ErrBadOrigin
+	if value == "" {
+		header, value = "Referer", r.Header.Get("Referer")
+		reason = ErrBadReferer
+	}
+	if value == "" {
+		return "", ErrNoOrigin
+	}
+	if entry := p.appOriginMatch(value); entry != "" {
+		return entry, nil
+	}
+
+	if cmp := p.cfg.OriginComparator; cmp != nil {
+		if u, err := ParseOrigin(value); err != nil || !cmp(u, r) {
+			return "", &OriginError{Reason: reason, Header: header, Got: observedHost(value)}
+		}
+		return OriginMatchComparator, nil
+	}
+	if entry := p.originMatch(value, hosts, reqHost); entry != "" {
+		return entry, nil
+	}
+	expected := slices.Clone(hosts)
+	for _, pat := range p.originPatterns {
+		expected = append(expected, pat.raw)
+	}
+	return "", &OriginError{Reason: reason, Header: header, Got: observedHost(value), Expected: expected}
+}
+
+// originMatch returns the entry accepting value: the matching origin
+// pattern or host, or OriginMatchRequestHost when hosts is the request host
+// fallback. Results are memoized in the origin cache (when OriginCacheSize
+// is set), keyed by the header value and, when hosts falls back to the
+// request host, by that host too.
+//
+// Params:
+// - value: Origin or Referer header value.
+// - hosts: allowed hosts.
+// - reqHost: the request host (part of the cache key when AllowedOrigins is empty).
+//
+// Returns:
+// - the matched entry, or "" if value is not acceptable.
+func (p *Protector) originMatch(value string, hosts []string, reqHost string) string {
+	key := value
+	if len(p.cfg.AllowedOrigins) == 0 {
+		key = reqHost + " " + value
+	}
+	if entry, hit := p.originCache.get(key); hit {
+		return entry
+	}
+	entry := p.matchOriginPattern(value)
+	for _, host := range hosts {
+		if entry != "" {
+			break
+		}
+		if sameSite(value, host) {
+			entry = host
+			if len(p.cfg.AllowedOrigins) == 0 {
+				entry = OriginMatchRequestHost
+			}
+		}
+	}
+	p.originCache.put(key, entry)
+	return entry
+}
+
+// sameSite checks if originOrRef is same-site with the allowed host.
+// It compares only the host (which may include the port) of http and https
+// URLs.
+//
+// Params:
+// - originOrRef: Origin or Referer URL string.
+// - allowedHost: the host to consider same-site against.
+//
+// Returns:
+// - true if the parsed URL host matches allowedHost (case-insensitive); false otherwise.
+func sameSite(originOrRef, allowedHost string) bool {
+	u, err := ParseOrigin(originOrRef)
+	if err != nil || !webScheme(u.Scheme) {
+		return false
+	}
+	// Compara apenas host (pode incluir porta). Opcional: normalizar porta padrão.
+	return strings.EqualFold(u.Host, allowedHost)
+}
+
+// matchOriginPattern returns the first AllowedOriginPatterns entry matching
+// the host of originOrRef, or "".
+func (p *Protector) matchOriginPattern(originOrRef string) string {
+	if len(p.originPatterns) == 0 {
+		return ""
+	}
+	u, err := ParseOrigin(originOrRef)
+	if err != nil || !webScheme(u.Scheme) {
+		return ""
+	}
+	for _, pat := range p.originPatterns {
+		if pat.match(u.Host) {
+			return pat.raw
+		}
+	}
+	return ""
+}
+
+// maxObservedLength caps raw header values echoed in diagnostics.
+const maxObservedLength = 256
+
+// observedHost returns the host of an Origin/Referer value for diagnostics,
+// or the raw value (truncated to maxObservedLength) when it has no
+// parseable host.
+func observedHost(v string) string {
+	if u, err := ParseOrigin(v); err == nil {
+		return u.Host
+	}
+	if len(v) > maxObservedLength {
+		return strings.ToValidUTF8(v[:maxObservedLength], "") + "..."
+	}
+	return v
+}
+
+// publicReason returns the rejection reason without diagnostic details, for
+// the response body sent to the client.
+func publicReason(err error) error {
+	var oe *OriginError
+	if errors.As(err, &oe) {
+		return oe.Reason
+	}
+	return err
+}
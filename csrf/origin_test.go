@@ -0,0 +1,164 @@
+package csrf
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Origin failures carry the observed and expected hosts to OnReject, while the
+// response body stays generic.
+func TestOriginMismatchDiagnostics(t *testing.T) {
+	var got error
+	p := New(Config{
+		EnforceOriginCheck: true,
+		AllowedOrigin:      "app.example.com",
+		OnReject:           func(r *http.Request, err error) { got = err },
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.Header.Set("Origin", "https://evil.example.net")
+	appHandler(p).ServeHTTP(rec, req)
+
+	var oe *OriginError
+	if !errors.As(got, &oe) || !errors.Is(got, ErrBadOrigin) {
+		t.Fatalf("expected *OriginError wrapping ErrBadOrigin, got %v", got)
+	}
+	if oe.Header != "Origin" || oe.Got != "evil.example.net" || oe.Expected[0] != "app.example.com" {
+		t.Fatalf("unexpected diagnostics: %+v", oe)
+	}
+	if !strings.Contains(got.Error(), `got "evil.example.net", expected "app.example.com"`) {
+		t.Fatalf("unexpected message: %q", got.Error())
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "bad origin" {
+		t.Fatalf("response body must not leak diagnostics, got %q", body)
+	}
+}
+
+// OriginComparator overrides the built-in host comparison.
+func TestOriginComparator(t *testing.T) {
+	p := New(Config{
+		EnforceOriginCheck: true,
+		TokenBytes:         16,
+		OriginComparator: func(origin *url.URL, r *http.Request) bool {
+			return strings.HasSuffix(origin.Hostname(), ".ngrok.io")
+		},
+	})
+	app := appHandler(p)
+	token, _ := newToken(16)
+
+	for origin, want := range map[string]int{
+		"https://abc123.ngrok.io": http.StatusOK,
+		"https://example.com":     http.StatusForbidden,
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.Host = "example.com"
+		req.Header.Set("Origin", origin)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+		req.Header.Set("X-CSRF-Token", token)
+		app.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("origin %s: got %d want %d", origin, rec.Code, want)
+		}
+	}
+}
+
+// Cached origin results match uncached ones, including the request-host
+// fallback, and the cache stays bounded.
+func TestOriginCache(t *testing.T) {
+	p := New(Config{EnforceOriginCheck: true, OriginCacheSize: 2})
+	check := func(host, origin string) error {
+		req := httptest.NewRequest(http.MethodPost, "http://"+host+"/", nil)
+		req.Header.Set("Origin", origin)
+		return p.validateOriginOrReferer(req)
+	}
+	for i := 0; i < 2; i++ {
+		if err := check("a.example.com", "https://a.example.com"); err != nil {
+			t.Fatalf("expected same host to pass, got %v", err)
+		}
+		if err := check("b.example.com", "https://a.example.com"); err == nil {
+			t.Fatal("expected other host to fail despite the cached result")
+		}
+	}
+	check("c.example.com", "https://c.example.com")
+	if n := p.originCache.len(); n != 2 {
+		t.Fatalf("expected the cache to hold 2 entries, got %d", n)
+	}
+}
+
+// Extension and file origins are rejected unless explicitly allowed, even
+// when their ID equals an allowed host.
+func TestAppOrigins(t *testing.T) {
+	const ext = "abcdefghijklmnopabcdefghijklmnop"
+	check := func(p *Protector, header, value string) error {
+		req := httptest.NewRequest(http.MethodPost, "http://api.example.com/", nil)
+		req.Header.Set(header, value)
+		return p.validateOriginOrReferer(req)
+	}
+	strict := New(Config{EnforceOriginCheck: true, AllowedOrigins: []string{"api.example.com", ext}})
+	for _, origin := range []string{"chrome-extension://" + ext, "moz-extension://api.example.com", "file://", "null"} {
+		if err := check(strict, "Origin", origin); err == nil {
+			t.Errorf("origin %s accepted by default", origin)
+		}
+	}
+
+	open := New(Config{EnforceOriginCheck: true, AllowedExtensionIDs: []string{ext}, AllowFileOrigin: true})
+	for _, tc := range []struct {
+		header, value string
+		ok            bool
+	}{
+		{"Origin", "chrome-extension://" + ext, true},
+		{"Referer", "chrome-extension://" + ext + "/popup.html", true},
+		{"Origin", "chrome-extension://ponmlkjihgfedcbaponmlkjihgfedcba", false},
+		{"Origin", "https://" + ext, false},
+		{"Origin", "file://", true},
+		{"Origin", "null", false},
+	} {
+		if err := check(open, tc.header, tc.value); (err == nil) != tc.ok {
+			t.Errorf("%s %s: got %v, want ok=%v", tc.header, tc.value, err, tc.ok)
+		}
+	}
+
+	if err := (Config{AllowedExtensionIDs: []string{"chrome-extension://" + ext}}).Validate(); err == nil {
+		t.Fatal("origin accepted as extension ID")
+	}
+}
+
+// Accepted requests are counted per matching allowlist entry, and unused
+// entries are listed with zero.
+func TestOriginMatches(t *testing.T) {
+	var hooked []string
+	p := New(Config{
+		EnforceOriginCheck:    true,
+		AllowedOrigins:        []string{"app.example.com", "old.example.com"},
+		AllowedOriginPatterns: []string{"pr-*.preview.example.com"},
+		OnOriginMatch:         func(_ *http.Request, entry string) { hooked = append(hooked, entry) },
+	})
+	app := p.Protect(appHandler(p))
+	token, _ := newToken(32)
+	for _, origin := range []string{"https://app.example.com", "https://pr-1.preview.example.com", "https://pr-2.preview.example.com", "https://evil.com"} {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.Header.Set("Origin", origin)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+		req.Header.Set("X-CSRF-Token", token)
+		app.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	want := map[string]int64{"app.example.com": 1, "old.example.com": 0, "pr-*.preview.example.com": 2}
+	if got := p.OriginMatches(); !maps.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if len(hooked) != 3 || hooked[0] != "app.example.com" {
+		t.Fatalf("unexpected hook calls %v", hooked)
+	}
+
+	fallback := New(Config{EnforceOriginCheck: true})
+	if _, ok := fallback.OriginMatches()[OriginMatchRequestHost]; !ok {
+		t.Fatal("request host fallback not listed")
+	}
+}
@@ -0,0 +1,87 @@
+package csrf
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Entries reported by OriginMatches for acceptances that no allowlist entry
+// of the configuration names.
+const (
+	// OriginMatchRequestHost is the fallback to the request host used when
+	// AllowedOrigins is empty.
+	OriginMatchRequestHost = "<request host>"
+
+	// OriginMatchComparator stands for OriginComparator decisions.
+	OriginMatchComparator = "<comparator>"
+)
+
+// originCounts counts accepted unsafe requests per allowlist entry. The
+// entries are fixed by New, so the map is only read afterwards.
+type originCounts map[string]*atomic.Int64
+
+// newOriginCounts returns counters for every entry cfg can match, so
+// entries never matched show up with a zero count, or nil when the origin
+// check is disabled.
+func newOriginCounts(cfg Config) originCounts {
+	if !cfg.EnforceOriginCheck {
+		return nil
+	}
+	c := originCounts{}
+	add := func(entry string) { c[entry] = new(atomic.Int64) }
+	switch {
+	case cfg.OriginComparator != nil:
+		add(OriginMatchComparator)
+	case len(cfg.AllowedOrigins) == 0:
+		add(OriginMatchRequestHost)
+	}
+	for _, h := range cfg.AllowedOrigins {
+		add(h)
+	}
+	for _, pat := range cfg.AllowedOriginPatterns {
+		add(pat)
+	}
+	for _, id := range cfg.AllowedExtensionIDs {
+		add(extensionEntry(id))
+	}
+	if cfg.AllowFileOrigin {
+		add(fileOrigin)
+	}
+	return c
+}
+
+// OriginMatches returns, per allowlist entry (AllowedOrigins host,
+// AllowedOriginPatterns pattern, "extension:<id>", "file://",
+// OriginMatchRequestHost or OriginMatchComparator), how many unsafe
+// requests it accepted. Every configured entry is listed, so those still at
+// zero after a representative period can be pruned, and a wildcard
+// accepting far more than expected stands out. It is empty when
+// EnforceOriginCheck is off; DebugHandler reports it as "originMatches".
+//
+// Returns:
+// - a snapshot of the counters keyed by entry.
+func (p *Protector) OriginMatches() map[string]int64 {
+	out := make(map[string]int64, len(p.originCounts))
+	for entry, n := range p.originCounts {
+		out[entry] = n.Load()
+	}
+	return out
+}
+
+// countOriginMatch records that entry accepted the validated request r and
+// passes it to OnOriginMatch.
+//
+// Params:
+// - r: the accepted unsafe request.
+// - entry: the matched allowlist entry ("" when the check is disabled).
+func (p *Protector) countOriginMatch(r *http.Request, entry string) {
+	if entry == "" {
+		return
+	}
+	if n, ok := p.originCounts[entry]; ok {
+		n.Add(1)
+	}
+	if p.cfg.OnOriginMatch != nil {
+		p.cfg.OnOriginMatch(r, entry)
+	}
+}
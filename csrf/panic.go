@@ -0,0 +1,85 @@
+package csrf
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// guard wraps h, the Protector's own handling of a request, so a panic
+// raised before the request is handed on (by the Protector itself or by a
+// user hook such as ErrorHandler, Exempt, SessionID or a store) is
+// recovered, logged and answered with a plain 500 instead of crashing the
+// connection or, worse, letting a half-checked request through. Panics of
+// next, and http.ErrAbortHandler, are re-raised untouched.
+//
+// Params:
+// - next: the wrapped handler, or nil when h never hands the request on.
+// - h: the handling; it must pass the request on through the handler it
+// receives, not next itself.
+//
+// Returns:
+// - the guarded handler.
+func (p *Protector) guard(next http.Handler, h func(w http.ResponseWriter, r *http.Request, next http.Handler)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handedOff := false
+		defer func() {
+			if handedOff {
+				return
+			}
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				p.recovered(w, r, v)
+			}
+		}()
+		var inner http.Handler
+		if next != nil {
+			inner = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handedOff = true
+				next.ServeHTTP(w, r)
+			})
+		}
+		h(w, r, inner)
+	})
+}
+
+// recovered answers a request whose CSRF handling panicked with v, after
+// recording ErrPanic as its failure and notifying OnReject and
+// OnRejectEvent. The response bypasses ErrorHandler, which may be what
+// panicked.
+//
+// Params:
+// - w: response writer.
+// - r: the request.
+// - v: the recovered value.
+func (p *Protector) recovered(w http.ResponseWriter, r *http.Request, v any) {
+	p.stats.panics.Add(1)
+	r = withFailure(r, ErrPanic)
+	p.cfg.Logger.Error("csrf: recovered panic, request rejected",
+		"panic", v, "method", r.Method, "path", r.URL.Path, "stack", string(debug.Stack()))
+	if p.cfg.OnReject != nil {
+		p.notifyPanic(r, "OnReject", func() { p.cfg.OnReject(r, ErrPanic) })
+	}
+	if p.cfg.OnRejectEvent != nil {
+		p.notifyPanic(r, "OnRejectEvent", func() { p.cfg.OnRejectEvent(p.NewRejectionEvent(r, ErrPanic)) })
+	}
+	http.Error(w, ErrPanic.Error(), http.StatusInternalServerError)
+}
+
+// notifyPanic runs an observer of a recovered panic, which may itself be
+// the hook that panicked; a second panic is logged and dropped.
+//
+// Params:
+// - r: the request.
+// - hook: name of the observer, for the log.
+// - call: invokes the observer.
+func (p *Protector) notifyPanic(r *http.Request, hook string, call func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			p.cfg.Logger.Error("csrf: "+hook+" panicked while reporting a panic",
+				"panic", v, "method", r.Method, "path", r.URL.Path)
+		}
+	}()
+	call()
+}
@@ -0,0 +1,97 @@
+package csrf
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A panicking hook rejects the request with a plain 500; panics of the
+// protected handler are not swallowed.
+func TestHookPanicFailsClosed(t *testing.T) {
+	var logs bytes.Buffer
+	reached := false
+	p := New(Config{
+		Exempt: func(r *http.Request) bool {
+			if r.URL.Path == "/buggy" {
+				panic("buggy exempt")
+			}
+			return false
+		},
+		ErrorHandler: func(http.ResponseWriter, *http.Request, int, error) { panic("buggy handler") },
+		Logger:       slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	h := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			panic("app")
+		}
+		reached = true
+	}))
+
+	send := func(path string) *httptest.ResponseRecorder {
+		req := TrackFailure(httptest.NewRequest(http.MethodPost, path, nil))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if err := FailureReasonFromContext(req.Context()); ReasonCode(err) != "panic" {
+			t.Fatalf("%s: failure %v", path, err)
+		}
+		return rec
+	}
+	// Exempt panics, then ErrorHandler panics on the missing token
+	for _, path := range []string{"/buggy", "/"} {
+		if rec := send(path); rec.Code != http.StatusInternalServerError || reached {
+			t.Fatalf("%s: status %d, reached %v", path, rec.Code, reached)
+		}
+	}
+	if p.stats.panics.Load() != 2 || !strings.Contains(logs.String(), "buggy exempt") {
+		t.Fatalf("panics %d, logs: %s", p.stats.panics.Load(), logs.String())
+	}
+
+	// safe requests reach the handler without touching the hooks
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !reached {
+		t.Fatal("GET not served")
+	}
+
+	defer func() {
+		if recover() != "app" {
+			t.Fatal("application panic swallowed")
+		}
+	}()
+	tok, _ := newToken(32)
+	req := httptest.NewRequest(http.MethodPut, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tok})
+	req.Header.Set("X-CSRF-Token", tok)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+// A recovered panic reaches OnReject and OnRejectEvent with ErrPanic
+// recorded as the request's failure, even without TrackFailure.
+func TestPanicReasonCode(t *testing.T) {
+	var reason, event string
+	p := New(Config{
+		Exempt: func(*http.Request) bool { panic("buggy exempt") },
+		OnReject: func(r *http.Request, err error) {
+			if !errors.Is(err, ErrPanic) {
+				t.Errorf("OnReject got %v", err)
+			}
+			reason = ReasonCode(FailureReasonFromContext(r.Context()))
+			panic("buggy observer")
+		},
+		OnRejectEvent: func(e RejectionEvent) { event = e.Reason },
+		Logger:        slog.New(slog.DiscardHandler),
+	})
+
+	rec := httptest.NewRecorder()
+	p.Protect(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d", rec.Code)
+	}
+	if reason != "panic" || event != "panic" {
+		t.Fatalf("reason %q, event %q", reason, event)
+	}
+}
@@ -0,0 +1,108 @@
+package csrf
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits applied by the exported parsers before any decoding work, so
+// pathological inputs (huge headers, megabyte cookies) are rejected cheaply.
+const (
+	// MaxTokenLength is the longest encoded token ParseToken and
+	// ParseSignedToken accept.
+	MaxTokenLength = 1024
+
+	// MaxOriginLength is the longest Origin or Referer value ParseOrigin
+	// accepts.
+	MaxOriginLength = 4096
+)
+
+var (
+	// ErrMalformedToken wraps ErrTokenMismatch, so existing errors.Is checks
+	// keep matching structurally invalid tokens.
+	ErrMalformedToken  = fmt.Errorf("%w (malformed)", ErrTokenMismatch)
+	errMalformedOrigin = errors.New("malformed origin")
+)
+
+// ParseToken decodes a plain (unsigned) token, as carried by the cookie,
+// header or form. Trailing "=" padding is tolerated. It is the decoder the
+// middleware uses and is safe to fuzz directly.
+//
+// Params:
+// - s: encoded token.
+// - n: expected decoded size (Config.TokenBytes).
+//
+// Returns:
+// - the n raw bytes, or an error if s is too long, not base64url or of the wrong size.
+func ParseToken(s string, n int) ([]byte, error) {
+	if n <= 0 || len(s) > MaxTokenLength {
+		return nil, ErrMalformedToken
+	}
+	b := make([]byte, n)
+	if !decodeToken(b, s) {
+		return nil, ErrMalformedToken
+	}
+	return b, nil
+}
+
+// ParseSignedToken splits a signed token "<random>.<region>.<signature>"
+// into its parts without verifying the signature.
+//
+// Params:
+// - s: encoded signed token.
+// - n: expected decoded size of the random part (Config.TokenBytes).
+//
+// Returns:
+// - the raw random bytes, the region, the signature bytes, or an error if s is malformed.
+func ParseSignedToken(s string, n int) (raw []byte, region string, sig []byte, err error) {
+	if len(s) > MaxTokenLength {
+		return nil, "", nil, ErrMalformedToken
+	}
+	body, enc, ok := cutLast(s, '.')
+	if !ok {
+		return nil, "", nil, ErrMalformedToken
+	}
+	rawEnc, region, ok := strings.Cut(body, ".")
+	if !ok || strings.Contains(region, ".") {
+		return nil, "", nil, ErrMalformedToken
+	}
+	if raw, err = ParseToken(rawEnc, n); err != nil {
+		return nil, "", nil, err
+	}
+	if sig, err = base64.RawURLEncoding.DecodeString(enc); err != nil || len(sig) == 0 {
+		return nil, "", nil, ErrMalformedToken
+	}
+	return raw, region, sig, nil
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s string, sep byte) (before, after string, found bool) {
+	if i := strings.LastIndexByte(s, sep); i >= 0 {
+		return s[:i], s[i+1:], true
+	}
+	return s, "", false
+}
+
+// ParseOrigin parses an Origin or Referer header value the way the origin
+// check does. Values that are too long, not valid UTF-8, "null", opaque
+// ("https:host") or without a host are rejected.
+//
+// Params:
+// - value: raw header value.
+//
+// Returns:
+// - the parsed URL (with a non-empty Host), or an error.
+func ParseOrigin(value string) (*url.URL, error) {
+	if value == "" || value == "null" || len(value) > MaxOriginLength || !utf8.ValidString(value) {
+		return nil, errMalformedOrigin
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Opaque != "" || u.Host == "" {
+		return nil, errMalformedOrigin
+	}
+	return u, nil
+}
@@ -0,0 +1,81 @@
+package csrf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseOrigin(t *testing.T) {
+	for _, v := range []string{
+		"", "null", "https:app.example.com", "/relative", "https://",
+		"https://app.example.com/\xff", "https://" + strings.Repeat("a", MaxOriginLength),
+		"https://app.example.com\x00",
+	} {
+		if _, err := ParseOrigin(v); err == nil {
+			t.Errorf("expected %q to be rejected", v)
+		}
+	}
+	u, err := ParseOrigin("https://user@app.example.com:8443/path?q=1")
+	if err != nil || u.Host != "app.example.com:8443" {
+		t.Fatalf("unexpected result %v, %v", u, err)
+	}
+}
+
+func TestParseSignedToken(t *testing.T) {
+	p := New(Config{TokenBytes: 16, SigningKey: bytes.Repeat([]byte{1}, 32), Region: "eu"})
+	tok, _ := p.newToken()
+	raw, region, sig, err := ParseSignedToken(tok, 16)
+	if err != nil || len(raw) != 16 || region != "eu" || len(sig) != 32 {
+		t.Fatalf("unexpected parse of %q: %d bytes, %q, %d-byte signature, %v", tok, len(raw), region, len(sig), err)
+	}
+	for _, s := range []string{"", tok[:10], strings.Replace(tok, ".eu.", ".e.u.", 1), tok + strings.Repeat("A", MaxTokenLength)} {
+		if _, _, _, err := ParseSignedToken(s, 16); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+// The parsers never panic and only accept what they can return consistently.
+func FuzzParseToken(f *testing.F) {
+	tok, _ := newToken(32)
+	for _, s := range []string{tok, tok + "=", "", "====", "\xff\xfe", strings.Repeat("A", 2048)} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		b, err := ParseToken(s, 32)
+		if err == nil && (len(b) != 32 || !validToken(s, 32)) {
+			t.Fatalf("inconsistent result for %q", s)
+		}
+	})
+}
+
+func FuzzParseSignedToken(f *testing.F) {
+	p := New(Config{TokenBytes: 16, SigningKey: bytes.Repeat([]byte{1}, 32), Region: "us"})
+	tok, _ := p.newToken()
+	for _, s := range []string{tok, "a.b.c", "...", "x..y", "\x00.\x00.\x00"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		raw, region, _, err := ParseSignedToken(s, 16)
+		if err == nil && (len(raw) != 16 || strings.Contains(region, ".")) {
+			t.Fatalf("inconsistent result for %q", s)
+		}
+		p.acceptToken(s)
+	})
+}
+
+func FuzzParseOrigin(f *testing.F) {
+	for _, s := range []string{"https://app.example.com", "null", "https:app", "http://[::1]:80", "https://a@b@c", "\t", "https://%zz"} {
+		f.Add(s)
+	}
+	p := New(Config{AllowedOrigins: []string{"app.example.com"}, AllowedOriginPatterns: []string{"pr-*.example.com"}})
+	f.Fuzz(func(t *testing.T, s string) {
+		u, err := ParseOrigin(s)
+		if err == nil && u.Host == "" {
+			t.Fatalf("accepted %q without a host", s)
+		}
+		p.originMatch(s, p.cfg.AllowedOrigins, "app.example.com")
+		_ = observedHost(s)
+	})
+}
@@ -0,0 +1,134 @@
+package csrf
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// originPattern is a compiled AllowedOriginPatterns entry. Each label of the
+// pattern is matched against the corresponding label of the host; "*" matches
+// any run of characters within a single label. A leading "**" label matches
+// one or more labels, i.e. subdomains at any depth.
+type originPattern struct {
+	raw    string
+	labels []string
+	deep   bool // leading "**"
+}
+
+// sharedHostingSuffixes are domains under which unrelated parties get
+// subdomains. A bare "*" label directly under them would accept anyone's
+// deployment, so patterns there must keep a literal part (e.g., "myapp-*").
+var sharedHostingSuffixes = []string{
+	"vercel.app", "netlify.app", "pages.dev", "workers.dev", "herokuapp.com",
+	"github.io", "gitlab.io", "ngrok.io", "ngrok-free.app", "onrender.com",
+	"fly.dev", "web.app", "firebaseapp.com", "azurewebsites.net", "amplifyapp.com",
+}
+
+// multiLabelSuffixes are common public suffixes with two labels; a pattern
+// must keep literal labels beyond them.
+var multiLabelSuffixes = []string{
+	"co.uk", "org.uk", "ac.uk", "com.au", "net.au", "com.br", "net.br",
+	"co.jp", "co.nz", "co.za", "com.mx", "com.ar", "co.in", "com.cn",
+}
+
+// compileOriginPattern validates and compiles a host pattern such as
+// "pr-*.preview.example.com", "myapp-*.vercel.app" or "**.example.com"
+// (every subdomain of example.com, at any depth, but not example.com
+// itself).
+//
+// Safeguards (the pattern is rejected when any applies):
+//   - the wildcard appears in the last two labels (e.g., "*.com", "example.*");
+//   - fewer than two literal labels follow the wildcard beyond a known
+//     multi-label public suffix (e.g., "*.co.uk");
+//   - a bare "*" or "**" label sits directly under a shared hosting domain
+//     (e.g., "*.vercel.app"), which would accept other tenants' deployments;
+//   - "**" is used other than as the whole first label.
+//
+// Params:
+// - raw: the pattern, case-insensitive, optionally with ":port".
+//
+// Returns:
+// - the compiled pattern, or an error explaining why it is too broad.
+func compileOriginPattern(raw string) (originPattern, error) {
+	pat := strings.ToLower(strings.TrimSpace(raw))
+	if pat == "" {
+		return originPattern{}, fmt.Errorf("csrf: empty origin pattern")
+	}
+	labels := strings.Split(pat, ".")
+	last := -1
+	for i, l := range labels {
+		if l == "" {
+			return originPattern{}, fmt.Errorf("csrf: origin pattern %q has an empty label", raw)
+		}
+		if strings.Contains(l, "**") && (l != "**" || i != 0) {
+			return originPattern{}, fmt.Errorf("csrf: origin pattern %q: \"**\" must be the whole first label", raw)
+		}
+		if strings.Contains(l, "*") {
+			last = i
+		}
+	}
+	if last >= 0 {
+		literal := labels[last+1:]
+		suffix := strings.Join(literal, ".")
+		if len(literal) < 2 {
+			return originPattern{}, fmt.Errorf("csrf: origin pattern %q is too broad: wildcard in the last two labels", raw)
+		}
+		for _, s := range multiLabelSuffixes {
+			if suffix == s {
+				return originPattern{}, fmt.Errorf("csrf: origin pattern %q is too broad: %q is a public suffix", raw, s)
+			}
+		}
+		if (labels[last] == "*" || labels[last] == "**") && last == len(labels)-3 {
+			for _, s := range sharedHostingSuffixes {
+				if suffix == s {
+					return originPattern{}, fmt.Errorf("csrf: origin pattern %q is too broad: %q hosts other tenants; use a prefix such as \"myapp-*.%s\"", raw, s, s)
+				}
+			}
+		}
+	}
+	return originPattern{raw: raw, labels: labels, deep: labels[0] == "**"}, nil
+}
+
+// match reports whether host (host[:port], case-insensitive) matches p.
+func (p originPattern) match(host string) bool {
+	labels := strings.Split(strings.ToLower(host), ".")
+	if p.deep {
+		// "**" takes the extra leading labels, at least one
+		extra := len(labels) - len(p.labels) + 1
+		if extra < 1 || slices.Contains(labels[:extra], "") {
+			return false
+		}
+		labels = append([]string{"**"}, labels[extra:]...)
+	}
+	if len(labels) != len(p.labels) {
+		return false
+	}
+	for i, l := range p.labels {
+		if !globLabel(l, labels[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// globLabel matches a single label against a pattern in which "*" matches
+// any (possibly empty) run of characters.
+func globLabel(pattern, s string) bool {
+	star := strings.IndexByte(pattern, '*')
+	if star < 0 {
+		return pattern == s
+	}
+	prefix := pattern[:star]
+	if !strings.HasPrefix(s, prefix) {
+		return false
+	}
+	rest := pattern[star+1:]
+	s = s[len(prefix):]
+	for i := 0; i <= len(s); i++ {
+		if globLabel(rest, s[i:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package csrf
+
+import "testing"
+
+// Patterns match within labels and overly broad ones are refused.
+func TestOriginPatterns(t *testing.T) {
+	valid := map[string]map[string]bool{
+		"pr-*.preview.example.com": {
+			"pr-42.preview.example.com":     true,
+			"PR-7.Preview.Example.com":      true,
+			"pr-1.x.preview.example.com":    false,
+			"preview.example.com":           false,
+			"pr-1.preview.example.com.evil": false,
+		},
+		"myapp-*.vercel.app": {
+			"myapp-git-main.vercel.app": true,
+			"evil.vercel.app":           false,
+		},
+		"*.tenants.example.com": {
+			"acme.tenants.example.com": true,
+		},
+		"**.example.com": {
+			"acme.example.com":         true,
+			"eu.acme.example.com":      true,
+			"example.com":              false,
+			"evilexample.com":          false,
+			".example.com":             false,
+			"acme.example.com.evil.io": false,
+		},
+	}
+	for raw, hosts := range valid {
+		pat, err := compileOriginPattern(raw)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", raw, err)
+		}
+		for host, want := range hosts {
+			if got := pat.match(host); got != want {
+				t.Errorf("%s vs %s: got %v want %v", raw, host, got, want)
+			}
+		}
+	}
+
+	for _, raw := range []string{"*", "*.com", "example.*", "*.co.uk", "*.vercel.app", "a..b.com", "", "**.com", "**.co.uk", "**.vercel.app", "a.**.example.com", "a**.example.com"} {
+		if _, err := compileOriginPattern(raw); err == nil {
+			t.Errorf("%q: expected pattern to be rejected", raw)
+		}
+	}
+}
+
+// New refuses broad patterns and the origin check accepts matching hosts.
+func TestAllowedOriginPatterns(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected New to panic on a broad pattern")
+		}
+	}()
+	if err := (Config{AllowedOriginPatterns: []string{"*.vercel.app"}}).Validate(); err == nil {
+		t.Fatalf("expected Validate error")
+	}
+
+	p := New(Config{AllowedOriginPatterns: []string{"pr-*.preview.example.com"}})
+	if p.matchOriginPattern("https://pr-9.preview.example.com") != "pr-*.preview.example.com" || p.matchOriginPattern("https://evil.com") != "" {
+		t.Fatalf("unexpected pattern matching")
+	}
+	New(Config{AllowedOriginPatterns: []string{"*.com"}})
+}
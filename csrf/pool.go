@@ -0,0 +1,73 @@
+package csrf
+
+import "sync/atomic"
+
+// tokenPool holds pre-generated tokens so bursts of first-visit traffic do
+// not serialize on crypto/rand. Each token is handed out at most once and
+// only ever lives in process memory. A nil *tokenPool is a valid, always-empty
+// pool.
+type tokenPool struct {
+	ch        chan string
+	n         int // TokenBytes of the pooled tokens
+	refilling atomic.Bool
+}
+
+// newTokenPool returns a pool of size tokens of n bytes and starts filling it
+// in the background, or nil when size is not positive.
+func newTokenPool(size, n int) *tokenPool {
+	if size <= 0 {
+		return nil
+	}
+	tp := &tokenPool{ch: make(chan string, size), n: n}
+	tp.refill()
+	return tp
+}
+
+// take returns a pooled token, if any, and triggers a background refill once
+// the pool drops below half its capacity.
+func (tp *tokenPool) take() (string, bool) {
+	if tp == nil {
+		return "", false
+	}
+	select {
+	case tok := <-tp.ch:
+		if len(tp.ch) < cap(tp.ch)/2 {
+			tp.refill()
+		}
+		return tok, true
+	default:
+		tp.refill()
+		return "", false
+	}
+}
+
+// refill starts a goroutine filling the pool, unless one is already running.
+// The goroutine exits once the pool is full or generation fails, so an idle
+// Protector holds no goroutine.
+func (tp *tokenPool) refill() {
+	if !tp.refilling.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer tp.refilling.Store(false)
+		for len(tp.ch) < cap(tp.ch) {
+			tok, err := newToken(tp.n)
+			if err != nil {
+				return
+			}
+			select {
+			case tp.ch <- tok:
+			default:
+				return
+			}
+		}
+	}()
+}
+
+// available returns the number of pooled tokens.
+func (tp *tokenPool) available() int {
+	if tp == nil {
+		return 0
+	}
+	return len(tp.ch)
+}
@@ -0,0 +1,34 @@
+package csrf
+
+import (
+	"testing"
+	"time"
+)
+
+// Pooled tokens are well-formed, never handed out twice and refilled.
+func TestTokenPool(t *testing.T) {
+	p := New(Config{TokenBytes: 16, TokenPoolSize: 8})
+	waitFull := func() {
+		deadline := time.Now().Add(time.Second)
+		for p.pool.available() < 8 {
+			if time.Now().After(deadline) {
+				t.Fatalf("pool not refilled: %d available", p.pool.available())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFull()
+
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		tok, err := p.newToken()
+		if err != nil || !validToken(tok, 16) || seen[tok] {
+			t.Fatalf("bad or reused token %q (err %v)", tok, err)
+		}
+		seen[tok] = true
+	}
+	if p.stats.poolHits.Load() == 0 {
+		t.Fatal("expected tokens to be served from the pool")
+	}
+	waitFull()
+}
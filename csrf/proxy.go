@@ -0,0 +1,85 @@
+package csrf
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ProxyDirector wraps an httputil.ReverseProxy Director for gateways running
+// Protect in front of upstreams that should not see the CSRF credentials:
+// after director (when not nil), it removes the token and issuance cookies
+// and the token header from the outbound request, so upstream logs and
+// handlers never hold a usable token. Validation results reach the upstream
+// through Config.ForwardAssertion, which ReverseProxy copies with the other
+// request headers.
+//
+//	proxy := httputil.NewSingleHostReverseProxy(target)
+//	proxy.Director = p.ProxyDirector(proxy.Director)
+//	proxy.ModifyResponse = p.ProxyModifyResponse(nil)
+//	http.ListenAndServe(":8080", p.Protect(proxy))
+//
+// With a Rewrite function, call p.StripCredentials(pr.Out) instead.
+//
+// Params:
+// - director: the Director to run first, or nil.
+//
+// Returns:
+// - the wrapped Director.
+func (p *Protector) ProxyDirector(director func(*http.Request)) func(*http.Request) {
+	return func(out *http.Request) {
+		if director != nil {
+			director(out)
+		}
+		p.StripCredentials(out)
+	}
+}
+
+// StripCredentials removes the CSRF token cookie, the issuance cookie and
+// the token header from out, leaving other cookies untouched.
+//
+// Params:
+// - out: upstream-bound request.
+func (p *Protector) StripCredentials(out *http.Request) {
+	out.Header.Del(p.cfg.HeaderName)
+	if out.Header.Get("Cookie") == "" {
+		return
+	}
+	var kept []string
+	for _, c := range out.Cookies() {
+		if p.isCSRFCookie(out, c.Name) {
+			continue
+		}
+		kept = append(kept, c.String())
+	}
+	if len(kept) == 0 {
+		out.Header.Del("Cookie")
+		return
+	}
+	out.Header.Set("Cookie", strings.Join(kept, "; "))
+}
+
+// ProxyModifyResponse wraps an httputil.ReverseProxy ModifyResponse so
+// upstream responses cannot set or clear the gateway's CSRF cookies: their
+// Set-Cookie headers for those names are dropped before modify (when not
+// nil) runs.
+//
+// Params:
+// - modify: the ModifyResponse to run afterwards, or nil.
+//
+// Returns:
+// - the wrapped ModifyResponse.
+func (p *Protector) ProxyModifyResponse(modify func(*http.Response) error) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		p.dropSetCookies(resp.Header, resp.Request)
+		if modify != nil {
+			return modify(resp)
+		}
+		return nil
+	}
+}
+
+// isCSRFCookie reports whether name is the token or issuance cookie for r.
+func (p *Protector) isCSRFCookie(r *http.Request, name string) bool {
+	base := p.cookieName(r)
+	return name == base || name == base+issuedAtSuffix || name == base+deviceSuffix
+}
@@ -0,0 +1,50 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+)
+
+// The gateway strips its credentials on the way up and the upstream's
+// attempts to set its cookie on the way down.
+func TestProxyDirector(t *testing.T) {
+	var seen http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+		http.SetCookie(w, &http.Cookie{Name: "csrf_token", Value: "from-upstream"})
+		http.SetCookie(w, &http.Cookie{Name: "app", Value: "1"})
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+
+	p := New(Config{TokenBytes: 16, ForwardAssertion: "X-CSRF-Assertion"})
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Director = p.ProxyDirector(proxy.Director)
+	proxy.ModifyResponse = p.ProxyModifyResponse(nil)
+	gateway := p.Protect(proxy)
+
+	tok, _ := newToken(16)
+	req := httptest.NewRequest(http.MethodPost, "/save", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tok})
+	req.Header.Set("X-CSRF-Token", tok)
+	rec := httptest.NewRecorder()
+	gateway.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d", rec.Code)
+	}
+	if got := seen.Get("Cookie"); got != "session=abc" {
+		t.Errorf("upstream saw cookies %q", got)
+	}
+	if seen.Get("X-CSRF-Token") != "" || seen.Get("X-CSRF-Assertion") != AssertionValidated {
+		t.Errorf("upstream headers: %v", seen)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "app" {
+		t.Errorf("client got cookies %v", cookies)
+	}
+}
@@ -0,0 +1,60 @@
+package csrf
+
+import (
+	"net/http"
+	"strings"
+)
+
+// pushToken advertises the token endpoint (PushTokenPath) alongside an HTML
+// page, so the SPA it loads finds the token ready instead of fetching it
+// after startup: a Link preload header is added, and on HTTP/2 connections
+// whose server supports it the endpoint response is pushed. The pushed
+// request carries the request's cookies updated with those set on w, so the
+// endpoint answers with the token of this response.
+//
+// Params:
+// - w: response writer of the page.
+// - r: safe page request.
+func (p *Protector) pushToken(w http.ResponseWriter, r *http.Request) {
+	path := p.cfg.PushTokenPath
+	if path == "" || r.Method != http.MethodGet || r.URL.Path == path || !isNavigation(r) {
+		return
+	}
+	w.Header().Add("Link", "<"+path+">; rel=preload; as=fetch; crossorigin=use-credentials")
+
+	pusher, ok := w.(http.Pusher)
+	if !ok {
+		return
+	}
+	jar := map[string]string{}
+	var names []string
+	set := func(c *http.Cookie) {
+		if _, seen := jar[c.Name]; !seen {
+			names = append(names, c.Name)
+		}
+		jar[c.Name] = c.Value
+	}
+	for _, c := range r.Cookies() {
+		set(c)
+	}
+	for _, c := range (&http.Response{Header: w.Header()}).Cookies() {
+		if c.MaxAge < 0 {
+			delete(jar, c.Name)
+			continue
+		}
+		set(c)
+	}
+	pairs := make([]string, 0, len(names))
+	for _, n := range names {
+		if v, ok := jar[n]; ok {
+			pairs = append(pairs, n+"="+v)
+		}
+	}
+	h := http.Header{"Accept": {"text/plain"}}
+	if len(pairs) > 0 {
+		h.Set("Cookie", strings.Join(pairs, "; "))
+	}
+	if err := pusher.Push(path, &http.PushOptions{Header: h}); err == nil {
+		p.stats.tokenPushed.Add(1)
+	}
+}
@@ -0,0 +1,73 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pushRecorder is a ResponseRecorder supporting server push.
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	target string
+	opts   *http.PushOptions
+}
+
+func (pr *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	pr.target, pr.opts = target, opts
+	return nil
+}
+
+func TestPushTokenWithPage(t *testing.T) {
+	cfg := SameOriginSPA("/csrf-token")
+	cfg.CookieSecure = false
+	p := New(cfg)
+	mux := http.NewServeMux()
+	mux.Handle("/csrf-token", p.TokenHandler())
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html>")) })
+	h := p.Protect(mux)
+
+	page := httptest.NewRequest(http.MethodGet, "/", nil)
+	page.Header.Set("Sec-Fetch-Mode", "navigate")
+	page.Header.Set("Sec-Fetch-Dest", "document")
+	page.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+	rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(rec, page)
+
+	c := getCookieByName(rec.Result(), "csrf_token")
+	if c == nil {
+		t.Fatal("page did not set the cookie")
+	}
+	if link := rec.Header().Get("Link"); !strings.Contains(link, "</csrf-token>; rel=preload") {
+		t.Fatalf("Link = %q", link)
+	}
+	if rec.target != "/csrf-token" {
+		t.Fatalf("pushed %q, want /csrf-token", rec.target)
+	}
+	cookie := rec.opts.Header.Get("Cookie")
+	if !strings.Contains(cookie, "session=s1") || !strings.Contains(cookie, "csrf_token="+c.Value) {
+		t.Fatalf("pushed Cookie = %q", cookie)
+	}
+
+	// the pushed request gets the token of the page response
+	push := httptest.NewRequest(http.MethodGet, rec.target, nil)
+	push.Header = rec.opts.Header.Clone()
+	tokRec := httptest.NewRecorder()
+	h.ServeHTTP(tokRec, push)
+	if got := strings.TrimSpace(tokRec.Body.String()); got != c.Value {
+		t.Fatalf("pushed token = %q, want %q", got, c.Value)
+	}
+	if n := p.stats.tokenPushed.Load(); n != 1 {
+		t.Fatalf("tokenPushed = %d", n)
+	}
+
+	// API calls are not navigations
+	api := httptest.NewRequest(http.MethodGet, "/items", nil)
+	api.Header.Set("Sec-Fetch-Mode", "cors")
+	rec = &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(rec, api)
+	if rec.target != "" || rec.Header().Get("Link") != "" {
+		t.Fatalf("pushed for a fetch: %q", rec.target)
+	}
+}
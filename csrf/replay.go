@@ -0,0 +1,311 @@
+package csrf
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redacted replaces secret values in captured requests.
+const redacted = "REDACTED"
+
+// replayHeaders are the request headers whose values are captured, besides
+// the CSRF token header and Sec-Fetch-*; every other value is redacted.
+var replayHeaders = []string{"Origin", "Referer", "Content-Type", "User-Agent"}
+
+// capturedRequest is a sanitized copy of a rejected request.
+type capturedRequest struct {
+	time    time.Time
+	reason  string
+	method  string
+	url     string
+	headers [][2]string // name, value; sorted by name
+	cookies [][2]string
+	form    [][2]string // parsed form body, if any
+	ctype   string
+}
+
+// replayLog keeps the most recent captured requests in a ring.
+type replayLog struct {
+	mu      sync.Mutex
+	entries []capturedRequest
+	next    int
+	full    bool
+}
+
+// newReplayLog returns a log of n entries, or nil when n is not positive.
+func newReplayLog(n int) *replayLog {
+	if n <= 0 {
+		return nil
+	}
+	return &replayLog{entries: make([]capturedRequest, n)}
+}
+
+// add stores c, evicting the oldest entry when the log is full.
+func (l *replayLog) add(c capturedRequest) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[l.next] = c
+	l.next = (l.next + 1) % len(l.entries)
+	l.full = l.full || l.next == 0
+}
+
+// list returns the entries, oldest first.
+func (l *replayLog) list() []capturedRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return slices.Clone(l.entries[:l.next])
+	}
+	return append(slices.Clone(l.entries[l.next:]), l.entries[:l.next]...)
+}
+
+// captureRejection records a sanitized copy of the rejected request r when
+// ReplayCapture is set. Values are redacted unless allowlisted: the CSRF
+// cookies, the token header, query parameters and form fields (under the
+// names of the matching rule, plus FormNonceField), and the Origin,
+// Referer (without its query), Sec-Fetch-*, Content-Type and User-Agent
+// headers. Names are kept, so the shape of the request stays visible. Every
+// well-formed token is replaced by a placeholder ("<csrf-token-1>", ...)
+// numbered per request, so equal values stay equal and a mismatch stays
+// visible without exposing tokens. Bodies are only included when already
+// parsed as a form.
+//
+// Params:
+// - r: the rejected request.
+// - err: the rejection reason.
+func (p *Protector) captureRejection(r *http.Request, err error) {
+	if p.replay == nil {
+		return
+	}
+	tokens := map[string]string{}
+	sanitize := func(v string) string {
+		if v == "" || !p.wellFormed(v) {
+			return v
+		}
+		ph, ok := tokens[v]
+		if !ok {
+			ph = "<csrf-token-" + strconv.Itoa(len(tokens)+1) + ">"
+			tokens[v] = ph
+		}
+		return ph
+	}
+
+	header, fields := p.tokenNames(p.ruleFor(r))
+	tokenField := func(name string) bool {
+		return slices.Contains(fields, name) || name != "" && name == p.cfg.FormNonceField
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: p.requestHost(r), Path: r.URL.Path}
+	if q := r.URL.Query(); len(q) > 0 {
+		for k, vs := range q {
+			for i := range vs {
+				if tokenField(k) {
+					vs[i] = sanitize(vs[i])
+				} else {
+					vs[i] = redacted
+				}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	c := capturedRequest{
+		time:   time.Now(),
+		reason: reasonCode(err),
+		method: r.Method,
+		url:    u.String(),
+		ctype:  r.Header.Get("Content-Type"),
+	}
+
+	for name, vs := range r.Header {
+		if name == "Cookie" || name == "Content-Length" {
+			continue
+		}
+		for _, v := range vs {
+			switch {
+			case name == "Referer":
+				if ref, err := url.Parse(v); err == nil && ref.RawQuery != "" {
+					ref.RawQuery, ref.Fragment = redacted, ""
+					v = ref.String()
+				}
+			case strings.EqualFold(name, header), strings.HasPrefix(name, "Sec-Fetch-"), slices.Contains(replayHeaders, name):
+			default:
+				v = redacted
+			}
+			c.headers = append(c.headers, [2]string{name, sanitize(v)})
+		}
+	}
+	slices.SortStableFunc(c.headers, func(a, b [2]string) int { return strings.Compare(a[0], b[0]) })
+
+	for _, ck := range r.Cookies() {
+		v := redacted
+		if p.isCSRFCookie(r, ck.Name) && ck.Name != p.cookieName(r)+deviceSuffix {
+			v = sanitize(ck.Value)
+		}
+		c.cookies = append(c.cookies, [2]string{ck.Name, v})
+	}
+
+	if r.PostForm != nil {
+		keys := make([]string, 0, len(r.PostForm))
+		for k := range r.PostForm {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			for _, v := range r.PostForm[k] {
+				if tokenField(k) {
+					v = sanitize(v)
+				} else {
+					v = redacted
+				}
+				c.form = append(c.form, [2]string{k, v})
+			}
+		}
+	}
+	p.replay.add(c)
+}
+
+// ReplayHandler returns a handler exporting the requests captured with
+// ReplayCapture, oldest first, so developers can reproduce a failing client
+// request against a dev server. The format is HAR 1.2 by default and a
+// shell script of curl commands with ?format=curl. Token placeholders
+// ("<csrf-token-1>") must be replaced by tokens issued by the target.
+//
+// Like DebugHandler, it is intended for internal networks only.
+//
+// Returns:
+// - http.Handler writing the export (empty when capture is disabled).
+func (p *Protector) ReplayHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		var entries []capturedRequest
+		if p.replay != nil {
+			entries = p.replay.list()
+		}
+		if r.URL.Query().Get("format") == "curl" {
+			w.Header().Set("Content-Type", "text/x-shellscript; charset=utf-8")
+			for _, c := range entries {
+				w.Write([]byte(c.curl()))
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(harLog(entries))
+	})
+}
+
+// curl renders c as a commented curl command line.
+func (c capturedRequest) curl() string {
+	var b strings.Builder
+	b.WriteString("# " + c.time.UTC().Format(time.RFC3339) + " rejected: " + c.reason + "\n")
+	b.WriteString("curl -X " + shellQuote(c.method) + " " + shellQuote(c.url))
+	for _, h := range c.headers {
+		b.WriteString(" \\\n  -H " + shellQuote(h[0]+": "+h[1]))
+	}
+	if len(c.cookies) > 0 {
+		pairs := make([]string, len(c.cookies))
+		for i, ck := range c.cookies {
+			pairs[i] = ck[0] + "=" + ck[1]
+		}
+		b.WriteString(" \\\n  -b " + shellQuote(strings.Join(pairs, "; ")))
+	}
+	if len(c.form) > 0 {
+		b.WriteString(" \\\n  --data-raw " + shellQuote(c.formBody()))
+	}
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+// formBody encodes the captured form, fields sorted by name.
+func (c capturedRequest) formBody() string {
+	parts := make([]string, len(c.form))
+	for i, f := range c.form {
+		parts[i] = url.QueryEscape(f[0]) + "=" + url.QueryEscape(f[1])
+	}
+	return strings.Join(parts, "&")
+}
+
+// shellQuote single-quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// harNameValue is a HAR name/value pair.
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harLog builds a HAR 1.2 document from entries. Responses are not
+// captured; each entry records the rejection reason in its comment.
+func harLog(entries []capturedRequest) map[string]any {
+	pairs := func(kv [][2]string) []harNameValue {
+		out := make([]harNameValue, len(kv))
+		for i, p := range kv {
+			out[i] = harNameValue{Name: p[0], Value: p[1]}
+		}
+		return out
+	}
+	list := make([]map[string]any, len(entries))
+	for i, c := range entries {
+		query := []harNameValue{}
+		if u, err := url.Parse(c.url); err == nil {
+			q := u.Query()
+			keys := make([]string, 0, len(q))
+			for k := range q {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				for _, v := range q[k] {
+					query = append(query, harNameValue{Name: k, Value: v})
+				}
+			}
+		}
+		req := map[string]any{
+			"method":      c.method,
+			"url":         c.url,
+			"httpVersion": "HTTP/1.1",
+			"headers":     pairs(c.headers),
+			"cookies":     pairs(c.cookies),
+			"queryString": query,
+			"headersSize": -1,
+			"bodySize":    -1,
+		}
+		if len(c.form) > 0 {
+			req["postData"] = map[string]any{
+				"mimeType": c.ctype,
+				"params":   pairs(c.form),
+				"text":     c.formBody(),
+			}
+		}
+		list[i] = map[string]any{
+			"startedDateTime": c.time.UTC().Format(time.RFC3339Nano),
+			"time":            0,
+			"request":         req,
+			"response": map[string]any{
+				"status": 0, "statusText": "", "httpVersion": "HTTP/1.1",
+				"headers": []harNameValue{}, "cookies": []harNameValue{},
+				"content":     map[string]any{"size": 0, "mimeType": ""},
+				"redirectURL": "", "headersSize": -1, "bodySize": -1,
+			},
+			"cache":   map[string]any{},
+			"timings": map[string]any{"send": 0, "wait": 0, "receive": 0},
+			"comment": "rejected: " + c.reason,
+		}
+	}
+	return map[string]any{"log": map[string]any{
+		"version": "1.2",
+		"creator": map[string]any{"name": eventProduct, "version": eventVersion},
+		"entries": list,
+	}}
+}
@@ -0,0 +1,98 @@
+package csrf
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Rejected requests are exported with secrets redacted and tokens replaced
+// by placeholders that keep equal values equal.
+func TestReplayHandler(t *testing.T) {
+	p := New(Config{TokenBytes: 16, ReplayCapture: 2})
+	app := p.Protect(appHandler(p))
+	cookieTok, _ := newToken(16)
+	formTok, _ := newToken(16)
+
+	send := func() {
+		form := url.Values{"csrf_token": {formTok}, "amount": {"100"}}
+		req := httptest.NewRequest(http.MethodPost, "/submit?csrf_token="+cookieTok, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer secret")
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: cookieTok})
+		req.AddCookie(&http.Cookie{Name: "session", Value: "secret"})
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected rejection, got %d", rec.Code)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		send()
+	}
+
+	rec := httptest.NewRecorder()
+	p.ReplayHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/replay?format=curl", nil))
+	out := rec.Body.String()
+	for _, leak := range []string{cookieTok, formTok, "secret"} {
+		if strings.Contains(out, leak) {
+			t.Fatalf("export leaks %q:\n%s", leak, out)
+		}
+	}
+	for _, want := range []string{"rejected: bad_token", "csrf_token=<csrf-token-1>", "csrf_token=%3Ccsrf-token-2%3E", "amount=REDACTED", "Authorization: REDACTED"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("export lacks %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "curl -X"); n != 2 {
+		t.Fatalf("expected 2 captured requests, got %d", n)
+	}
+
+	rec = httptest.NewRecorder()
+	p.ReplayHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/replay", nil))
+	var har struct {
+		Log struct {
+			Version string
+			Entries []struct {
+				Request struct{ Method, URL string }
+				Comment string
+			}
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &har); err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 || har.Log.Entries[0].Request.Method != http.MethodPost {
+		t.Fatalf("unexpected HAR %+v", har)
+	}
+}
+
+// Query and header values are redacted unless allowlisted.
+func TestReplayRedactsByDefault(t *testing.T) {
+	p := New(Config{TokenBytes: 16, ReplayCapture: 1})
+	req := httptest.NewRequest(http.MethodPost, "/submit?access_token=qsecret1&api_key=qsecret2&page=2", nil)
+	req.Header.Set("X-Auth-Token", "hsecret1")
+	req.Header.Set("X-Amz-Security-Token", "hsecret2")
+	req.Header.Set("Cookie2", "hsecret3")
+	req.Header.Set("Referer", "https://app.example.com/form?reset=rsecret")
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	p.Protect(appHandler(p)).ServeHTTP(httptest.NewRecorder(), req)
+
+	rec := httptest.NewRecorder()
+	p.ReplayHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/replay?format=curl", nil))
+	out := rec.Body.String()
+	for _, leak := range []string{"qsecret", "hsecret", "rsecret", "page=2"} {
+		if strings.Contains(out, leak) {
+			t.Fatalf("export leaks %q:\n%s", leak, out)
+		}
+	}
+	for _, want := range []string{"access_token=REDACTED", "X-Auth-Token: REDACTED", "Origin: https://app.example.com", "Referer: https://app.example.com/form?REDACTED", "Sec-Fetch-Site: cross-site"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("export lacks %q:\n%s", want, out)
+		}
+	}
+}
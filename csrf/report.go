@@ -0,0 +1,127 @@
+package csrf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxReportBytes bounds the body accepted by ReportHandler.
+const maxReportBytes = 4 << 10
+
+// maxReportKind bounds the length of ClientReport.Kind.
+const maxReportKind = 32
+
+// ClientReport is the JSON body a frontend posts to ReportHandler when it
+// detects a broken token state, e.g. {"kind": "missing_token", "page":
+// "https://app.example.com/checkout", "message": "no csrf_token cookie"}.
+type ClientReport struct {
+	// Kind is a short code for the problem (e.g., "missing_token",
+	// "bad_token"); anything but lowercase letters, digits and "_" (up to
+	// 32 characters) is reported as "other".
+	Kind string `json:"kind"`
+	// Page is the URL of the page that detected it; only its path is kept.
+	Page string `json:"page,omitempty"`
+	// Message is free-form detail, truncated to 256 bytes.
+	Message string `json:"message,omitempty"`
+}
+
+// ClientReportError is the error passed to OnReject for a ClientReport, so
+// hooks can tell client-side reports apart from server-side rejections. Use
+// errors.As to access it.
+type ClientReportError struct {
+	Report ClientReport
+}
+
+// Error describes the report, e.g.:
+// client report: missing_token: no csrf_token cookie.
+func (e *ClientReportError) Error() string {
+	if e.Report.Message == "" {
+		return "client report: " + e.Report.Kind
+	}
+	return fmt.Sprintf("client report: %s: %s", e.Report.Kind, e.Report.Message)
+}
+
+// ReportHandler returns a handler, in the spirit of CSP reporting, that
+// accepts ClientReports POSTed by the frontend (up to 4 KiB of JSON) and
+// feeds them to OnReject (as a *ClientReportError) and OnRejectEvent (with
+// Source "client" and the report kind as Reason), and to the
+// "clientReports" counter of DebugHandler. Reports do not count as
+// rejections and never touch FailureLimiter.
+//
+// Reports come from clients that may lack a valid token, so mount the
+// handler outside Protect (or exempt it). Anyone can post reports: treat
+// them as diagnostics, not as evidence.
+//
+// Returns:
+// - http.Handler answering 204 on success, 400 for malformed reports, 405
+// for methods other than POST and 415 for non-JSON bodies.
+func (p *Protector) ReportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); !strings.Contains(ct, "json") {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var rep ClientReport
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReportBytes)).Decode(&rep); err != nil {
+			http.Error(w, "malformed report", http.StatusBadRequest)
+			return
+		}
+		rep = rep.normalize()
+		p.stats.clientReports.Add(1)
+
+		err := &ClientReportError{Report: rep}
+		if p.cfg.OnReject != nil {
+			p.cfg.OnReject(r, err)
+		}
+		if p.cfg.OnRejectEvent != nil {
+			e := p.NewRejectionEvent(r, err)
+			e.Reason = rep.Kind
+			e.Source = "client"
+			if rep.Page != "" {
+				e.Path = rep.Page
+			}
+			p.cfg.OnRejectEvent(e.Redact(p.cfg.RedactEventFields...))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// normalize bounds the report fields: Kind to a short code, Page to the
+// path of the URL and Message to maxObservedLength bytes.
+func (rep ClientReport) normalize() ClientReport {
+	if !validReportKind(rep.Kind) {
+		rep.Kind = "other"
+	}
+	if u, err := url.Parse(rep.Page); err == nil {
+		rep.Page = u.Path
+	} else {
+		rep.Page = ""
+	}
+	if len(rep.Message) > maxObservedLength {
+		rep.Message = strings.ToValidUTF8(rep.Message[:maxObservedLength], "") + "..."
+	}
+	return rep
+}
+
+// validReportKind reports whether kind is a non-empty code of lowercase
+// letters, digits and "_", at most maxReportKind long.
+func validReportKind(kind string) bool {
+	if kind == "" || len(kind) > maxReportKind {
+		return false
+	}
+	for i := 0; i < len(kind); i++ {
+		c := kind[i]
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
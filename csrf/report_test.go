@@ -0,0 +1,60 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReportHandler(t *testing.T) {
+	var got []RejectionEvent
+	var hookErr error
+	p := New(Config{
+		OnReject:      func(_ *http.Request, err error) { hookErr = err },
+		OnRejectEvent: func(e RejectionEvent) { got = append(got, e) },
+	})
+	h := p.ReportHandler()
+
+	body := `{"kind":"missing_token","page":"https://app.example.com/checkout?card=4111","message":"no cookie"}`
+	req := httptest.NewRequest(http.MethodPost, "/csrf-report", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("got %d", rec.Code)
+	}
+	if len(got) != 1 || got[0].Reason != "missing_token" || got[0].Source != "client" || got[0].Path != "/checkout" {
+		t.Fatalf("unexpected events %+v", got)
+	}
+	var re *ClientReportError
+	if !errors.As(hookErr, &re) || re.Report.Message != "no cookie" {
+		t.Fatalf("unexpected OnReject error %v", hookErr)
+	}
+	if n := p.stats.clientReports.Load(); n != 1 || p.stats.rejected.Load() != 0 {
+		t.Fatalf("counters: reports=%d rejected=%d", n, p.stats.rejected.Load())
+	}
+
+	for _, tc := range []struct {
+		method, ctype, body string
+		want                int
+	}{
+		{http.MethodGet, "", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "text/plain", body, http.StatusUnsupportedMediaType},
+		{http.MethodPost, "application/json", "{", http.StatusBadRequest},
+		{http.MethodPost, "application/json", `{"kind":"` + strings.Repeat("x", maxReportBytes) + `"}`, http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(tc.method, "/csrf-report", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", tc.ctype)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s %s %.20q: got %d, want %d", tc.method, tc.ctype, tc.body, rec.Code, tc.want)
+		}
+	}
+
+	if k := (ClientReport{Kind: "<script>"}).normalize().Kind; k != "other" {
+		t.Fatalf("unsafe kind kept: %q", k)
+	}
+}
@@ -0,0 +1,94 @@
+package csrf
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// RotateToken mints a new token and sets it as the CSRF cookie on the
+// response, replacing any CSRF cookie already added during this request.
+// Call it after privilege changes (login, logout) so a token observed before
+// the change cannot be reused. The request context still holds the previous
+// token.
+//
+// Params:
+// - w: response writer to set the new cookie on (before the body is written).
+// - r: current request.
+//
+// Returns:
+// - the new token, or an error if token generation fails.
+func (p *Protector) RotateToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	tok, err := p.newToken()
+	if err != nil {
+		return "", err
+	}
+	p.dropResponseCookie(w, r)
+	if err := p.issueToken(w, r, tok); err != nil {
+		return "", err
+	}
+	p.stats.issued.Add(1)
+	p.window.add(windowIssued)
+	p.countRoute(r, routeIssued)
+	return tok, nil
+}
+
+// RotateHandler returns a POST-only handler that rotates the token and
+// responds with {"token": "<new token>"}. It is wrapped with Protect, so the
+// caller must present the current token like any other unsafe request.
+// SPAs can call it after login/logout instead of a custom handler.
+//
+// Returns:
+// - http.Handler responding with application/json.
+func (p *Protector) RotateHandler() http.Handler {
+	return p.Protect(p.rotateHandler())
+}
+
+// rotateHandler is RotateHandler without the Protect wrapper, for use inside
+// an already protected subtree (see Mount).
+func (p *Protector) rotateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		tok, err := p.RotateToken(w, r)
+		if err != nil {
+			p.fail(w, r, http.StatusInternalServerError, ErrRotateFailed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]string{"token": tok})
+	})
+}
+
+// dropResponseCookie removes CSRF Set-Cookie headers (token and issuance
+// timestamp) already added to the response, leaving other cookies untouched.
+//
+// Params:
+// - w: response writer whose pending headers are edited.
+// - r: current request (for CookieNameFunc).
+func (p *Protector) dropResponseCookie(w http.ResponseWriter, r *http.Request) {
+	p.dropSetCookies(w.Header(), r)
+}
+
+// dropSetCookies removes the CSRF Set-Cookie lines for r from h.
+func (p *Protector) dropSetCookies(h http.Header, r *http.Request) {
+	lines := h.Values("Set-Cookie")
+	if len(lines) == 0 {
+		return
+	}
+	kept := lines[:0:0]
+	for _, line := range lines {
+		if c, err := http.ParseSetCookie(line); err == nil && p.isCSRFCookie(r, c.Name) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if len(kept) == 0 {
+		h.Del("Set-Cookie")
+		return
+	}
+	h["Set-Cookie"] = kept
+}
@@ -0,0 +1,62 @@
+package csrf
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// RotateHandler is POST-only, requires the current token and returns a new one.
+func TestRotateHandler(t *testing.T) {
+	p := New(Config{CookieName: "csrf_token_test", TokenBytes: 16})
+	h := p.RotateHandler()
+	token, _ := newToken(16)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rotate", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for GET, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/rotate", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token_test", Value: token})
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without token, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/rotate", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token_test", Value: token})
+	req.Header.Set("X-CSRF-Token", token)
+	h.ServeHTTP(rec, req)
+	res := rec.Result()
+	var body struct{ Token string }
+	json.NewDecoder(res.Body).Decode(&body)
+	cookies := res.Cookies()
+	if res.StatusCode != http.StatusOK || len(cookies) != 1 {
+		t.Fatalf("expected 200 with one cookie, got %d with %d cookies", res.StatusCode, len(cookies))
+	}
+	if body.Token == "" || body.Token == token || cookies[0].Value != body.Token {
+		t.Fatalf("expected new token in body and cookie, got body=%q cookie=%q", body.Token, cookies[0].Value)
+	}
+}
+
+// RotateToken replaces a CSRF cookie issued earlier in the same response.
+func TestRotateTokenReplacesIssuedCookie(t *testing.T) {
+	p := New(Config{TokenBytes: 16})
+	rec := httptest.NewRecorder()
+	http.SetCookie(rec, &http.Cookie{Name: "session", Value: "s"})
+	p.setCookie(rec, httptest.NewRequest(http.MethodGet, "/", nil), "old")
+
+	tok, err := p.RotateToken(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 2 || cookies[0].Name != "session" || cookies[1].Value != tok {
+		t.Fatalf("unexpected cookies: %v", cookies)
+	}
+}
@@ -0,0 +1,142 @@
+package csrf
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// maxRouteLabels caps the distinct RoutePattern labels tracked; later ones
+// are counted under RouteOther so a misbehaving extractor cannot grow the
+// table without bound.
+const maxRouteLabels = 256
+
+const (
+	// RouteUnmatched labels requests for which RoutePattern returned "".
+	RouteUnmatched = "unmatched"
+	// RouteOther labels requests past the label cap.
+	RouteOther = "other"
+)
+
+const routeKey ctxKey = "csrf_route_ctx"
+
+// RouteCount holds the per-route counters reported by RouteStats.
+type RouteCount struct {
+	Issued    int64 `json:"issued"`
+	Validated int64 `json:"validated"`
+	Rejected  int64 `json:"rejected"`
+}
+
+// routeEvent indexes the counters of a route.
+type routeEvent int
+
+const (
+	routeIssued routeEvent = iota
+	routeValidated
+	routeRejected
+)
+
+// routeCounters holds the counters of one route label.
+type routeCounters [3]atomic.Int64
+
+// routeTable maps route labels to their counters.
+type routeTable struct {
+	mu     sync.Mutex
+	routes map[string]*routeCounters
+}
+
+// counters returns the counters for label, creating them if needed.
+func (t *routeTable) counters(label string) *routeCounters {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.routes == nil {
+		t.routes = make(map[string]*routeCounters)
+	}
+	c, ok := t.routes[label]
+	if !ok {
+		if len(t.routes) >= maxRouteLabels {
+			label = RouteOther
+			if c, ok = t.routes[label]; ok {
+				return c
+			}
+		}
+		c = new(routeCounters)
+		t.routes[label] = c
+	}
+	return c
+}
+
+// countRoute records ev for the route template of r when RoutePattern is
+// set.
+//
+// Params:
+// - r: current request.
+// - ev: event to count.
+func (p *Protector) countRoute(r *http.Request, ev routeEvent) {
+	if p.cfg.RoutePattern == nil {
+		return
+	}
+	label := p.cfg.RoutePattern(r)
+	if label == "" {
+		label = RouteUnmatched
+	}
+	p.routes.counters(label)[ev].Add(1)
+}
+
+// RouteStats returns the issuance and validation counters per route
+// template, as labeled by Config.RoutePattern. It is empty when
+// RoutePattern is not set.
+//
+// Returns:
+// - counters keyed by route template.
+func (p *Protector) RouteStats() map[string]RouteCount {
+	p.routes.mu.Lock()
+	defer p.routes.mu.Unlock()
+	out := make(map[string]RouteCount, len(p.routes.routes))
+	for label, c := range p.routes.routes {
+		out[label] = RouteCount{
+			Issued:    c[routeIssued].Load(),
+			Validated: c[routeValidated].Load(),
+			Rejected:  c[routeRejected].Load(),
+		}
+	}
+	return out
+}
+
+// MuxPattern returns a Config.RoutePattern extractor for a Go 1.22+
+// ServeMux: it labels requests with the pattern mux would dispatch them to
+// (e.g., "POST /items/{id}"), which is known before the handler runs.
+//
+// Params:
+// - mux: the ServeMux wrapped by Protect.
+//
+// Returns:
+// - the extractor.
+func MuxPattern(mux *http.ServeMux) func(*http.Request) string {
+	return func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+}
+
+// WithRoutePattern returns r carrying pattern for ContextRoutePattern, for
+// routers that resolve the route before middleware runs (e.g., gin's
+// c.FullPath()).
+//
+// Params:
+// - r: current request.
+// - pattern: the matched route template.
+//
+// Returns:
+// - a shallow copy of r with the pattern in its context.
+func WithRoutePattern(r *http.Request, pattern string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeKey, pattern))
+}
+
+// ContextRoutePattern is a Config.RoutePattern extractor returning the
+// template stored by WithRoutePattern.
+func ContextRoutePattern(r *http.Request) string {
+	pattern, _ := r.Context().Value(routeKey).(string)
+	return pattern
+}
@@ -0,0 +1,61 @@
+package csrf
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Counters are labeled by the mux pattern, not the raw path.
+func TestRouteStats(t *testing.T) {
+	mux := http.NewServeMux()
+	ok := func(http.ResponseWriter, *http.Request) {}
+	mux.HandleFunc("GET /items/{id}", ok)
+	mux.HandleFunc("POST /items/{id}", ok)
+	p := New(Config{TokenBytes: 16, RoutePattern: MuxPattern(mux)})
+	app := p.Protect(mux)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	token := rec.Result().Cookies()[0].Value
+	for i := range 3 {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/items/%d", i), nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+		if i > 0 {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		app.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/nowhere", nil))
+
+	got := p.RouteStats()
+	if c := got["GET /items/{id}"]; c.Issued != 1 {
+		t.Errorf("GET: %+v", c)
+	}
+	if c := got["POST /items/{id}"]; c.Validated != 2 || c.Rejected != 1 {
+		t.Errorf("POST: %+v", c)
+	}
+	if c := got[RouteUnmatched]; c.Rejected != 1 {
+		t.Errorf("unmatched: %+v", c)
+	}
+}
+
+func TestRouteStatsCap(t *testing.T) {
+	p := New(Config{TokenBytes: 16, RoutePattern: func(r *http.Request) string { return r.URL.Path }})
+	app := p.Protect(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for i := range maxRouteLabels + 10 {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, fmt.Sprintf("/%d", i), nil))
+	}
+	got := p.RouteStats()
+	if len(got) != maxRouteLabels+1 || got[RouteOther].Rejected != 10 {
+		t.Fatalf("got %d labels, other=%+v", len(got), got[RouteOther])
+	}
+}
+
+func TestContextRoutePattern(t *testing.T) {
+	r := WithRoutePattern(httptest.NewRequest(http.MethodGet, "/u/7", nil), "/u/:id")
+	if got := ContextRoutePattern(r); got != "/u/:id" {
+		t.Fatalf("got %q", got)
+	}
+}
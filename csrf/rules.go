@@ -0,0 +1,157 @@
+package csrf
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Rule customizes enforcement for a group of routes. A request matches when
+// its path starts with PathPrefix and, if Methods is not empty, its method is
+// listed. The first matching rule in Config.Rules applies.
+type Rule struct {
+	// PathPrefix selects the routes (e.g., "/account/delete").
+	PathPrefix string
+
+	// Methods optionally restricts the rule to these methods.
+	Methods []string
+
+	// Sensitive marks destructive routes (account deletion, payouts) that
+	// require a recently issued token: at most
+	// Config.MaxTokenAgeForSensitiveRoutes old unless MaxTokenAge is set.
+	Sensitive bool
+
+	// MaxTokenAge, when positive, is the maximum token age for this rule.
+	MaxTokenAge time.Duration
+
+	// ReportOnly lets failing requests on these routes through, reporting
+	// them as Config.ReportOnly does, e.g. while rolling out protection.
+	ReportOnly bool
+
+	// HeaderName and FormFields, when set, replace Config.HeaderName and
+	// Config.FormFields on these routes, for embedded third-party widgets
+	// or legacy subapps with their own fixed names.
+	HeaderName string
+	FormFields []string
+
+	// CookieScope gives the PathPrefix subtree (e.g., "/admin") its own
+	// token cookie, named CookieName plus the path ("csrf_token_admin") and
+	// restricted to that Path, so a token exposed by the rest of the site
+	// is not accepted there. The scope covers PathPrefix and the paths
+	// below it, for every method, so the rule must not set Methods; serve
+	// the token endpoint from inside the scope too.
+	CookieScope bool
+}
+
+// matches reports whether r is covered by the rule.
+func (rule *Rule) matches(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, rule.PathPrefix) {
+		return false
+	}
+	if len(rule.Methods) == 0 {
+		return true
+	}
+	for _, m := range rule.Methods {
+		if strings.EqualFold(m, r.Method) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleFor returns the first rule matching r, or nil.
+//
+// Params:
+// - r: incoming request.
+//
+// Returns:
+// - pointer into Config.Rules, or nil when no rule matches.
+func (p *Protector) ruleFor(r *http.Request) *Rule {
+	for i := range p.cfg.Rules {
+		if p.cfg.Rules[i].matches(r) {
+			return &p.cfg.Rules[i]
+		}
+	}
+	return nil
+}
+
+// tokenNames returns the header and form field names the client token is
+// read from under rule.
+//
+// Params:
+// - rule: the rule matching the request, or nil.
+//
+// Returns:
+// - the header name and the form field names.
+func (p *Protector) tokenNames(rule *Rule) (string, []string) {
+	header, fields := p.cfg.HeaderName, p.cfg.FormFields
+	if rule == nil {
+		return header, fields
+	}
+	if rule.HeaderName != "" {
+		header = rule.HeaderName
+	}
+	if len(rule.FormFields) > 0 {
+		fields = rule.FormFields
+	}
+	return header, fields
+}
+
+// freshnessFor returns the maximum token age a matching rule demands for r.
+//
+// Params:
+// - rule: the rule matching the request, or nil.
+//
+// Returns:
+// - the required maximum age, or 0 when the rule demands none.
+func (p *Protector) freshnessFor(rule *Rule) time.Duration {
+	switch {
+	case rule == nil:
+		return 0
+	case rule.MaxTokenAge > 0:
+		return rule.MaxTokenAge
+	case rule.Sensitive:
+		return p.cfg.MaxTokenAgeForSensitiveRoutes
+	default:
+		return 0
+	}
+}
+
+// reissueAge returns the age past which a safe request to r's path receives
+// a new token: the shorter of MaxTokenAge and the freshness demanded by the
+// first rule covering the path (regardless of method), so that loading the
+// page of a sensitive action yields a token fresh enough to submit it.
+//
+// Params:
+// - r: incoming safe request.
+//
+// Returns:
+// - the maximum age, or 0 when tokens never go stale.
+func (p *Protector) reissueAge(r *http.Request) time.Duration {
+	age := p.cfg.MaxTokenAge
+	for i := range p.cfg.Rules {
+		rule := &p.cfg.Rules[i]
+		if !strings.HasPrefix(r.URL.Path, rule.PathPrefix) {
+			continue
+		}
+		if fresh := p.freshnessFor(rule); fresh > 0 && (age <= 0 || fresh < age) {
+			age = fresh
+		}
+		break
+	}
+	return age
+}
+
+// needsIssuedAt reports whether any setting of cfg relies on the issuance
+// timestamp cookie.
+func (cfg *Config) needsIssuedAt() bool {
+	if cfg.MaxTokenAge > 0 || cfg.MaxTokenAgeForSensitiveRoutes > 0 {
+		return true
+	}
+	for _, rule := range cfg.Rules {
+		if rule.MaxTokenAge > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package csrf
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Sensitive routes refuse tokens older than MaxTokenAgeForSensitiveRoutes
+// while other routes keep accepting them; loading the page refreshes them.
+func TestSensitiveRouteFreshness(t *testing.T) {
+	p := New(Config{
+		TokenBytes:                    16,
+		MaxTokenAgeForSensitiveRoutes: 5 * time.Minute,
+		Rules: []Rule{
+			{PathPrefix: "/account/delete", Sensitive: true},
+			{PathPrefix: "/payout", Methods: []string{http.MethodPost}, MaxTokenAge: time.Minute},
+		},
+	})
+	if !p.Config().TrackIssuedAt {
+		t.Fatal("expected sensitive routes to imply TrackIssuedAt")
+	}
+	app := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	tok := &http.Cookie{Name: "csrf_token", Value: strings.Repeat("A", 22)}
+	send := func(method, path string, issued time.Time) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(tok)
+		if !issued.IsZero() {
+			req.AddCookie(&http.Cookie{Name: "csrf_token_iat", Value: strconv.FormatInt(issued.Unix(), 10)})
+		}
+		req.Header.Set("X-CSRF-Token", tok.Value)
+		app.ServeHTTP(rec, req)
+		return rec
+	}
+
+	old := time.Now().Add(-10 * time.Minute)
+	if rec := send(http.MethodPost, "/profile", old); rec.Code != http.StatusOK {
+		t.Fatalf("expected ordinary route to accept old token, got %d", rec.Code)
+	}
+	rec := send(http.MethodPost, "/account/delete", old)
+	if rec.Code != http.StatusForbidden || strings.TrimSpace(rec.Body.String()) != ErrTokenStale.Error() {
+		t.Fatalf("expected stale rejection, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodPost, "/account/delete", time.Time{}); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected token of unknown age to be refused, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, "/account/delete", time.Now()); rec.Code != http.StatusOK {
+		t.Fatalf("expected fresh token to pass, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, "/payout", time.Now().Add(-2*time.Minute)); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected rule MaxTokenAge to apply, got %d", rec.Code)
+	}
+
+	// loading the page of the sensitive action issues a fresh token
+	rec = send(http.MethodGet, "/account/delete", old)
+	if c := getCookieByName(rec.Result(), "csrf_token"); c == nil || c.Value == tok.Value {
+		t.Fatalf("expected page load to refresh the token, got %v", c)
+	}
+	rec = send(http.MethodGet, "/profile", old)
+	if c := getCookieByName(rec.Result(), "csrf_token"); c != nil {
+		t.Fatalf("expected ordinary page to keep the token, got %v", c)
+	}
+}
+
+// A rule's HeaderName and FormFields replace the global names on its routes
+// only.
+func TestRuleTokenNames(t *testing.T) {
+	p := New(Config{
+		TokenBytes: 16,
+		Rules:      []Rule{{PathPrefix: "/widget/", HeaderName: "X-Widget-Token", FormFields: []string{"authenticity_token"}}},
+	})
+	app := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(TemplateField(r)))
+	}))
+	tok := &http.Cookie{Name: "csrf_token", Value: strings.Repeat("A", 22)}
+	send := func(path string, set func(*http.Request)) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.AddCookie(tok)
+		set(req)
+		app.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	header := func(name string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set(name, tok.Value) }
+	}
+	form := func(field string) func(*http.Request) {
+		return func(req *http.Request) {
+			body := field + "=" + tok.Value
+			req.Body, req.ContentLength = io.NopCloser(strings.NewReader(body)), int64(len(body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+
+	if code := send("/widget/save", header("X-Widget-Token")); code != http.StatusOK {
+		t.Fatalf("expected the rule's header to be accepted, got %d", code)
+	}
+	if code := send("/widget/save", form("authenticity_token")); code != http.StatusOK {
+		t.Fatalf("expected the rule's form field to be accepted, got %d", code)
+	}
+	if code := send("/widget/save", header("X-CSRF-Token")); code != http.StatusForbidden {
+		t.Fatalf("expected the global header to be ignored on the rule's routes, got %d", code)
+	}
+	if code := send("/profile", header("X-Widget-Token")); code != http.StatusForbidden {
+		t.Fatalf("expected the rule's header to be ignored elsewhere, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widget/form", nil))
+	if !strings.Contains(rec.Body.String(), `name="authenticity_token"`) {
+		t.Fatalf("expected TemplateField to use the rule's field, got %q", rec.Body.String())
+	}
+}
+
+// A CookieScope rule isolates its subtree: the public token is refused there.
+func TestRuleCookieScope(t *testing.T) {
+	p := New(Config{TokenBytes: 16, Rules: []Rule{{PathPrefix: "/admin/", CookieScope: true}}})
+	app := p.Protect(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	issue := func(path string) *http.Cookie {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Result().Cookies()[0]
+	}
+	public, admin := issue("/"), issue("/admin/users")
+	if public.Name != "csrf_token" || public.Path != "/" {
+		t.Fatalf("public cookie %v", public)
+	}
+	if admin.Name != "csrf_token_admin" || admin.Path != "/admin" {
+		t.Fatalf("admin cookie %v", admin)
+	}
+
+	post := func(path string, c *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.AddCookie(c)
+		req.Header.Set("X-CSRF-Token", c.Value)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post("/admin/users", admin); code != http.StatusOK {
+		t.Fatalf("admin token on admin: got %d", code)
+	}
+	if code := post("/admin/users", public); code != http.StatusForbidden {
+		t.Fatalf("public token on admin: got %d", code)
+	}
+	if code := post("/administrators", public); code != http.StatusOK {
+		t.Fatalf("public token outside the scope: got %d", code)
+	}
+
+	if err := (Config{Rules: []Rule{{PathPrefix: "/admin", Methods: []string{"POST"}, CookieScope: true}}}).Validate(); err == nil {
+		t.Fatal("expected CookieScope with Methods to be rejected")
+	}
+}
@@ -0,0 +1,80 @@
+package csrf
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// cookieScope is a path scope with its own token cookie (Rule.CookieScope).
+type cookieScope struct {
+	// prefix is the scope path without trailing slash (e.g., "/admin").
+	prefix string
+	// suffix is appended to the cookie name (e.g., "_admin").
+	suffix string
+	// attrs is the pre-rendered attribute portion with Path set to prefix.
+	attrs string
+}
+
+// contains reports whether path lies in the scope, with the segment-aware
+// matching browsers apply to the cookie Path.
+func (s *cookieScope) contains(path string) bool {
+	return path == s.prefix || strings.HasPrefix(path, s.prefix+"/")
+}
+
+// compileScopes returns the cookie scopes declared by cfg.Rules, in order.
+func compileScopes(cfg Config) []cookieScope {
+	var scopes []cookieScope
+	for _, rule := range cfg.Rules {
+		if !rule.CookieScope {
+			continue
+		}
+		prefix := strings.TrimSuffix(rule.PathPrefix, "/")
+		scoped := cfg
+		scoped.CookiePath = prefix
+		scopes = append(scopes, cookieScope{
+			prefix: prefix,
+			suffix: strings.ReplaceAll(prefix, "/", "_"),
+			attrs:  renderCookieSuffix(scoped),
+		})
+	}
+	return scopes
+}
+
+// validateScopes checks the CookieScope rules of cfg: rooted, below "/",
+// method-independent and yielding valid cookie names.
+func validateScopes(cfg Config) error {
+	for _, rule := range cfg.Rules {
+		if !rule.CookieScope {
+			continue
+		}
+		prefix := strings.TrimSuffix(rule.PathPrefix, "/")
+		switch {
+		case !strings.HasPrefix(prefix, "/"):
+			return fmt.Errorf("csrf: CookieScope rule needs a PathPrefix below \"/\", got %q", rule.PathPrefix)
+		case len(rule.Methods) > 0:
+			return fmt.Errorf("csrf: CookieScope rule %q must not restrict Methods", rule.PathPrefix)
+		case !validCookieName("x" + strings.ReplaceAll(prefix, "/", "_")):
+			return fmt.Errorf("csrf: CookieScope rule %q does not yield a valid cookie name", rule.PathPrefix)
+		}
+	}
+	return nil
+}
+
+// scopeFor returns the cookie scope covering r's path, or nil.
+func (p *Protector) scopeFor(r *http.Request) *cookieScope {
+	for i := range p.scopes {
+		if p.scopes[i].contains(r.URL.Path) {
+			return &p.scopes[i]
+		}
+	}
+	return nil
+}
+
+// cookieAttrs returns the Set-Cookie attribute portion for r's scope.
+func (p *Protector) cookieAttrs(r *http.Request) string {
+	if s := p.scopeFor(r); s != nil {
+		return s.attrs
+	}
+	return p.cookieSuffix
+}
@@ -0,0 +1,313 @@
+package csrf
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider supplies the signing keys at runtime, so secrets never have
+// to be baked into the binary or static config (see Config.SecretProvider).
+// Implementations must be safe for concurrent use.
+type SecretProvider interface {
+	// Current returns the key new tokens are signed with.
+	Current() SigningKeyEntry
+
+	// All returns every key accepted for verification, including Current.
+	All() []SigningKeyEntry
+
+	// Refresh reloads the keys from their source.
+	Refresh(ctx context.Context) error
+}
+
+// SecretSnapshotter is implemented by SecretProviders that can return their
+// keys and the current key's ID read together. The middleware prefers it
+// to separate All and Current calls, between which a concurrent reload
+// could pair a current key with a key set that lacks it. The providers of
+// this package implement it.
+type SecretSnapshotter interface {
+	// Snapshot returns every key and the ID of the current one, from the
+	// same load.
+	Snapshot() (all []SigningKeyEntry, current string)
+}
+
+// secretSnapshot returns the keys of sp and the current key's ID, from one
+// Snapshot when sp implements SecretSnapshotter.
+//
+// Params:
+// - sp: the provider.
+//
+// Returns:
+// - all keys and the current key's ID.
+func secretSnapshot(sp SecretProvider) ([]SigningKeyEntry, string) {
+	if ss, ok := sp.(SecretSnapshotter); ok {
+		return ss.Snapshot()
+	}
+	return sp.All(), sp.Current().ID
+}
+
+// loadingProvider is a SecretProvider backed by a load function. The
+// current key is the first one not marked VerifyOnly.
+type loadingProvider struct {
+	load func(ctx context.Context) ([]SigningKeyEntry, error)
+
+	mu   sync.RWMutex
+	keys []SigningKeyEntry
+}
+
+// NewSecretProvider returns a SecretProvider whose keys come from load,
+// called now and on every Refresh. Use it to plug in a KMS or secret
+// manager; the current key is the first one not marked VerifyOnly.
+//
+// Params:
+// - ctx: context for the initial load.
+// - load: function fetching the keys.
+//
+// Returns:
+// - the provider, or the initial load's error.
+func NewSecretProvider(ctx context.Context, load func(ctx context.Context) ([]SigningKeyEntry, error)) (SecretProvider, error) {
+	sp := &loadingProvider{load: load}
+	if err := sp.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// Current returns the first key not marked VerifyOnly.
+func (sp *loadingProvider) Current() SigningKeyEntry {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	return currentKey(sp.keys)
+}
+
+// Snapshot returns a copy of the loaded keys and the current key's ID
+// under one lock.
+func (sp *loadingProvider) Snapshot() ([]SigningKeyEntry, string) {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	return append([]SigningKeyEntry(nil), sp.keys...), currentKey(sp.keys).ID
+}
+
+// currentKey returns the first of keys not marked VerifyOnly, or the zero
+// entry.
+func currentKey(keys []SigningKeyEntry) SigningKeyEntry {
+	for _, k := range keys {
+		if !k.VerifyOnly {
+			return k
+		}
+	}
+	return SigningKeyEntry{}
+}
+
+// All returns a copy of the loaded keys.
+func (sp *loadingProvider) All() []SigningKeyEntry {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	return append([]SigningKeyEntry(nil), sp.keys...)
+}
+
+// Refresh reloads the keys; on error (or invalid keys) the previous ones are
+// kept.
+func (sp *loadingProvider) Refresh(ctx context.Context) error {
+	keys, err := sp.load(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateKeys(keys, ""); err != nil {
+		return err
+	}
+	sp.mu.Lock()
+	sp.keys = keys
+	sp.mu.Unlock()
+	return nil
+}
+
+// EnvSecretProvider reads base64-encoded keys from environment variables,
+// named after the variables. The first variable holds the current key; the
+// others are VerifyOnly. Unset variables after the first are skipped.
+//
+// Params:
+// - vars: environment variable names, current key first.
+//
+// Returns:
+// - the provider, or an error if the keys are missing or invalid.
+func EnvSecretProvider(vars ...string) (SecretProvider, error) {
+	return NewSecretProvider(context.Background(), func(context.Context) ([]SigningKeyEntry, error) {
+		var keys []SigningKeyEntry
+		for i, name := range vars {
+			v := os.Getenv(name)
+			if v == "" {
+				if i == 0 {
+					return nil, fmt.Errorf("csrf: %s is not set", name)
+				}
+				continue
+			}
+			secret, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, fmt.Errorf("csrf: %s: %w", name, err)
+			}
+			keys = append(keys, SigningKeyEntry{ID: name, Secret: secret, VerifyOnly: i > 0})
+		}
+		return keys, nil
+	})
+}
+
+// fileKey is the JSON form of a SigningKeyEntry, with a base64 secret.
+type fileKey struct {
+	ID         string `json:"id"`
+	Secret     string `json:"secret"`
+	VerifyOnly bool   `json:"verifyOnly"`
+}
+
+// FileSecretProvider reads keys from a JSON file (e.g., a mounted secret):
+//
+//	[{"id": "2025", "secret": "<base64>"}, {"id": "2024", "secret": "<base64>", "verifyOnly": true}]
+//
+// Refresh re-reads the file, so rotating the mounted secret and calling
+// Protector.RefreshSecrets (or setting SecretRefreshInterval) takes effect
+// without a restart.
+//
+// Params:
+// - path: JSON file.
+//
+// Returns:
+// - the provider, or an error if the file cannot be read or is invalid.
+func FileSecretProvider(path string) (SecretProvider, error) {
+	return NewSecretProvider(context.Background(), func(context.Context) ([]SigningKeyEntry, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var fks []fileKey
+		if err := json.Unmarshal(b, &fks); err != nil {
+			return nil, fmt.Errorf("csrf: parse %s: %w", path, err)
+		}
+		return decodeFileKeys(fks)
+	})
+}
+
+// decodeFileKeys converts fileKeys, decoding their base64 secrets.
+func decodeFileKeys(fks []fileKey) ([]SigningKeyEntry, error) {
+	keys := make([]SigningKeyEntry, 0, len(fks))
+	for _, fk := range fks {
+		secret, err := base64.StdEncoding.DecodeString(fk.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("csrf: key %q: %w", fk.ID, err)
+		}
+		keys = append(keys, SigningKeyEntry{ID: fk.ID, Secret: secret, VerifyOnly: fk.VerifyOnly})
+	}
+	return keys, nil
+}
+
+// VaultSecretProvider fetches keys from a HashiCorp Vault KV version 2
+// secret whose "keys" field holds the same JSON array as FileSecretProvider.
+// Other secret managers exposing JSON over HTTP can be used through
+// NewSecretProvider in the same way.
+//
+// Params:
+// - ctx: context for the initial fetch.
+// - client: HTTP client (http.DefaultClient when nil).
+// - addr: Vault address (e.g., "https://vault.internal:8200").
+// - path: secret path including the mount (e.g., "secret/data/csrf").
+// - token: Vault token sent as X-Vault-Token.
+//
+// Returns:
+// - the provider, or the initial fetch's error.
+func VaultSecretProvider(ctx context.Context, client *http.Client, addr, path, token string) (SecretProvider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	url := strings.TrimRight(addr, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	return NewSecretProvider(ctx, func(ctx context.Context) ([]SigningKeyEntry, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Vault-Token", token)
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("csrf: vault %s: %s", path, res.Status)
+		}
+		var body struct {
+			Data struct {
+				Data struct {
+					Keys string `json:"keys"`
+				} `json:"data"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("csrf: vault %s: %w", path, err)
+		}
+		if body.Data.Data.Keys == "" {
+			return nil, errors.New(`csrf: vault secret has no "keys" field`)
+		}
+		var fks []fileKey
+		if err := json.Unmarshal([]byte(body.Data.Data.Keys), &fks); err != nil {
+			return nil, fmt.Errorf("csrf: vault %s: keys: %w", path, err)
+		}
+		return decodeFileKeys(fks)
+	})
+}
+
+// RefreshSecrets reloads the keys from SecretProvider and swaps the key ring
+// atomically; tokens signed with keys that remain valid keep verifying. It
+// is a no-op without a SecretProvider.
+//
+// Params:
+// - ctx: context for the provider's Refresh.
+//
+// Returns:
+// - the provider's error, or the validation error of the keys it returned
+// (none, or a current key that is missing or cannot sign), in which case
+// the current keys stay in use.
+func (p *Protector) RefreshSecrets(ctx context.Context) error {
+	sp := p.cfg.SecretProvider
+	if sp == nil {
+		return nil
+	}
+	if err := sp.Refresh(ctx); err != nil {
+		return err
+	}
+	all, current := secretSnapshot(sp)
+	if err := validateKeys(all, current); err != nil {
+		return err
+	}
+	p.keys.store(newKeyring(all, current, p.keys.ring.Load()))
+	return nil
+}
+
+// keyring returns the current key ring, starting a background refresh when
+// SecretRefreshInterval has elapsed since the last load.
+//
+// Returns:
+// - the ring, or nil when tokens are not signed.
+func (p *Protector) keyring() *keyring {
+	kr := p.keys.ring.Load()
+	every := p.cfg.SecretRefreshInterval
+	if kr == nil || every <= 0 || p.cfg.SecretProvider == nil {
+		return kr
+	}
+	now := time.Now().UnixNano()
+	if last := p.keys.loaded.Load(); now-last >= int64(every) && p.keys.loaded.CompareAndSwap(last, now) {
+		// failures are retried after another interval, not on every request
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), every)
+			defer cancel()
+			if err := p.RefreshSecrets(ctx); err != nil {
+				p.cfg.Logger.Warn("csrf: secret refresh failed, keeping current keys", "error", err)
+			}
+		}()
+	}
+	return kr
+}
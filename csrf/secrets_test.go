@@ -0,0 +1,174 @@
+package csrf
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Rotating the keys behind a provider keeps tokens signed with a key that is
+// still in the ring valid.
+func TestSecretProviderRotation(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	k2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+	t.Setenv("CSRF_KEY", k1)
+	t.Setenv("CSRF_KEY_OLD", "")
+
+	sp, err := EnvSecretProvider("CSRF_KEY", "CSRF_KEY_OLD")
+	if err != nil {
+		t.Fatalf("EnvSecretProvider: %v", err)
+	}
+	p := New(Config{TokenBytes: 16, SecretProvider: sp})
+	oldTok, _ := p.newToken()
+
+	t.Setenv("CSRF_KEY", k2)
+	t.Setenv("CSRF_KEY_OLD", k1)
+	if err := p.RefreshSecrets(context.Background()); err != nil {
+		t.Fatalf("RefreshSecrets: %v", err)
+	}
+	newTok, _ := p.newToken()
+	for _, tok := range []string{oldTok, newTok} {
+		if !p.verifyToken(tok) {
+			t.Fatalf("expected %q to verify after rotation", tok)
+		}
+	}
+	usage := p.KeyUsage()
+	if usage["CSRF_KEY"].Signed != 2 || !usage["CSRF_KEY_OLD"].VerifyOnly {
+		t.Fatalf("unexpected usage after rotation: %+v", usage)
+	}
+
+	t.Setenv("CSRF_KEY", "")
+	if err := p.RefreshSecrets(context.Background()); err == nil {
+		t.Fatal("expected a refresh without the current key to fail")
+	}
+	if !p.verifyToken(newTok) {
+		t.Fatal("expected a failed refresh to keep the current keys")
+	}
+}
+
+func TestFileSecretProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	keys, _ := json.Marshal([]fileKey{
+		{ID: "2024", Secret: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)), VerifyOnly: true},
+		{ID: "2025", Secret: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))},
+	})
+	os.WriteFile(path, keys, 0o600)
+
+	sp, err := FileSecretProvider(path)
+	if err != nil {
+		t.Fatalf("FileSecretProvider: %v", err)
+	}
+	if id := sp.Current().ID; id != "2025" || len(sp.All()) != 2 {
+		t.Fatalf("unexpected keys: current %q, %d total", id, len(sp.All()))
+	}
+	if _, err := FileSecretProvider(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("expected a missing file to fail")
+	}
+}
+
+func TestVaultSecretProvider(t *testing.T) {
+	keys, _ := json.Marshal([]fileKey{
+		{ID: "k1", Secret: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{3}, 32))},
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/csrf" || r.Header.Get("X-Vault-Token") != "s.token" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": map[string]string{"keys": string(keys)}}})
+	}))
+	defer srv.Close()
+
+	sp, err := VaultSecretProvider(context.Background(), srv.Client(), srv.URL, "secret/data/csrf", "s.token")
+	if err != nil {
+		t.Fatalf("VaultSecretProvider: %v", err)
+	}
+	if sp.Current().ID != "k1" {
+		t.Fatalf("unexpected current key %q", sp.Current().ID)
+	}
+	if _, err := VaultSecretProvider(context.Background(), srv.Client(), srv.URL, "secret/data/csrf", "wrong"); err == nil {
+		t.Fatal("expected a rejected token to fail")
+	}
+}
+
+func TestSecretProviderExcludesStaticKeys(t *testing.T) {
+	sp, _ := NewSecretProvider(context.Background(), func(context.Context) ([]SigningKeyEntry, error) {
+		return []SigningKeyEntry{{ID: "a", Secret: bytes.Repeat([]byte{1}, 32)}}, nil
+	})
+	if err := (Config{SecretProvider: sp, SigningKey: bytes.Repeat([]byte{1}, 32)}).Validate(); err == nil {
+		t.Fatal("expected SecretProvider with SigningKey to be rejected")
+	}
+}
+
+// stubProvider serves fixed keys, with no validation of its own.
+type stubProvider struct {
+	current string
+	keys    []SigningKeyEntry
+}
+
+func (s *stubProvider) Current() SigningKeyEntry {
+	for _, k := range s.keys {
+		if k.ID == s.current {
+			return k
+		}
+	}
+	return SigningKeyEntry{ID: s.current}
+}
+func (s *stubProvider) All() []SigningKeyEntry        { return s.keys }
+func (s *stubProvider) Refresh(context.Context) error { return nil }
+
+// A refresh returning no keys or an unknown current key is rejected and
+// the previous ring keeps signing.
+func TestRefreshSecretsValidatesKeys(t *testing.T) {
+	sp := &stubProvider{current: "a", keys: []SigningKeyEntry{{ID: "a", Secret: bytes.Repeat([]byte{1}, 32)}}}
+	p := New(Config{TokenBytes: 16, SecretProvider: sp})
+	tok, _ := p.newToken()
+
+	for name, bad := range map[string]*stubProvider{
+		"empty":   {current: "a"},
+		"unknown": {current: "b", keys: sp.keys},
+	} {
+		*sp = *bad
+		if err := p.RefreshSecrets(context.Background()); err == nil {
+			t.Fatalf("%s: expected the refresh to fail", name)
+		}
+		if !p.verifyToken(tok) {
+			t.Fatalf("%s: previous keys dropped", name)
+		}
+		if next, err := p.newToken(); err != nil || !p.verifyToken(next) || next == tok {
+			t.Fatalf("%s: signing broken after failed refresh: %q, %v", name, next, err)
+		}
+	}
+}
+
+// snapshotProvider answers All and Current from different loads, as if a
+// reload ran between them, but Snapshot consistently.
+type snapshotProvider struct {
+	stubProvider
+	snap []SigningKeyEntry
+}
+
+func (s *snapshotProvider) Snapshot() ([]SigningKeyEntry, string) { return s.snap, s.snap[0].ID }
+
+// RefreshSecrets validates and installs one consistent Snapshot.
+func TestRefreshSecretsSnapshot(t *testing.T) {
+	a := SigningKeyEntry{ID: "a", Secret: bytes.Repeat([]byte{1}, 32)}
+	b := SigningKeyEntry{ID: "b", Secret: bytes.Repeat([]byte{2}, 32)}
+	sp := &snapshotProvider{stubProvider: stubProvider{current: "a", keys: []SigningKeyEntry{a}}, snap: []SigningKeyEntry{a}}
+	p := New(Config{TokenBytes: 16, SecretProvider: sp})
+
+	// torn read: Current names b, All still holds only a
+	sp.current, sp.snap = "b", []SigningKeyEntry{b, a}
+	if err := p.RefreshSecrets(context.Background()); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if id := p.keys.ring.Load().signer.ID; id != "b" {
+		t.Fatalf("signing with %q, want b", id)
+	}
+}
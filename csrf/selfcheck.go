@@ -0,0 +1,83 @@
+package csrf
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// SelfCheck inspects the effective configuration and returns human-readable
+// findings: decisions taken automatically by New and settings that commonly
+// break CSRF protection in production. An empty result means nothing worth
+// reporting. It is meant to be logged once at startup.
+//
+// Returns:
+// - list of findings, in a stable order.
+func (p *Protector) SelfCheck() []string {
+	cfg := p.cfg
+	var out []string
+
+	out = append(out, fmt.Sprintf("SameSite=%s (%s)", sameSiteName(cfg.CookieSameSite), p.sameSiteReason))
+
+	if !cfg.CookieSecure {
+		out = append(out, "CookieSecure is false: enable it in production behind HTTPS")
+	}
+	if !cfg.EnforceOriginCheck {
+		out = append(out, "EnforceOriginCheck is false: Origin/Referer are not verified")
+	}
+	if cfg.ReportOnly {
+		out = append(out, "ReportOnly is true: failing requests are reported but not rejected")
+	}
+	if cfg.FaultInjector != nil {
+		out = append(out, "FaultInjector is set: CSRF failures are being injected (testing only)")
+	}
+	out = append(out, cookieDomainFindings(cfg)...)
+	return out
+}
+
+// cookieDomainFindings lints CookieDomain, the most common silent cause of
+// tokens that always fail: a public suffix (browsers drop the cookie), a
+// registrable domain (every subdomain receives the token and can overwrite
+// it), a "__Host-" cookie name (browsers drop it with a Domain) and
+// AllowedOrigins hosts outside the domain (their pages never see the
+// cookie).
+//
+// Params:
+// - cfg: effective configuration.
+//
+// Returns:
+// - findings, empty when CookieDomain is unset or consistent.
+func cookieDomainFindings(cfg Config) []string {
+	domain := strings.ToLower(strings.TrimPrefix(cfg.CookieDomain, "."))
+	if domain == "" {
+		return nil
+	}
+	var out []string
+	labels := strings.Split(domain, ".")
+	switch {
+	case len(labels) < 2 || slices.Contains(multiLabelSuffixes, domain):
+		out = append(out, fmt.Sprintf("CookieDomain %q is a public suffix: browsers reject the cookie", cfg.CookieDomain))
+	case slices.Contains(sharedHostingSuffixes, domain):
+		out = append(out, fmt.Sprintf("CookieDomain %q hosts other tenants: browsers reject the cookie or share it with every deployment under it", cfg.CookieDomain))
+	case len(labels) == 2 || len(labels) == 3 && slices.Contains(multiLabelSuffixes, strings.Join(labels[1:], ".")):
+		out = append(out, fmt.Sprintf("CookieDomain %q is a registrable domain: every subdomain receives the token and can overwrite it; leave it unset or use the app's own subdomain unless all subdomains are trusted", cfg.CookieDomain))
+	}
+	if strings.HasPrefix(cfg.CookieName, "__Host-") {
+		out = append(out, fmt.Sprintf("CookieName %q has the __Host- prefix but CookieDomain is set: browsers reject the cookie", cfg.CookieName))
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		host := strings.ToLower(origin)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			continue
+		}
+		if cfg.TokenCORSOrigin != "" && sameSite(cfg.TokenCORSOrigin, origin) {
+			continue // reads the token from TokenHandler
+		}
+		out = append(out, fmt.Sprintf("AllowedOrigins entry %q is outside CookieDomain %q: its pages cannot read the cookie, so their tokens always fail (serve them through TokenHandler with TokenCORSOrigin)", origin, cfg.CookieDomain))
+	}
+	return out
+}
@@ -0,0 +1,161 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// AutoSameSite picks Strict for host-only cookies and Lax with a CookieDomain.
+func TestAutoSameSite(t *testing.T) {
+	cases := []struct {
+		cfg  Config
+		want http.SameSite
+	}{
+		{Config{AutoSameSite: true}, http.SameSiteStrictMode},
+		{Config{AutoSameSite: true, CookieDomain: "example.com"}, http.SameSiteLaxMode},
+		{Config{AutoSameSite: true, CookieSameSite: http.SameSiteNoneMode}, http.SameSiteNoneMode},
+		{Config{}, http.SameSiteLaxMode},
+	}
+	for i, tc := range cases {
+		if got := New(tc.cfg).Config().CookieSameSite; got != tc.want {
+			t.Errorf("case %d: SameSite=%v want %v", i, got, tc.want)
+		}
+	}
+}
+
+// SelfCheck reports the SameSite decision and insecure settings.
+func TestSelfCheck(t *testing.T) {
+	findings := New(Config{AutoSameSite: true}).SelfCheck()
+	all := strings.Join(findings, "\n")
+	if !strings.Contains(all, "SameSite=Strict (auto: host-only cookie)") {
+		t.Fatalf("missing SameSite decision: %v", findings)
+	}
+	if !strings.Contains(all, "CookieSecure is false") {
+		t.Fatalf("missing CookieSecure finding: %v", findings)
+	}
+}
+
+// SelfCheck flags CookieDomain values that make tokens fail or leak to
+// untrusted subdomains.
+func TestSelfCheckCookieDomain(t *testing.T) {
+	cases := []struct {
+		cfg  Config
+		want string // substring of a finding; "" for none about the domain
+	}{
+		{Config{CookieDomain: "app.example.com", AllowedOrigins: []string{"app.example.com", "admin.app.example.com:8443"}}, ""},
+		{Config{CookieDomain: "example.com"}, "is a registrable domain"},
+		{Config{CookieDomain: ".example.co.uk"}, "is a registrable domain"},
+		{Config{CookieDomain: "co.uk"}, "is a public suffix"},
+		{Config{CookieDomain: "vercel.app"}, "hosts other tenants"},
+		{Config{CookieDomain: "app.example.com", CookieName: "__Host-csrf"}, "__Host- prefix"},
+		{Config{CookieDomain: "app.example.com", AllowedOrigins: []string{"www.example.org"}}, `"www.example.org" is outside CookieDomain`},
+		{Config{CookieDomain: "api.example.com", AllowedOrigins: []string{"app.example.org"}, TokenCORSOrigin: "https://app.example.org"}, ""},
+	}
+	for i, tc := range cases {
+		all := strings.Join(New(tc.cfg).SelfCheck(), "\n")
+		if tc.want == "" {
+			if strings.Contains(all, "CookieDomain") {
+				t.Errorf("case %d: unexpected finding: %s", i, all)
+			}
+			continue
+		}
+		if !strings.Contains(all, tc.want) {
+			t.Errorf("case %d: missing %q in: %s", i, tc.want, all)
+		}
+	}
+}
+
+// SameSite=None forces Secure, which SelfCheck reports.
+func TestSameSiteNoneForcesSecure(t *testing.T) {
+	p := New(Config{CookieSameSite: http.SameSiteNoneMode})
+	if !p.Config().CookieSecure {
+		t.Fatal("CookieSecure not forced")
+	}
+	rec := httptest.NewRecorder()
+	p.Protect(appHandler(p)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/submit", nil))
+	c := getCookieByName(rec.Result(), "csrf_token")
+	if c == nil || !c.Secure || c.SameSite != http.SameSiteNoneMode {
+		t.Fatalf("cookie = %+v, want Secure with SameSite=None", c)
+	}
+	all := strings.Join(p.SelfCheck(), "\n")
+	if !strings.Contains(all, "Secure forced by SameSite=None") || strings.Contains(all, "CookieSecure is false") {
+		t.Fatalf("findings: %s", all)
+	}
+}
+
+// Profiles are selected by name or environment variable; no name selects the
+// base and an unknown one panics.
+func TestProfiles(t *testing.T) {
+	cfg := Config{
+		CookieName: "base",
+		Profiles: map[string]Config{
+			"prod": {CookieName: "prod", CookieSecure: true},
+		},
+	}
+	if got := NewProfile(cfg, "prod").Config(); got.CookieName != "prod" || !got.CookieSecure {
+		t.Fatalf("expected prod profile, got %+v", got)
+	}
+	if got := NewProfile(cfg, "").Config(); got.CookieName != "base" || got.Profiles != nil {
+		t.Fatalf("expected base config without profiles, got %+v", got)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("unknown profile fell back to the base config")
+			}
+		}()
+		NewProfile(cfg, "prodution")
+	}()
+	if p, err := TryNewProfile(cfg, "prodution"); err == nil || p != nil {
+		t.Fatalf("expected an error for an unknown profile, got %v", p)
+	}
+
+	t.Setenv("CSRF_TEST_ENV", "prod")
+	if got := NewFromEnv(cfg, "CSRF_TEST_ENV").Config(); got.CookieName != "prod" {
+		t.Fatalf("expected prod profile from env, got %q", got.CookieName)
+	}
+}
+
+// The cross-subdomain SPA preset yields SameSite=None+Secure and CORS on the token endpoint.
+func TestCrossSubdomainSPA(t *testing.T) {
+	p := New(CrossSubdomainSPA("example.com", "app.example.com"))
+	cfg := p.Config()
+	if cfg.CookieSameSite != http.SameSiteNoneMode || !cfg.CookieSecure || cfg.CookieDomain != "example.com" {
+		t.Fatalf("unexpected cookie settings: %+v", cfg)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/csrf-token", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	tokenEndpointHandler(p).ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("missing CORS headers: %v", rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "https://api.example.com/csrf-token", nil)
+	req.Header.Set("Origin", "https://evil.example.net")
+	tokenEndpointHandler(p).ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("CORS must not be granted to other origins")
+	}
+}
+
+// Config returns a copy sharing nothing with the running Protector.
+func TestConfigIsCopy(t *testing.T) {
+	p := New(Config{
+		AllowedOrigins: []string{"app.example.com"},
+		Rules:          []Rule{{PathPrefix: "/admin", Methods: []string{"POST"}}},
+	})
+	cfg := p.Config()
+	cfg.AllowedOrigins[0] = "evil.example"
+	cfg.Rules[0].Methods[0] = "GET"
+	cfg.FormFields[0] = "other"
+
+	if live := p.Config(); live.AllowedOrigins[0] != "app.example.com" || live.Rules[0].Methods[0] != "POST" || live.FormFields[0] != "csrf_token" {
+		t.Fatalf("live config altered: %+v", live)
+	}
+}
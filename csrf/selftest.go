@@ -0,0 +1,158 @@
+package csrf
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// Synthetic requests of SelfTestHandler.
+const (
+	selfTestHost          = "csrf-selftest.invalid"
+	selfTestForeignOrigin = "https://csrf-selftest-attacker.invalid"
+	// selfTestSessionHeader carries the session of the synthetic requests
+	// in synchronizer token mode; only the probe Protector reads it.
+	selfTestSessionHeader = "X-CSRF-Self-Test-Session"
+)
+
+// SelfTestCheck is one step of a SelfTestHandler run.
+type SelfTestCheck struct {
+	Name   string `json:"name"`
+	Pass   bool   `json:"pass"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SelfTestResult is the JSON body written by SelfTestHandler.
+type SelfTestResult struct {
+	Pass       bool            `json:"pass"`
+	Checks     []SelfTestCheck `json:"checks"`
+	DurationMs int64           `json:"durationMs"`
+}
+
+// SelfTestHandler returns a handler running an end-to-end cycle through the
+// middleware on synthetic requests: a token is issued on a GET, a POST
+// carrying it (and an allowed Origin) must pass, a POST without it must be
+// rejected and, with EnforceOriginCheck, so must a POST from a foreign
+// origin. It answers 200 with a SelfTestResult when every check passes and
+// 503 otherwise, as a synthetic check target for uptime monitors after
+// deploys.
+//
+// The cycle runs on a clone of p with the same keys, cookie settings,
+// origin policy and SessionTokenStore, but without hooks, FailureLimiter,
+// Blocklist, Challenge, Rules, exemptions or fault injection, so it neither
+// pollutes counters and rejection logs nor depends on the caller.
+//
+// Returns:
+// - http.Handler writing the result as JSON.
+func (p *Protector) SelfTestHandler() http.Handler {
+	probe := p.Clone(func(c *Config) {
+		c.OnReject, c.OnRejectEvent, c.OnOriginMatch, c.ErrorHandler = nil, nil, nil, nil
+		c.FailureLimiter, c.Blocklist, c.Challenge = nil, nil, nil
+		c.FaultInjector, c.ReplayCapture = nil, 0
+		c.Rules, c.Profiles, c.RoutePattern = nil, nil, nil
+		c.Exempt, c.Attestor, c.TrustedNetworks = nil, nil, nil
+		c.OriginComparator, c.HostResolver = nil, nil
+		c.DeviceCookie, c.DeviceKey = "", nil
+		c.ReportOnly, c.SkipContextInjection, c.DeferIssuance, c.IssueOnNavigationOnly = false, false, false, false
+		c.IssuePredicate = func(*http.Request) bool { return true }
+		c.TokenPoolSize, c.CoalesceIssuance = 0, 0
+		c.PushTokenPath, c.EarlyHintsToken = "", false
+		if c.SessionTokenStore != nil {
+			c.SessionID = func(r *http.Request) string { return r.Header.Get(selfTestSessionHeader) }
+		}
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		res := probe.selfTest(r)
+		res.DurationMs = time.Since(start).Milliseconds()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !res.Pass {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+// selfTest runs the SelfTestHandler cycle on the probe Protector p.
+//
+// Params:
+// - r: the monitor's request (its context bounds store calls).
+//
+// Returns:
+// - the result, without duration.
+func (p *Protector) selfTest(r *http.Request) SelfTestResult {
+	origin := "https://" + selfTestHost
+	if len(p.cfg.AllowedOrigins) > 0 {
+		origin = "https://" + p.cfg.AllowedOrigins[0]
+	}
+	session := ""
+	if p.synchronized() {
+		id, err := newToken(16)
+		if err != nil {
+			return SelfTestResult{Checks: []SelfTestCheck{{Name: "issue", Detail: err.Error()}}}
+		}
+		session = "selftest-" + id
+		defer p.DeleteSessionToken(r.Context(), session)
+	}
+
+	var issued string
+	reached := false
+	h := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issued, _ = TokenFromContext(r.Context())
+		reached = true
+	}))
+	send := func(method, token, from string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequestWithContext(r.Context(), method, "https://"+selfTestHost+"/", nil)
+		if session != "" {
+			req.Header.Set(selfTestSessionHeader, session)
+		}
+		if token != "" {
+			req.Header.Set(p.cfg.HeaderName, token)
+		}
+		if from != "" {
+			req.Header.Set("Origin", from)
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		reached = false
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var res SelfTestResult
+	check := func(name string, pass bool, detail string) {
+		c := SelfTestCheck{Name: name, Pass: pass}
+		if !pass {
+			c.Detail = detail
+		}
+		res.Checks = append(res.Checks, c)
+	}
+
+	rec := send(http.MethodGet, "", "", nil)
+	check("issue", issued != "", "no token issued (status "+http.StatusText(rec.Code)+")")
+	if issued == "" {
+		return res
+	}
+	token, cookies := issued, rec.Result().Cookies()
+
+	rec = send(http.MethodPost, token, origin, cookies)
+	check("validate", reached, "valid request rejected: "+rec.Body.String())
+
+	send(http.MethodPost, "", origin, cookies)
+	check("reject_missing_token", !reached, "request without token passed")
+
+	if p.cfg.EnforceOriginCheck {
+		send(http.MethodPost, token, selfTestForeignOrigin, cookies)
+		check("reject_foreign_origin", !reached, "request from a foreign origin passed")
+	}
+
+	res.Pass = true
+	for _, c := range res.Checks {
+		res.Pass = res.Pass && c.Pass
+	}
+	return res
+}
@@ -0,0 +1,59 @@
+package csrf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// downSessionStore fails every call.
+type downSessionStore struct{}
+
+func (downSessionStore) Get(context.Context, string) (string, bool, error) {
+	return "", false, errors.New("down")
+}
+func (downSessionStore) Set(context.Context, string, string, time.Duration) error {
+	return errors.New("down")
+}
+func (downSessionStore) Delete(context.Context, string) error { return errors.New("down") }
+
+func TestSelfTestHandler(t *testing.T) {
+	run := func(p *Protector) (int, SelfTestResult) {
+		rec := httptest.NewRecorder()
+		p.SelfTestHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/csrf/selftest", nil))
+		var res SelfTestResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatalf("decode: %v (%s)", err, rec.Body.String())
+		}
+		return rec.Code, res
+	}
+
+	var rejected int
+	p := New(Config{
+		EnforceOriginCheck: true,
+		AllowedOrigins:     []string{"app.example.com"},
+		OnReject:           func(*http.Request, error) { rejected++ },
+	})
+	code, res := run(p)
+	if code != http.StatusOK || !res.Pass || len(res.Checks) != 4 {
+		t.Fatalf("got %d %+v", code, res)
+	}
+	if rejected != 0 {
+		t.Fatalf("self-test reached OnReject %d times", rejected)
+	}
+
+	code, res = run(New(Config{SessionTokenStore: NewMemoryTokenStore(), SessionID: func(*http.Request) string { return "" }}))
+	if code != http.StatusOK || !res.Pass {
+		t.Fatalf("synchronizer mode: got %d %+v", code, res)
+	}
+
+	// An unreachable store must fail the check.
+	code, res = run(New(Config{SessionTokenStore: downSessionStore{}, SessionID: func(*http.Request) string { return "" }}))
+	if code != http.StatusServiceUnavailable || res.Pass || res.Checks[0].Pass {
+		t.Fatalf("broken config: got %d %+v", code, res)
+	}
+}
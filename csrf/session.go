@@ -0,0 +1,49 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+)
+
+// errNoSessionHook is returned by RenewSession when OnSessionRenew is unset.
+var errNoSessionHook = errors.New("csrf: OnSessionRenew is not configured")
+
+// RenewSession rotates the application session and the CSRF token
+// together, as required on authentication changes (login, logout, privilege
+// elevation): rotating only one of them reintroduces login CSRF or session
+// fixation. It calls Config.OnSessionRenew first and rotates the CSRF token
+// only if the session was renewed, so a failure leaves both unchanged (from
+// the client's point of view, provided the hook sets no cookie on failure).
+//
+// Params:
+// - w: response writer to set the new cookies on (before the body is written).
+// - r: current request.
+//
+// Returns:
+// - the new CSRF token, or the hook's or token generation's error.
+func (p *Protector) RenewSession(w http.ResponseWriter, r *http.Request) (string, error) {
+	if p.cfg.OnSessionRenew == nil {
+		return "", errNoSessionHook
+	}
+	if err := p.cfg.OnSessionRenew(w, r); err != nil {
+		return "", err
+	}
+	return p.RotateToken(w, r)
+}
+
+// RenewSession renews the session and the token using the Protector that
+// handled r. See Protector.RenewSession.
+//
+// Params:
+// - w: response writer to set the new cookies on.
+// - r: current request (must have passed through Protect).
+//
+// Returns:
+// - the new token, or an error if no Protector is in the context or renewal fails.
+func RenewSession(w http.ResponseWriter, r *http.Request) (string, error) {
+	p, ok := ProtectorFromContext(r.Context())
+	if !ok {
+		return "", errNoProtector
+	}
+	return p.RenewSession(w, r)
+}
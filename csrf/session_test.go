@@ -0,0 +1,41 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// RenewSession rotates the CSRF token only after the session hook succeeds.
+func TestRenewSession(t *testing.T) {
+	fail := errors.New("store down")
+	var hookErr error
+	p := New(Config{TokenBytes: 16, OnSessionRenew: func(w http.ResponseWriter, _ *http.Request) error {
+		if hookErr != nil {
+			return hookErr
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "new"})
+		return nil
+	}})
+
+	rec := httptest.NewRecorder()
+	tok, err := p.RenewSession(rec, httptest.NewRequest(http.MethodPost, "/login", nil))
+	res := rec.Result()
+	if err != nil || getCookieByName(res, "session") == nil || getCookieByName(res, "csrf_token").Value != tok {
+		t.Fatalf("expected both cookies rotated, got %v (err %v)", res.Cookies(), err)
+	}
+
+	hookErr = fail
+	rec = httptest.NewRecorder()
+	if _, err := p.RenewSession(rec, httptest.NewRequest(http.MethodPost, "/login", nil)); !errors.Is(err, fail) {
+		t.Fatalf("expected hook error, got %v", err)
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Fatal("expected no CSRF rotation when the session hook fails")
+	}
+
+	if _, err := New(Config{}).RenewSession(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil)); err == nil {
+		t.Fatal("expected an error without OnSessionRenew")
+	}
+}
@@ -0,0 +1,119 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"slices"
+)
+
+// minSigningKeyBytes is the shortest signing key accepted by Validate.
+const minSigningKeyBytes = 32
+
+// signToken appends the region and an HMAC-SHA256 signature to the random
+// token raw: "<raw>.<region>.<signature>".
+//
+// Params:
+// - raw: random base64url token of TokenBytes.
+//
+// Returns:
+// - the signed token.
+func (p *Protector) signToken(raw string) string {
+	body := raw + "." + p.cfg.Region
+	return body + "." + base64.RawURLEncoding.EncodeToString(p.keyring().sign(body))
+}
+
+// acceptToken reports whether s is a usable cookie token: well-formed, and
+// correctly signed when tokens are signed.
+func (p *Protector) acceptToken(s string) bool {
+	if p.keyring() == nil {
+		return validToken(s, p.cfg.TokenBytes)
+	}
+	return p.verifyToken(s)
+}
+
+// verifyToken reports whether s is a token signed with a ring key whose
+// region is accepted (see Config.PeerRegions) and whose random part is
+// well-formed.
+//
+// Params:
+// - s: signed token, as found in the cookie.
+//
+// Returns:
+// - true if s can be trusted as minted by this deployment or a peer.
+func (p *Protector) verifyToken(s string) bool {
+	body, sig, ok := p.signedParts(s)
+	return ok && p.keyring().match(body, sig) != nil
+}
+
+// countVerified credits the ring key that signed tok, the cookie token of
+// a validated request, with one verification.
+//
+// Params:
+// - tok: the validated cookie (or session) token.
+func (p *Protector) countVerified(tok string) {
+	kr := p.keyring()
+	if kr == nil {
+		return
+	}
+	if body, sig, ok := p.signedParts(tok); ok {
+		if k := kr.match(body, sig); k != nil {
+			k.verified.Add(1)
+		}
+	}
+}
+
+// signedParts splits the signed token s into the signed body and the
+// signature, provided it is well-formed and its region accepted.
+func (p *Protector) signedParts(s string) (body string, sig []byte, ok bool) {
+	_, region, sig, err := ParseSignedToken(s, p.cfg.TokenBytes)
+	if err != nil || !p.regionAccepted(region) {
+		return "", nil, false
+	}
+	body, _, _ = cutLast(s, '.')
+	return body, sig, true
+}
+
+// wellFormed reports whether the client token s has the structure of a
+// token (signed or not, per the configuration), regardless of its value.
+func (p *Protector) wellFormed(s string) bool {
+	if p.keyring() == nil {
+		return len(s) <= MaxTokenLength && validToken(s, p.cfg.TokenBytes)
+	}
+	_, _, _, err := ParseSignedToken(s, p.cfg.TokenBytes)
+	return err == nil
+}
+
+// regionAccepted reports whether tokens minted in region are accepted: any
+// region when PeerRegions is empty, otherwise Region and PeerRegions only.
+func (p *Protector) regionAccepted(region string) bool {
+	if len(p.cfg.PeerRegions) == 0 {
+		return true
+	}
+	return region == p.cfg.Region || slices.Contains(p.cfg.PeerRegions, region)
+}
+
+// tokenMAC returns the HMAC-SHA256 of body under key.
+func tokenMAC(key []byte, body string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(body))
+	return m.Sum(nil)
+}
+
+// tokensMatch compares the client token against the (already validated)
+// cookie token in constant time. Signed tokens must match byte for byte;
+// plain tokens are compared after decoding, see tokensEqual.
+//
+// Params:
+// - client: token sent in the header or form.
+// - cookie: token from the cookie.
+//
+// Returns:
+// - true if the client proved it can read the cookie.
+func (p *Protector) tokensMatch(client, cookie string) bool {
+	if p.keyring() == nil {
+		return tokensEqual(client, cookie, p.cfg.TokenBytes)
+	}
+	return cookie != "" && subtle.ConstantTimeCompare([]byte(client), []byte(cookie)) == 1
+}
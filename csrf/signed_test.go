@@ -0,0 +1,96 @@
+package csrf
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Signed tokens minted by a peer region validate; forged or foreign ones are
+// ignored and replaced.
+func TestSignedTokensAcrossRegions(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	us := New(Config{TokenBytes: 16, SigningKey: key, Region: "us-east-1"})
+	eu := New(Config{TokenBytes: 16, SigningKey: key, Region: "eu-west-1", PeerRegions: []string{"us-east-1"}})
+	ap := New(Config{TokenBytes: 16, SigningKey: key, Region: "ap-south-1", PeerRegions: []string{"eu-west-1"}})
+
+	tok, err := us.newToken()
+	if err != nil || strings.Count(tok, ".") != 2 || !strings.Contains(tok, ".us-east-1.") {
+		t.Fatalf("unexpected signed token %q (err %v)", tok, err)
+	}
+
+	post := func(p *Protector, cookie string) int {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: cookie})
+		req.Header.Set("X-CSRF-Token", cookie)
+		rec := httptest.NewRecorder()
+		appHandler(p).ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post(eu, tok); code != http.StatusOK {
+		t.Fatalf("expected the peer region's token to pass, got %d", code)
+	}
+	if code := post(ap, tok); code != http.StatusForbidden {
+		t.Fatalf("expected a token from a non-peer region to fail, got %d", code)
+	}
+
+	forged := strings.Replace(tok, "us-east-1", "eu-west-1", 1)
+	if code := post(eu, forged); code != http.StatusForbidden {
+		t.Fatalf("expected a forged token to fail, got %d", code)
+	}
+	plain, _ := newToken(16)
+	if code := post(us, plain); code != http.StatusForbidden {
+		t.Fatalf("expected an unsigned token to fail, got %d", code)
+	}
+}
+
+func TestSigningKeyValidation(t *testing.T) {
+	if err := (Config{SigningKey: []byte("short")}).Validate(); err == nil {
+		t.Fatal("expected a short key to be rejected")
+	}
+	if err := (Config{SigningKey: bytes.Repeat([]byte{1}, 32), Region: "eu.west"}).Validate(); err == nil {
+		t.Fatal("expected a region containing a dot to be rejected")
+	}
+	if p, err := TryNew(Config{SigningKey: []byte("short")}); err == nil || p != nil {
+		t.Fatal("expected TryNew to return the validation error")
+	}
+}
+
+// The first signing key signs, retired keys only verify, and usage is
+// counted per key, once per validated request.
+func TestKeyRoles(t *testing.T) {
+	old := SigningKeyEntry{ID: "2024", Secret: bytes.Repeat([]byte{1}, 32)}
+	before := New(Config{TokenBytes: 16, SigningKeys: []SigningKeyEntry{old}})
+	oldTok, _ := before.newToken()
+
+	old.VerifyOnly = true
+	p := New(Config{TokenBytes: 16, SigningKeys: []SigningKeyEntry{
+		old,
+		{ID: "2025", Secret: bytes.Repeat([]byte{2}, 32)},
+	}})
+	newTok, _ := p.newToken()
+
+	for _, tok := range []string{oldTok, newTok} {
+		req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tok})
+		req.Header.Set("X-CSRF-Token", tok)
+		rec := httptest.NewRecorder()
+		appHandler(p).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %q to validate, got %d", tok, rec.Code)
+		}
+	}
+	usage := p.KeyUsage()
+	if usage["2024"].Signed != 0 || usage["2024"].Verified != 1 || !usage["2024"].VerifyOnly {
+		t.Fatalf("unexpected usage of the retired key: %+v", usage["2024"])
+	}
+	if usage["2025"].Signed != 1 || usage["2025"].Verified != 1 {
+		t.Fatalf("unexpected usage of the current key: %+v", usage["2025"])
+	}
+
+	if err := (Config{SigningKeys: []SigningKeyEntry{old}}).Validate(); err == nil {
+		t.Fatal("expected a ring without a signing key to be rejected")
+	}
+}
@@ -0,0 +1,50 @@
+package csrf
+
+import "net/http"
+
+// ProtectSSE wraps a Server-Sent Events endpoint. EventSource cannot set
+// custom headers but does carry credentials, so a cross-site page could open
+// the stream with the user's cookies. The initiating GET is therefore
+// checked like an unsafe request: its Origin (or Referer) must match the
+// allowed host or patterns, regardless of EnforceOriginCheck. A request
+// marked Sec-Fetch-Site: same-origin passes without them, since browsers omit
+// Origin on same-origin GETs.
+//
+// When tokenParam is not empty, the token must also be sent as that query
+// parameter (e.g., new EventSource("/events?csrf_token=" + token)).
+//
+// Other methods are handled by Protect as usual.
+//
+// Params:
+// - next: the SSE handler.
+// - tokenParam: query parameter carrying the token, or "" to skip the check.
+//
+// Returns:
+// - http.Handler enforcing the checks and wrapped with Protect.
+func (p *Protector) ProtectSSE(next http.Handler, tokenParam string) http.Handler {
+	return p.Protect(p.guard(next, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Header.Get("Sec-Fetch-Site") != "same-origin" {
+			if err := p.validateOriginOrReferer(r); err != nil {
+				p.reject(w, r, http.StatusForbidden, err)
+				return
+			}
+		}
+		if tokenParam != "" {
+			clientToken := r.URL.Query().Get(tokenParam)
+			if clientToken == "" {
+				p.reject(w, r, http.StatusForbidden, ErrMissingToken)
+				return
+			}
+			cookieToken, _ := p.cookieToken(r)
+			if !p.tokensMatch(clientToken, cookieToken) {
+				p.reject(w, r, http.StatusForbidden, ErrTokenMismatch)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,41 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// The SSE GET is origin-checked and, when configured, needs the query token.
+func TestProtectSSE(t *testing.T) {
+	p := New(Config{TokenBytes: 16})
+	token, _ := newToken(16)
+	h := p.ProtectSSE(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}), "csrf_token")
+
+	cases := []struct {
+		name   string
+		target string
+		header map[string]string
+		want   int
+	}{
+		{"cross-site", "/events?csrf_token=" + token, map[string]string{"Origin": "https://evil.test"}, http.StatusForbidden},
+		{"no origin", "/events?csrf_token=" + token, nil, http.StatusForbidden},
+		{"missing token", "/events", map[string]string{"Origin": "http://example.com"}, http.StatusForbidden},
+		{"same origin", "/events?csrf_token=" + token, map[string]string{"Origin": "http://example.com"}, http.StatusOK},
+		{"fetch metadata", "/events?csrf_token=" + token, map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+		for k, v := range tc.header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package csrf
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// issueCoalescer remembers tokens minted in the last window per client key,
+// so concurrent requests of one client replacing its stale cookie share a
+// single token (Config.CoalesceIssuance).
+type issueCoalescer struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*coalescedToken
+	ops     int
+
+	now func() time.Time
+}
+
+// coalescedToken is a token minted, or being minted, for one client key.
+// tok and err are set before done is closed.
+type coalescedToken struct {
+	at   time.Time
+	done chan struct{}
+	tok  string
+	err  error
+}
+
+// newIssueCoalescer returns a coalescer, or nil when window is not positive.
+func newIssueCoalescer(window time.Duration) *issueCoalescer {
+	if window <= 0 {
+		return nil
+	}
+	return &issueCoalescer{window: window, entries: make(map[string]*coalescedToken), now: time.Now}
+}
+
+// mintToken returns a new token for r; with CoalesceIssuance, safe requests
+// carrying the same stale cookie as one handed a token within the window
+// get that token again instead, so parallel reloads set one cookie value. The lock
+// only covers the map: the token is minted outside it while requests of the
+// same client wait for that one mint, so other clients are never held up.
+//
+// Params:
+// - r: request the token is minted for.
+//
+// Returns:
+// - the token, or an error if generation fails.
+func (p *Protector) mintToken(r *http.Request) (string, error) {
+	c := p.coalescer
+	if c == nil || unsafeMethods[r.Method] {
+		return p.newToken()
+	}
+	key, ok := p.coalesceKey(r)
+	if !ok {
+		return p.newToken()
+	}
+
+	c.mu.Lock()
+	now := c.now()
+	if e, ok := c.entries[key]; ok && now.Sub(e.at) < c.window {
+		c.mu.Unlock()
+		<-e.done
+		if e.err != nil {
+			return "", e.err
+		}
+		p.stats.coalesced.Add(1)
+		return e.tok, nil
+	}
+	e := &coalescedToken{at: now, done: make(chan struct{})}
+	c.entries[key] = e
+	c.ops++
+	if c.ops >= sweepEvery {
+		c.ops = 0
+		for k, old := range c.entries {
+			if now.Sub(old.at) >= c.window {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	e.tok, e.err = p.newToken()
+	if e.err != nil {
+		c.mu.Lock()
+		if c.entries[key] == e {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	close(e.done)
+	return e.tok, e.err
+}
+
+// coalesceKey identifies the client of r for coalescing by the unusable
+// cookie it sent (stale, from rotated keys), with its IP and User-Agent.
+// Requests without a cookie are never coalesced: neither IP nor User-Agent
+// tells users behind one NAT apart, and sharing a token with another user
+// would let them forge requests.
+//
+// Params:
+// - r: incoming safe request.
+//
+// Returns:
+// - the key and whether r can be coalesced.
+func (p *Protector) coalesceKey(r *http.Request) (string, bool) {
+	c, err := r.Cookie(p.cookieName(r))
+	if err != nil || c.Value == "" || len(c.Value) > p.cfg.MaxCookieBytes {
+		return "", false
+	}
+	device, _ := p.deviceID(r)
+	return c.Value + "\x00" + clientIP(r, p.cfg.TrustedProxies) + "\x00" + r.UserAgent() + "\x00" + device, true
+}
@@ -0,0 +1,54 @@
+package csrf
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Concurrent requests sending one stale cookie share a token, over any of
+// their connections; requests without a cookie or with another one do not.
+func TestCoalesceIssuance(t *testing.T) {
+	p := New(Config{TokenBytes: 16, CoalesceIssuance: time.Minute})
+	app := appHandler(p)
+	issue := func(remote string, cookie *http.Cookie) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return getCookieByName(rec.Result(), "csrf_token").Value
+	}
+
+	stale := &http.Cookie{Name: "csrf_token", Value: "stale"}
+	var wg sync.WaitGroup
+	tokens := make([]string, 8)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i] = issue(fmt.Sprintf("203.0.113.7:%d", 40000+i), stale)
+		}()
+	}
+	wg.Wait()
+	for _, tok := range tokens[1:] {
+		if tok != tokens[0] {
+			t.Fatalf("expected one token per stale cookie, got %v", tokens)
+		}
+	}
+	if issue("203.0.113.7:40000", &http.Cookie{Name: "csrf_token", Value: "other"}) == tokens[0] {
+		t.Fatal("another stale cookie got the same token")
+	}
+	// users behind one NAT with the same browser never share a token
+	if issue("203.0.113.7:40000", nil) == issue("203.0.113.7:40001", nil) {
+		t.Fatal("requests without a cookie were coalesced")
+	}
+	if n := p.stats.coalesced.Load(); n != 7 {
+		t.Fatalf("coalesced = %d", n)
+	}
+}
@@ -0,0 +1,164 @@
+package csrf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// formStashSuffix is appended to CookieName to name the stash cookie.
+	formStashSuffix = "_form"
+
+	// formStashTTL bounds how long stashed values survive.
+	formStashTTL = 5 * time.Minute
+
+	// defaultFormStashMaxBytes is the default FormStashMaxBytes.
+	defaultFormStashMaxBytes = 2048
+)
+
+// sensitiveFieldHints are substrings of form field names (lowercase) that
+// are never stashed.
+var sensitiveFieldHints = []string{"pass", "secret", "token", "card", "cvv", "cvc", "ssn", "pin", "otp"}
+
+// stashForm saves the non-sensitive fields of a rejected form post in a
+// short-lived encrypted cookie, so the page the user returns to can
+// re-populate them with StashedForm. Nothing is stashed unless
+// FormStashKey is set, the request is a parsed url-encoded form and its
+// Origin/Referer is same-site: values posted cross-site must never end up
+// pre-filled in the user's forms.
+//
+// Params:
+// - w: response writer of the rejected request.
+// - r: the rejected request.
+func (p *Protector) stashForm(w http.ResponseWriter, r *http.Request) {
+	if len(p.cfg.FormStashKey) == 0 || r.PostForm == nil || len(r.PostForm) == 0 {
+		return
+	}
+	if p.validateOriginOrReferer(r) != nil {
+		return
+	}
+	_, fields := p.tokenNames(p.ruleFor(r))
+	kept := url.Values{}
+	for name, vals := range r.PostForm {
+		if slices.Contains(fields, name) || sensitiveField(name) {
+			continue
+		}
+		kept[name] = vals
+	}
+	if len(kept) == 0 {
+		return
+	}
+	sealed, err := p.sealStash([]byte(kept.Encode()))
+	if err != nil || len(sealed) > p.cfg.FormStashMaxBytes {
+		return
+	}
+	w.Header().Add("Set-Cookie", (&http.Cookie{
+		Name:     p.cookieName(r) + formStashSuffix,
+		Value:    sealed,
+		Path:     p.cfg.CookiePath,
+		Domain:   p.cfg.CookieDomain,
+		MaxAge:   int(formStashTTL / time.Second),
+		Secure:   p.cfg.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}).String())
+}
+
+// StashedForm returns the form values stashed by a rejected form post (see
+// Config.FormStashKey) and clears the stash cookie, so a retry page can
+// re-populate its fields once.
+//
+// Params:
+// - w: response writer used to clear the stash cookie.
+// - r: request possibly carrying the stash cookie.
+//
+// Returns:
+//   - the stashed values and true, or nil and false when there is no valid,
+//     unexpired stash.
+func (p *Protector) StashedForm(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	c, err := r.Cookie(p.cookieName(r) + formStashSuffix)
+	if err != nil || len(p.cfg.FormStashKey) == 0 {
+		return nil, false
+	}
+	w.Header().Add("Set-Cookie", (&http.Cookie{
+		Name:   c.Name,
+		Path:   p.cfg.CookiePath,
+		Domain: p.cfg.CookieDomain,
+		MaxAge: -1,
+	}).String())
+	plain, err := p.openStash(c.Value)
+	if err != nil {
+		return nil, false
+	}
+	vals, err := url.ParseQuery(string(plain))
+	if err != nil {
+		return nil, false
+	}
+	return vals, true
+}
+
+// sealStash encrypts plain with AES-GCM under FormStashKey, prefixed with
+// the current time, and returns it base64url-encoded.
+func (p *Protector) sealStash(plain []byte) (string, error) {
+	aead, err := p.stashAEAD()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+8+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	msg := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(plain)), uint64(time.Now().Unix()))
+	msg = append(msg, plain...)
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, msg, []byte(p.cfg.CookieName))), nil
+}
+
+// openStash reverses sealStash, rejecting tampered or expired stashes.
+func (p *Protector) openStash(s string) ([]byte, error) {
+	aead, err := p.stashAEAD()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, errBadStash
+	}
+	msg, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(p.cfg.CookieName))
+	if err != nil || len(msg) < 8 {
+		return nil, errBadStash
+	}
+	if time.Since(time.Unix(int64(binary.BigEndian.Uint64(msg)), 0)) > formStashTTL {
+		return nil, errBadStash
+	}
+	return msg[8:], nil
+}
+
+func (p *Protector) stashAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(p.cfg.FormStashKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var errBadStash = errors.New("invalid form stash")
+
+// sensitiveField reports whether a form field name suggests a secret.
+func sensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, hint := range sensitiveFieldHints {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package csrf
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// A same-site form post rejected for its token has its non-sensitive fields
+// stashed and read back once; cross-site posts are never stashed.
+func TestFormStash(t *testing.T) {
+	p := New(Config{TokenBytes: 16, FormStashKey: bytes.Repeat([]byte{7}, 32)})
+	app := appHandler(p)
+
+	post := func(origin string) *http.Response {
+		form := url.Values{"title": {"hello"}, "password": {"hunter2"}, "csrf_token": {"stale"}}
+		req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	if getCookieByName(post("https://evil.test"), "csrf_token_form") != nil {
+		t.Fatal("expected no stash for a cross-site post")
+	}
+	res := post("http://example.com")
+	stash := getCookieByName(res, "csrf_token_form")
+	if res.StatusCode != http.StatusForbidden || stash == nil || !stash.HttpOnly {
+		t.Fatalf("expected an HttpOnly stash cookie on rejection, got %d %v", res.StatusCode, stash)
+	}
+	if strings.Contains(stash.Value, "hello") {
+		t.Fatal("expected the stash to be encrypted")
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.AddCookie(stash)
+	vals, ok := p.StashedForm(rec, req)
+	if !ok || vals.Get("title") != "hello" || vals.Has("password") || vals.Has("csrf_token") {
+		t.Fatalf("unexpected stashed values %v (ok=%v)", vals, ok)
+	}
+	if c := getCookieByName(rec.Result(), "csrf_token_form"); c == nil || c.MaxAge >= 0 {
+		t.Fatalf("expected the stash cookie to be cleared, got %v", c)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf_token_form", Value: stash.Value[:len(stash.Value)-2] + "AA"})
+	if _, ok := p.StashedForm(httptest.NewRecorder(), req); ok {
+		t.Fatal("expected a tampered stash to be ignored")
+	}
+}
+
+func TestFormStashKeyLength(t *testing.T) {
+	if err := (Config{FormStashKey: []byte("short")}).Validate(); err == nil {
+		t.Fatal("expected an invalid key length to be rejected")
+	}
+}
@@ -0,0 +1,63 @@
+package csrf
+
+import "sync/atomic"
+
+// counters holds the lifetime event counters of a Protector.
+type counters struct {
+	issued    atomic.Int64 // tokens minted and set as cookie
+	validated atomic.Int64 // unsafe requests that passed validation
+	rejected  atomic.Int64 // unsafe requests rejected by validation
+	limited   atomic.Int64 // unsafe requests denied by FailureLimiter
+	blocked   atomic.Int64 // unsafe requests denied by the Blocklist
+	reported  atomic.Int64 // failures let through in report-only mode
+
+	tokenDenied atomic.Int64 // token endpoint requests refused (cross-site or rate-limited)
+	challenged  atomic.Int64 // rejections answered by Challenge
+	coalesced   atomic.Int64 // safe requests handed a token minted concurrently for the same client
+	attested    atomic.Int64 // unsafe requests let through by Attestor
+	tokenPushed atomic.Int64 // token endpoint responses pushed with a page (PushTokenPath)
+	earlyHints  atomic.Int64 // 103 Early Hints sent with the token (EarlyHintsToken)
+
+	clientReports atomic.Int64 // reports received by ReportHandler
+
+	poolHits   atomic.Int64 // tokens served from the token pool
+	poolMisses atomic.Int64 // tokens generated inline because the pool was empty
+
+	breakerSkipped atomic.Int64 // store checks skipped while StoreBreaker was open
+	panics         atomic.Int64 // requests rejected because the middleware or a hook panicked
+
+	degradedSigned       atomic.Int64 // requests served at LevelSigned because stores failed
+	degradedDoubleSubmit atomic.Int64 // requests served at LevelDoubleSubmit because stores failed
+	levelChanges         atomic.Int64 // SecurityLevel transitions
+}
+
+// snapshot returns the current counter values keyed by name.
+func (c *counters) snapshot() map[string]int64 {
+	return map[string]int64{
+		"issued":    c.issued.Load(),
+		"validated": c.validated.Load(),
+		"rejected":  c.rejected.Load(),
+		"limited":   c.limited.Load(),
+		"blocked":   c.blocked.Load(),
+		"reported":  c.reported.Load(),
+
+		"tokenDenied": c.tokenDenied.Load(),
+		"challenged":  c.challenged.Load(),
+		"coalesced":   c.coalesced.Load(),
+		"attested":    c.attested.Load(),
+		"tokenPushed": c.tokenPushed.Load(),
+		"earlyHints":  c.earlyHints.Load(),
+
+		"clientReports": c.clientReports.Load(),
+
+		"poolHits":   c.poolHits.Load(),
+		"poolMisses": c.poolMisses.Load(),
+
+		"breakerSkipped": c.breakerSkipped.Load(),
+		"panics":         c.panics.Load(),
+
+		"degradedSigned":       c.degradedSigned.Load(),
+		"degradedDoubleSubmit": c.degradedDoubleSubmit.Load(),
+		"levelChanges":         c.levelChanges.Load(),
+	}
+}
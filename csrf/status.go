@@ -0,0 +1,59 @@
+package csrf
+
+import "net/http"
+
+// Protection describes how the middleware treats a route's unsafe requests.
+type Protection string
+
+const (
+	// ProtectionEnforce rejects unsafe requests that fail the checks.
+	ProtectionEnforce Protection = "enforce"
+
+	// ProtectionReportOnly reports failures (OnReject, OnRejectEvent, the
+	// "reported" counter) but lets the request through.
+	ProtectionReportOnly Protection = "report-only"
+
+	// ProtectionSkipped means the checks did not run (TrustedNetworks or
+	// Exempt).
+	ProtectionSkipped Protection = "skipped"
+)
+
+// protectionFor returns the protection configured for requests matching
+// rule: report-only when ReportOnly is set globally or on the rule,
+// enforce otherwise.
+func (p *Protector) protectionFor(rule *Rule) Protection {
+	if p.cfg.ReportOnly || rule != nil && rule.ReportOnly {
+		return ProtectionReportOnly
+	}
+	return ProtectionEnforce
+}
+
+// setStatusHeader writes the protection applied to the request in
+// StatusHeader, when set.
+//
+// Params:
+// - w: response writer.
+// - status: protection applied.
+func (p *Protector) setStatusHeader(w http.ResponseWriter, status Protection) {
+	if p.cfg.StatusHeader != "" {
+		w.Header().Set(p.cfg.StatusHeader, string(status))
+	}
+}
+
+// report records a check failure in report-only mode: it is counted and
+// passed to OnReject and OnRejectEvent like a rejection, but nothing is
+// written to the response.
+//
+// Params:
+// - r: the request that failed the checks.
+// - err: the reason it would have been rejected.
+func (p *Protector) report(r *http.Request, err error) {
+	p.stats.reported.Add(1)
+	p.window.add(windowReported)
+	if p.cfg.OnReject != nil {
+		p.cfg.OnReject(r, err)
+	}
+	if p.cfg.OnRejectEvent != nil {
+		p.cfg.OnRejectEvent(p.NewRejectionEvent(r, err))
+	}
+}
@@ -0,0 +1,49 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Report-only routes let failing requests through and report them; the
+// status header tells which protection applied.
+func TestReportOnlyAndStatusHeader(t *testing.T) {
+	var reported []error
+	p := New(Config{
+		TokenBytes:   16,
+		StatusHeader: "X-CSRF-Protected",
+		Rules:        []Rule{{PathPrefix: "/beta/", ReportOnly: true}},
+		Exempt:       func(r *http.Request) bool { return r.URL.Path == "/webhook" },
+		OnReject:     func(_ *http.Request, err error) { reported = append(reported, err) },
+	})
+	app := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	cases := []struct {
+		method, path string
+		code         int
+		status       Protection
+	}{
+		{http.MethodGet, "/beta/page", http.StatusOK, ProtectionReportOnly},
+		{http.MethodGet, "/page", http.StatusOK, ProtectionEnforce},
+		{http.MethodPost, "/beta/save", http.StatusOK, ProtectionReportOnly},
+		{http.MethodPost, "/save", http.StatusForbidden, ProtectionEnforce},
+		{http.MethodPost, "/webhook", http.StatusOK, ProtectionSkipped},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != tc.code || rec.Header().Get("X-CSRF-Protected") != string(tc.status) {
+			t.Errorf("%s %s: got %d %q, want %d %q", tc.method, tc.path, rec.Code, rec.Header().Get("X-CSRF-Protected"), tc.code, tc.status)
+		}
+	}
+	if len(reported) != 2 || !errors.Is(reported[0], ErrMissingToken) {
+		t.Fatalf("expected both failures to be reported, got %v", reported)
+	}
+	if n := p.stats.reported.Load(); n != 1 {
+		t.Fatalf("expected 1 reported failure, got %d", n)
+	}
+}
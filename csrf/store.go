@@ -0,0 +1,157 @@
+package csrf
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenStore keeps single-use keys with an expiry, for form nonces.
+// Implementations must be safe for concurrent use and may be backed by
+// shared storage (e.g., Redis SET with EX, and GETDEL) so a nonce issued by
+// one instance can be consumed by another.
+type TokenStore interface {
+	// Put stores key for ttl.
+	Put(ctx context.Context, key string, ttl time.Duration) error
+
+	// Take deletes key and reports whether it was present and unexpired.
+	// Concurrent Takes of one key must succeed at most once.
+	Take(ctx context.Context, key string) (bool, error)
+}
+
+// SessionTokenStore keeps the token of each session for the synchronizer
+// token mode (see Config.SessionTokenStore). Implementations must be safe
+// for concurrent use; back them with shared storage (e.g., Redis GET, SET
+// with EX and DEL) when several instances serve one session.
+type SessionTokenStore interface {
+	// Get returns the unexpired token stored under key, if any.
+	Get(ctx context.Context, key string) (string, bool, error)
+
+	// Set stores token under key for ttl, replacing any previous token.
+	Set(ctx context.Context, key, token string, ttl time.Duration) error
+
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryTokenStore is an in-memory TokenStore and SessionTokenStore, for
+// single-instance deployments and tests. Expired keys are dropped on access,
+// by periodic sweeps on writes and by Sweep / RunGC, so idle sessions do not
+// accumulate once writes stop.
+type MemoryTokenStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	ops     int
+
+	now func() time.Time
+}
+
+// memoryEntry is a stored value and its expiry.
+type memoryEntry struct {
+	value   string
+	expires time.Time
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+// Put stores key until now + ttl.
+func (s *MemoryTokenStore) Put(ctx context.Context, key string, ttl time.Duration) error {
+	return s.Set(ctx, key, "", ttl)
+}
+
+// Take deletes key and reports whether it was unexpired.
+func (s *MemoryTokenStore) Take(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return false, nil
+	}
+	delete(s.entries, key)
+	return s.now().Before(e.expires), nil
+}
+
+// Get returns the value of key if it is unexpired.
+func (s *MemoryTokenStore) Get(_ context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return "", false, nil
+	}
+	if !s.now().Before(e.expires) {
+		delete(s.entries, key)
+		return "", false, nil
+	}
+	return e.value, true, nil
+}
+
+// Set stores token under key until now + ttl.
+func (s *MemoryTokenStore) Set(_ context.Context, key, token string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.entries[key] = memoryEntry{value: token, expires: now.Add(ttl)}
+
+	s.ops++
+	if s.ops >= sweepEvery {
+		s.sweep(now)
+	}
+	return nil
+}
+
+// Sweep drops every expired key.
+//
+// Returns:
+// - the number of keys dropped.
+func (s *MemoryTokenStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sweep(s.now())
+}
+
+// RunGC calls Sweep every interval until ctx is done. The store starts no
+// goroutine of its own; run it with go and cancel ctx on shutdown.
+//
+// Params:
+// - ctx: stops the loop when done.
+// - every: interval between sweeps (default 1m when <= 0).
+func (s *MemoryTokenStore) RunGC(ctx context.Context, every time.Duration) {
+	if every <= 0 {
+		every = time.Minute
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Sweep()
+		}
+	}
+}
+
+// sweep drops the keys expired at now; s.mu must be held.
+func (s *MemoryTokenStore) sweep(now time.Time) int {
+	s.ops = 0
+	n := 0
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+// Delete removes key.
+func (s *MemoryTokenStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
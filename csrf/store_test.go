@@ -0,0 +1,89 @@
+package csrf
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Keys are taken at most once and not after they expire.
+func TestMemoryTokenStore(t *testing.T) {
+	s := NewMemoryTokenStore()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	s.Put(ctx, "a", time.Minute)
+	s.Put(ctx, "b", time.Minute)
+	if ok, _ := s.Take(ctx, "a"); !ok {
+		t.Fatal("fresh key not taken")
+	}
+	if ok, _ := s.Take(ctx, "a"); ok {
+		t.Fatal("key taken twice")
+	}
+	now = now.Add(2 * time.Minute)
+	if ok, _ := s.Take(ctx, "b"); ok {
+		t.Fatal("expired key taken")
+	}
+}
+
+// Session tokens are replaced by Set and expire like nonces.
+func TestMemoryTokenStoreSessions(t *testing.T) {
+	s := NewMemoryTokenStore()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	s.Set(ctx, "k", "t1", time.Minute)
+	s.Set(ctx, "k", "t2", time.Minute)
+	if v, ok, _ := s.Get(ctx, "k"); !ok || v != "t2" {
+		t.Fatalf("Get = %q, %v", v, ok)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, ok, _ := s.Get(ctx, "k"); ok {
+		t.Fatal("expired token returned")
+	}
+	s.Set(ctx, "k", "t3", time.Minute)
+	s.Delete(ctx, "k")
+	if _, ok, _ := s.Get(ctx, "k"); ok {
+		t.Fatal("deleted token returned")
+	}
+}
+
+// Sweep and RunGC drop expired keys without further writes.
+func TestMemoryTokenStoreGC(t *testing.T) {
+	s := NewMemoryTokenStore()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.Set(ctx, "short", "t", time.Minute)
+	s.Put(ctx, "long", time.Hour)
+	now = now.Add(2 * time.Minute)
+	if n := s.Sweep(); n != 1 {
+		t.Fatalf("Sweep dropped %d keys, want 1", n)
+	}
+	if ok, _ := s.Take(ctx, "long"); !ok {
+		t.Fatal("unexpired key swept")
+	}
+
+	s.Put(ctx, "k", time.Minute)
+	now = now.Add(2 * time.Minute) // before RunGC starts, so no race
+	done := make(chan struct{})
+	go func() { s.RunGC(ctx, time.Millisecond); close(done) }()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.entries)
+		s.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("RunGC did not sweep")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,26 @@
+package csrf
+
+import "net/http"
+
+// ProtectStreaming is Protect for handlers that start streaming the response
+// immediately (streaming SSR, early flushes). The token is resolved, or
+// minted regardless of IssuePredicate and IssueOnNavigationOnly, before fn
+// runs, and its Set-Cookie header is already on the response when fn
+// receives it, so it is sent with the first flushed byte. fn must not rotate
+// the token once it has written the body.
+//
+// Params:
+// - fn: handler receiving the token as its third argument.
+//
+// Returns:
+// - http.Handler wrapped with Protect.
+func (p *Protector) ProtectStreaming(fn func(w http.ResponseWriter, r *http.Request, token string)) http.Handler {
+	return p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tok, err := p.currentToken(w, r)
+		if err != nil {
+			p.fail(w, r, http.StatusInternalServerError, issueFailure(err))
+			return
+		}
+		fn(w, r, tok)
+	}))
+}
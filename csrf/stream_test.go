@@ -0,0 +1,30 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// The cookie is on the response before a streaming handler's first flush,
+// even for requests that would not otherwise be issued a token.
+func TestProtectStreaming(t *testing.T) {
+	p := New(Config{TokenBytes: 16, IssueOnNavigationOnly: true})
+	var atFlush []string
+	h := p.ProtectStreaming(func(w http.ResponseWriter, r *http.Request, tok string) {
+		atFlush = w.Header().Values("Set-Cookie")
+		_, _ = w.Write([]byte(`<meta name="csrf-token" content="` + tok + `">`))
+		_ = http.NewResponseController(w).Flush()
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/page", nil))
+	c := getCookieByName(rec.Result(), "csrf_token")
+	if c == nil || len(atFlush) == 0 {
+		t.Fatal("expected the cookie to be set before the first flush")
+	}
+	if !strings.Contains(rec.Body.String(), c.Value) {
+		t.Fatalf("expected the streamed token to match the cookie, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,142 @@
+package csrf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// defaultSessionTokenTTL is the default lifetime of a stored session token.
+const defaultSessionTokenTTL = 24 * time.Hour
+
+// ErrNoSession rejects an unsafe request in synchronizer token mode when
+// SessionID finds no session, so there is no stored token to compare with.
+var ErrNoSession = errors.New("no session for CSRF token")
+
+// synchronized reports whether tokens are kept server-side per session
+// (Config.SessionTokenStore) instead of in the cookie.
+func (p *Protector) synchronized() bool {
+	return p.cfg.SessionTokenStore != nil
+}
+
+// sessionKey returns the SessionTokenStore key of r's session, or "" when
+// SessionID finds none.
+func (p *Protector) sessionKey(r *http.Request) string {
+	id := p.cfg.SessionID(r)
+	if id == "" {
+		return ""
+	}
+	return "csrf-session:" + id
+}
+
+// sessionStore names SessionTokenStore in unavailable errors.
+const sessionStore = "session token store"
+
+// sessionToken returns the token stored for r's session. A failed lookup,
+// or one skipped while StoreBreaker is open, is an error and never a
+// missing token: the stored token is the check itself, so the request fails
+// closed regardless of BackendFailurePolicy and no token is issued over
+// the one that could not be read.
+//
+// Params:
+// - r: current request.
+//
+// Returns:
+// - token (string), a boolean indicating whether a usable token was found,
+// and an error wrapping ErrUnavailable when the store could not be read.
+func (p *Protector) sessionToken(r *http.Request) (string, bool, error) {
+	key := p.sessionKey(r)
+	if key == "" {
+		return "", false, nil
+	}
+	if !p.cfg.StoreBreaker.allow() {
+		p.stats.breakerSkipped.Add(1)
+		return "", false, unavailable(sessionStore)
+	}
+	ctx, cancel := p.storeContext(r)
+	defer cancel()
+	tok, ok, err := p.cfg.SessionTokenStore.Get(ctx, key)
+	p.cfg.StoreBreaker.record(err)
+	if err != nil {
+		p.cfg.Logger.Warn("csrf: session token lookup failed",
+			"error", err, "method", r.Method, "path", r.URL.Path)
+		return "", false, unavailable(sessionStore)
+	}
+	if !ok || !p.acceptToken(tok) {
+		return "", false, nil
+	}
+	return tok, true, nil
+}
+
+// issueToken hands a newly minted token to the client: as the cookie or,
+// in synchronizer token mode, by storing it for r's session (the client
+// reads it from the request context, TokenHandler or the form field).
+//
+// Params:
+// - w: response writer the cookie is set on.
+// - r: current request.
+// - tok: the new token.
+//
+// Returns:
+// - nil on success; ErrNoSession, or an error wrapping ErrUnavailable when
+// the store failed or StoreBreaker is open.
+func (p *Protector) issueToken(w http.ResponseWriter, r *http.Request, tok string) error {
+	if !p.synchronized() {
+		p.setCookie(w, r, tok)
+		return nil
+	}
+	key := p.sessionKey(r)
+	if key == "" {
+		return ErrNoSession
+	}
+	if !p.cfg.StoreBreaker.allow() {
+		p.stats.breakerSkipped.Add(1)
+		return unavailable(sessionStore)
+	}
+	ctx, cancel := p.storeContext(r)
+	defer cancel()
+	err := p.cfg.SessionTokenStore.Set(ctx, key, tok, p.cfg.SessionTokenTTL)
+	p.cfg.StoreBreaker.record(err)
+	if err != nil {
+		p.cfg.Logger.Warn("csrf: session token store failed",
+			"error", err, "method", r.Method, "path", r.URL.Path)
+		return unavailable(sessionStore)
+	}
+	return nil
+}
+
+// DeleteSessionToken removes the stored token of a session in synchronizer
+// token mode, e.g. when the session is destroyed on logout, so the token
+// dies with it rather than at SessionTokenTTL.
+//
+// Params:
+// - ctx: context bounding the store call.
+// - sessionID: the session, as returned by Config.SessionID.
+//
+// Returns:
+// - the store error, if any; nil when synchronizer mode is off.
+func (p *Protector) DeleteSessionToken(ctx context.Context, sessionID string) error {
+	if !p.synchronized() || sessionID == "" {
+		return nil
+	}
+	return p.cfg.SessionTokenStore.Delete(ctx, "csrf-session:"+sessionID)
+}
+
+// validateSynchronizer rejects synchronizer token mode without SessionID or
+// combined with the features relying on companion cookies.
+func validateSynchronizer(cfg Config) error {
+	if cfg.SessionTokenStore == nil {
+		return nil
+	}
+	switch {
+	case cfg.SessionID == nil:
+		return fmt.Errorf("csrf: SessionTokenStore needs SessionID")
+	case cfg.DeviceCookie != "":
+		return fmt.Errorf("csrf: SessionTokenStore cannot be combined with DeviceCookie")
+	case cfg.TrackIssuedAt || cfg.needsIssuedAt():
+		return fmt.Errorf("csrf: SessionTokenStore cannot be combined with TrackIssuedAt or MaxTokenAge (use SessionTokenTTL)")
+	}
+	return nil
+}
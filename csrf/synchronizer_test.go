@@ -0,0 +1,164 @@
+package csrf
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSynchronizerTokenMode(t *testing.T) {
+	var rejected error
+	p := New(Config{
+		SessionTokenStore: NewMemoryTokenStore(),
+		SessionID: func(r *http.Request) string {
+			c, err := r.Cookie("sid")
+			if err != nil {
+				return ""
+			}
+			return c.Value
+		},
+		OnReject: func(r *http.Request, err error) { rejected = err },
+	})
+	var tok string
+	h := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tok, _ = TokenFromContext(r.Context())
+	}))
+	send := func(method, sid, token string) int {
+		req := httptest.NewRequest(method, "/", nil)
+		if sid != "" {
+			req.AddCookie(&http.Cookie{Name: "sid", Value: sid})
+		}
+		if token != "" {
+			req.Header.Set("X-CSRF-Token", token)
+			req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token}) // double-submit is not enough
+		}
+		rejected = nil
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if getCookieByName(rec.Result(), "csrf_token") != nil {
+			t.Fatal("synchronizer mode set the token cookie")
+		}
+		return rec.Code
+	}
+
+	send(http.MethodGet, "s1", "")
+	if tok == "" {
+		t.Fatal("no token for the session")
+	}
+	issued := tok
+	if send(http.MethodGet, "s1", ""); tok != issued {
+		t.Fatal("session token not reused")
+	}
+	if code := send(http.MethodPost, "s1", issued); code != http.StatusOK {
+		t.Fatalf("valid token: %d (%v)", code, rejected)
+	}
+
+	forged, _ := newToken(32)
+	if code := send(http.MethodPost, "s1", forged); code != http.StatusForbidden || !errors.Is(rejected, ErrTokenMismatch) {
+		t.Fatalf("forged double-submit token: %d (%v)", code, rejected)
+	}
+	if code := send(http.MethodPost, "s2", issued); code != http.StatusForbidden || !errors.Is(rejected, ErrTokenMismatch) {
+		t.Fatalf("token of another session: %d (%v)", code, rejected)
+	}
+	if code := send(http.MethodPost, "", issued); code != http.StatusForbidden || !errors.Is(rejected, ErrNoSession) {
+		t.Fatalf("no session: %d (%v)", code, rejected)
+	}
+
+	if err := p.DeleteSessionToken(context.Background(), "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if code := send(http.MethodPost, "s1", issued); code != http.StatusForbidden {
+		t.Fatalf("deleted session token accepted: %d", code)
+	}
+}
+
+func TestSynchronizerValidate(t *testing.T) {
+	store := NewMemoryTokenStore()
+	if err := (Config{SessionTokenStore: store}).Validate(); err == nil {
+		t.Fatal("missing SessionID accepted")
+	}
+	sid := func(*http.Request) string { return "s" }
+	if err := (Config{SessionTokenStore: store, SessionID: sid, MaxTokenAge: 1}).Validate(); err == nil {
+		t.Fatal("MaxTokenAge accepted")
+	}
+	if err := (Config{SessionTokenStore: store, SessionID: sid}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// flakySessionStore wraps a MemoryTokenStore whose reads can be failed.
+type flakySessionStore struct {
+	*MemoryTokenStore
+	down       bool
+	gets, sets int
+}
+
+func (s *flakySessionStore) Get(ctx context.Context, key string) (string, bool, error) {
+	s.gets++
+	if s.down {
+		return "", false, errors.New("store down")
+	}
+	return s.MemoryTokenStore.Get(ctx, key)
+}
+
+func (s *flakySessionStore) Set(ctx context.Context, key, token string, ttl time.Duration) error {
+	s.sets++
+	return s.MemoryTokenStore.Set(ctx, key, token, ttl)
+}
+
+// A failed read answers 500 without issuing a token over the stored one,
+// even with FailOpen, and trips StoreBreaker.
+func TestSynchronizerStoreFailure(t *testing.T) {
+	store := &flakySessionStore{MemoryTokenStore: NewMemoryTokenStore()}
+	var failed error
+	p := New(Config{
+		SessionTokenStore:    store,
+		SessionID:            func(*http.Request) string { return "s1" },
+		BackendFailurePolicy: FailOpen,
+		StoreBreaker:         NewCircuitBreaker(2, time.Minute),
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, status int, err error) {
+			failed = err
+			w.WriteHeader(status)
+		},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	var tok string
+	h := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tok, _ = TokenFromContext(r.Context())
+	}))
+	send := func(method, token string) int {
+		req := httptest.NewRequest(method, "/", nil)
+		if token != "" {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		failed = nil
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	send(http.MethodGet, "")
+	stored := tok
+
+	store.down = true
+	store.sets = 0
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if code := send(method, stored); code != http.StatusInternalServerError || !errors.Is(failed, ErrUnavailable) {
+			t.Fatalf("%s with store down: %d (%v)", method, code, failed)
+		}
+	}
+	if store.sets != 0 {
+		t.Fatalf("token issued %d times over an unreadable one", store.sets)
+	}
+	gets := store.gets
+	if code := send(http.MethodPost, stored); code != http.StatusInternalServerError || store.gets != gets {
+		t.Fatalf("breaker open: %d, store called %d times", code, store.gets-gets)
+	}
+	if v, _, _ := store.MemoryTokenStore.Get(context.Background(), "csrf-session:s1"); v != stored {
+		t.Fatal("stored token replaced")
+	}
+}
@@ -2,12 +2,20 @@ package csrf
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
-	"net/url"
 	"strings"
 )
 
+// maxStackTokenBytes is the largest decoded token size compared using
+// stack-allocated buffers; larger TokenBytes values fall back to the heap.
+const maxStackTokenBytes = 64
+
 // newToken generates a random URL-safe token.
 //
 // Params:
@@ -25,45 +33,166 @@ func newToken(n int) (string, error) {
 	return s, nil
 }
 
+// newToken returns a token of TokenBytes, taken from the token pool when one
+// is available and signed when signing keys are set. It fails at the injected
+// rate.
+//
+// Returns:
+// - the token, or an error if generation fails.
+func (p *Protector) newToken() (string, error) {
+	if p.cfg.FaultInjector.failToken() {
+		return "", errInjectedTokenFailure
+	}
+	tok, ok := p.pool.take()
+	if ok {
+		p.stats.poolHits.Add(1)
+	} else {
+		if p.pool != nil {
+			p.stats.poolMisses.Add(1)
+		}
+		var err error
+		if tok, err = newToken(p.cfg.TokenBytes); err != nil {
+			return "", err
+		}
+	}
+	if p.keyring() != nil {
+		tok = p.signToken(tok)
+	}
+	return tok, nil
+}
+
+// decodeToken decodes the base64url token s into dst, which must be sized to
+// the configured TokenBytes. Trailing "=" padding is tolerated so clients that
+// re-encode with padding still match.
+//
+// Params:
+// - dst: destination buffer; its length is the expected decoded size.
+// - s: encoded token as received from the cookie, header or form.
+//
+// Returns:
+// - true if s decodes to exactly len(dst) bytes; false otherwise.
+func decodeToken(dst []byte, s string) bool {
+	s = strings.TrimRight(s, "=")
+	if s == "" || base64.RawURLEncoding.DecodedLen(len(s)) != len(dst) {
+		return false
+	}
+	n, err := base64.RawURLEncoding.Decode(dst, []byte(s))
+	return err == nil && n == len(dst)
+}
+
+// tokensEqual decodes both tokens to n raw bytes and compares them in
+// constant time. Malformed tokens never compare equal.
+//
+// Params:
+// - a, b: encoded tokens to compare.
+// - n: expected decoded size (Config.TokenBytes).
+//
+// Returns:
+// - true if both decode to n bytes and the bytes are equal; false otherwise.
+func tokensEqual(a, b string, n int) bool {
+	var bufA, bufB [maxStackTokenBytes]byte
+	var da, db []byte
+	if n <= maxStackTokenBytes {
+		da, db = bufA[:n], bufB[:n]
+	} else {
+		da, db = make([]byte, n), make([]byte, n)
+	}
+	okA := decodeToken(da, a)
+	okB := decodeToken(db, b)
+	return okA && okB && subtle.ConstantTimeCompare(da, db) == 1
+}
+
+// validToken reports whether s is a well-formed token of n decoded bytes.
+//
+// Params:
+// - s: encoded token.
+// - n: expected decoded size (Config.TokenBytes).
+//
+// Returns:
+// - true if s decodes to exactly n bytes.
+func validToken(s string, n int) bool {
+	var buf [maxStackTokenBytes]byte
+	if n <= maxStackTokenBytes {
+		return decodeToken(buf[:n], s)
+	}
+	return decodeToken(make([]byte, n), s)
+}
+
+// tokenETag returns a weak ETag derived from tok. The token is hashed so the
+// ETag does not reveal it to caches or logs.
+//
+// Params:
+// - tok: the current token.
+//
+// Returns:
+// - the quoted weak ETag (e.g., W/"0123abcd...").
+func tokenETag(tok string) string {
+	sum := sha256.Sum256([]byte(tok))
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatch reports whether an If-None-Match header value lists etag using
+// weak comparison. "*" never matches: the token is per client, so a client
+// that has not seen it cannot hold a current copy.
+//
+// Params:
+// - header: raw If-None-Match value (comma-separated list).
+// - etag: the current ETag.
+//
+// Returns:
+// - true if the client's cached representation is still current.
+func etagMatch(header, etag string) bool {
+	want := strings.TrimPrefix(etag, "W/")
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if strings.TrimPrefix(v, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBody reports whether r may carry a request body.
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
 // extractClientToken tries to read the CSRF token provided by the client.
 //
 // It first checks the header name provided, and if empty, it falls back to
-// the form field (works for x-www-form-urlencoded and multipart).
+// the form fields (works for x-www-form-urlencoded and multipart). Requests
+// without a body (typically DELETE, or a POST with Content-Length 0) are never
+// form-parsed.
 //
 // Params:
 // - r: incoming request possibly containing header or form token.
 // - headerName: the HTTP header to read the token from (e.g., X-CSRF-Token).
-// - formField: the form field name to read the token from.
+// - formFields: the form field names to read the token from, in order.
+// - headerOnly: skip the form fallback.
 //
 // Returns:
-// - the token string if found; otherwise empty string.
-func extractClientToken(r *http.Request, headerName, formField string) string {
+//   - the token string if found; otherwise empty string.
+//   - ErrBodyTooLarge when the body exceeded an http.MaxBytesReader limit
+//     (e.g., set by http.MaxBytesHandler) while the form was parsed.
+func extractClientToken(r *http.Request, headerName string, formFields []string, headerOnly bool) (string, error) {
 	// Check header first
 	if h := r.Header.Get(headerName); h != "" {
-		return h
+		return h, nil
+	}
+	if headerOnly || !hasBody(r) {
+		return "", nil
 	}
 	// Then check form (x-www-form-urlencoded / multipart)
-	_ = r.ParseForm()
-	if v := r.Form.Get(formField); v != "" {
-		return v
+	if err := r.ParseForm(); err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return "", fmt.Errorf("%w (limit %d bytes)", ErrBodyTooLarge, mbe.Limit)
+		}
 	}
-	return ""
-}
-
-// sameSite checks if originOrRef is same-site with the allowed host.
-// It compares only the host (which may include the port).
-//
-// Params:
-// - originOrRef: Origin or Referer URL string.
-// - allowedHost: the host to consider same-site against.
-//
-// Returns:
-// - true if the parsed URL host matches allowedHost (case-insensitive); false otherwise.
-func sameSite(originOrRef, allowedHost string) bool {
-	u, err := url.Parse(originOrRef)
-	if err != nil {
-		return false
+	for _, field := range formFields {
+		if v := r.Form.Get(field); v != "" {
+			return v, nil
+		}
 	}
-	// Compara apenas host (pode incluir porta). Opcional: normalizar porta padrão.
-	return strings.EqualFold(u.Host, allowedHost)
+	return "", nil
 }
@@ -0,0 +1,89 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrCrossSiteTokenRequest refuses a cross-site token endpoint request
+// (see Config.TokenEndpointSameSite).
+var ErrCrossSiteTokenRequest = errors.New("cross-site token request")
+
+// guardTokenEndpoint applies TokenEndpointSameSite and TokenEndpointLimiter
+// to a token endpoint request, before any token is minted. When the request
+// is turned away, it writes the response itself.
+//
+// Params:
+// - w: response writer for the rejection response.
+// - r: token endpoint request.
+//
+// Returns:
+// - true if the token may be served.
+func (p *Protector) guardTokenEndpoint(w http.ResponseWriter, r *http.Request) bool {
+	cfg := p.cfg
+	if cfg.TokenEndpointSameSite && !p.sameSiteTokenRequest(r) {
+		p.denyTokenRequest(w, r, http.StatusForbidden, ErrCrossSiteTokenRequest)
+		return false
+	}
+	l := cfg.TokenEndpointLimiter
+	if l == nil {
+		return true
+	}
+	ctx, cancel := p.storeContext(r)
+	defer cancel()
+	key := clientIP(r, cfg.TrustedProxies)
+	ok, err := l.Allow(ctx, key)
+	if err == nil && ok {
+		// every served request uses up one unit of the client's budget
+		err = l.Fail(ctx, key)
+	}
+	if err != nil {
+		if cfg.BackendFailurePolicy == FailClosed {
+			p.fail(w, r, http.StatusInternalServerError, unavailable("limiter"))
+			return false
+		}
+		cfg.Logger.Warn("csrf: store unavailable, check skipped",
+			"store", "token endpoint limiter", "error", err, "method", r.Method, "path", r.URL.Path)
+		return true
+	}
+	if !ok {
+		p.denyTokenRequest(w, r, http.StatusTooManyRequests, ErrRateLimited)
+		return false
+	}
+	return true
+}
+
+// sameSiteTokenRequest reports whether r may come from a page of this site
+// (or TokenCORSOrigin). Fetch metadata decides when present; otherwise the
+// Origin or Referer must match. Requests with neither, such as those of
+// non-browser clients, are allowed: they cannot carry a victim's cookies.
+func (p *Protector) sameSiteTokenRequest(r *http.Request) bool {
+	if o := p.cfg.TokenCORSOrigin; o != "" && strings.EqualFold(r.Header.Get("Origin"), o) {
+		return true
+	}
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "same-site", "none":
+		return true
+	case "cross-site":
+		return false
+	}
+	err := p.validateOriginOrReferer(r)
+	return err == nil || errors.Is(err, ErrNoOrigin)
+}
+
+// denyTokenRequest counts and reports a refused token endpoint request and
+// writes the error response. Unlike reject, it never sets a cookie, and it
+// drops one Protect minted for this request.
+func (p *Protector) denyTokenRequest(w http.ResponseWriter, r *http.Request, status int, err error) {
+	r = withFailure(r, err)
+	p.stats.tokenDenied.Add(1)
+	p.dropResponseCookie(w, r)
+	if p.cfg.OnReject != nil {
+		p.cfg.OnReject(r, err)
+	}
+	if p.cfg.OnRejectEvent != nil {
+		p.cfg.OnRejectEvent(p.NewRejectionEvent(r, err))
+	}
+	p.fail(w, r, status, err)
+}
@@ -0,0 +1,57 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenEndpointSameSite(t *testing.T) {
+	p := New(Config{TokenBytes: 16, TokenEndpointSameSite: true, TokenCORSOrigin: "https://spa.example.com"})
+	h := p.Protect(p.TokenHandler())
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		code    int
+	}{
+		{"same-origin fetch", map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusOK},
+		{"cross-site fetch", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.com"}, http.StatusForbidden},
+		{"cors origin", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://spa.example.com"}, http.StatusOK},
+		{"foreign referer", map[string]string{"Referer": "https://evil.com/page"}, http.StatusForbidden},
+		{"own referer", map[string]string{"Referer": "http://example.com/page"}, http.StatusOK},
+		{"no metadata", nil, http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/csrf-token", nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("%s: got %d, want %d", tc.name, rec.Code, tc.code)
+		}
+		if rec.Code == http.StatusForbidden && len(rec.Result().Cookies()) != 0 {
+			t.Errorf("%s: cookie set on a refused request", tc.name)
+		}
+	}
+	if n := p.stats.tokenDenied.Load(); n != 2 {
+		t.Fatalf("expected 2 denied requests, got %d", n)
+	}
+}
+
+func TestTokenEndpointLimiter(t *testing.T) {
+	p := New(Config{TokenBytes: 16, TokenEndpointLimiter: NewMemoryLimiter(3, time.Hour)})
+	h := p.TokenHandler()
+	var codes []int
+	for range 4 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/csrf-token", nil))
+		codes = append(codes, rec.Code)
+	}
+	if codes[2] != http.StatusOK || codes[3] != http.StatusTooManyRequests {
+		t.Fatalf("got %v", codes)
+	}
+}
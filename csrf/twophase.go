@@ -0,0 +1,128 @@
+package csrf
+
+import "net/http"
+
+// Prepare is the first phase of a two-phase setup for routers that answer
+// unmatched methods or paths themselves: it issues the cookie and injects
+// the token like Protect, but hands unsafe requests no route matches to
+// the router unchecked, so a PUT to a GET-only route gets the router's 405
+// rather than a CSRF 403:
+//
+//	mux.Handle("GET /profile", show)
+//	mux.Handle("POST /profile", p.Enforce(update))
+//	http.ListenAndServe(addr, p.Prepare(mux))
+//
+// The route is resolved before anything is served, through a
+// Handler(*http.Request) (http.Handler, string) method like that of
+// http.ServeMux; every matched unsafe request is validated before the
+// router runs, so a route missing Enforce still fails closed. With routers
+// lacking that method Prepare validates like Protect; use PrepareRequest
+// and ValidateRequest after matching instead.
+//
+// Params:
+// - next: the router.
+//
+// Returns:
+// - http.Handler performing the first phase.
+func (p *Protector) Prepare(next http.Handler) http.Handler {
+	return p.protect(next, true)
+}
+
+// Enforce is the second phase of a Prepare setup, marking the routes whose
+// unsafe requests are validated. Prepare already validated them, so behind
+// Prepare it passes requests through; used without Prepare, it is Protect.
+//
+// Params:
+// - next: the route handler.
+//
+// Returns:
+// - http.Handler validating unsafe requests before next.
+func (p *Protector) Enforce(next http.Handler) http.Handler {
+	return p.Protect(next)
+}
+
+// routeResolver is implemented by routers that report the route matching a
+// request without serving it, such as http.ServeMux.
+type routeResolver interface {
+	Handler(r *http.Request) (h http.Handler, pattern string)
+}
+
+// unrouted reports whether next resolves routes and matches none for r, so
+// it answers r itself (404 or 405).
+//
+// Params:
+// - next: the router.
+// - r: unsafe request.
+//
+// Returns:
+// - true if the router turns r away; false otherwise, or when next cannot
+// resolve routes.
+func unrouted(next http.Handler, r *http.Request) bool {
+	rr, ok := next.(routeResolver)
+	if !ok {
+		return false
+	}
+	_, pattern := rr.Handler(r)
+	return pattern == ""
+}
+
+// PrepareRequest is the lower-level form of Prepare for routers that
+// decide per route, after matching, whether and how to validate (e.g.,
+// from policies stored in route metadata): it rejects oversized headers,
+// issues the cookie and injects the token into the request context.
+// Follow it with ValidateRequest for the routes that need it.
+//
+//	r, ok := p.PrepareRequest(w, r)
+//	if !ok {
+//		return
+//	}
+//	route := router.Match(r)
+//	if route.CSRF != nil && !p.ValidateRequest(w, r, route.CSRF) {
+//		return
+//	}
+//	route.Handler.ServeHTTP(w, r)
+//
+// Params:
+// - w: response writer the cookie is set on.
+// - r: incoming request.
+//
+// Returns:
+// - the request to route (carrying the token), and false when the
+// response was already written (431 or 500).
+func (p *Protector) PrepareRequest(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if p.cfg.ForwardAssertion != "" {
+		r.Header.Del(p.cfg.ForwardAssertion)
+	}
+	r, _, ok := p.prepare(w, r, true)
+	return r, ok
+}
+
+// ValidateRequest is the lower-level form of Enforce: it runs the checks of
+// Protect on an unsafe request under rule, the policy of the matched route
+// (nil for the Config defaults; PathPrefix, Methods and CookieScope are
+// ignored), with the same hooks, counters and rejection responses. Safe
+// requests pass unchecked.
+//
+// Params:
+// - w: response writer for the rejection response.
+// - r: request returned by PrepareRequest.
+// - rule: the route's policy, or nil.
+//
+// Returns:
+// - true if the handler may run (valid, exempted or report-only); false
+// when the rejection was written.
+func (p *Protector) ValidateRequest(w http.ResponseWriter, r *http.Request, rule *Rule) bool {
+	if !unsafeMethods[r.Method] {
+		return true
+	}
+	tok, ok := "", false
+	if st, isState := r.Context().Value(tokenKey).(*requestState); isState && st.p == p {
+		tok, ok = st.token, true
+	}
+	if !ok {
+		tok, _ = p.cookieToken(r)
+	}
+	passed := false
+	p.enforce(w, r, rule, tok, http.HandlerFunc(func(http.ResponseWriter, *http.Request) { passed = true }))
+	return passed
+}
@@ -0,0 +1,146 @@
+package csrf
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// With Prepare, the router answers unrouted methods before validation, and
+// routed unsafe requests are validated, with or without Enforce.
+func TestPrepareEnforce(t *testing.T) {
+	p := New(Config{})
+	ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("GET /profile", ok)
+	mux.Handle("POST /profile", p.Enforce(ok))
+	mux.Handle("POST /unguarded", ok)
+	app := p.Prepare(mux)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profile", nil))
+	cookie := getCookieByName(rec.Result(), "csrf_token")
+	if rec.Code != http.StatusOK || cookie == nil {
+		t.Fatalf("GET: got %d, cookie %v", rec.Code, cookie)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		token        bool
+		want         int
+	}{
+		{http.MethodPut, "/profile", false, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/missing", false, http.StatusNotFound},
+		{http.MethodPost, "/profile", false, http.StatusForbidden},
+		{http.MethodPost, "/profile", true, http.StatusOK},
+		{http.MethodPost, "/unguarded", false, http.StatusForbidden},
+		{http.MethodPost, "/unguarded", true, http.StatusOK},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.AddCookie(cookie)
+		if tc.token {
+			req.Header.Set("X-CSRF-Token", cookie.Value)
+		}
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s %s: got %d, want %d", tc.method, tc.path, rec.Code, tc.want)
+		}
+	}
+	if n := p.stats.validated.Load(); n != 2 {
+		t.Fatalf("validated: got %d, want 2", n)
+	}
+}
+
+// A router that cannot resolve routes ahead is validated like Protect, so
+// its handlers never run an unchecked unsafe request.
+func TestPrepareUnresolvableRouter(t *testing.T) {
+	p := New(Config{})
+	reached := false
+	app := p.Prepare(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { reached = true }))
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/anything", nil))
+	if rec.Code != http.StatusForbidden || reached {
+		t.Fatalf("got %d, reached %v", rec.Code, reached)
+	}
+}
+
+// Routers can validate per route with policies kept in route metadata.
+func TestPrepareValidateRequest(t *testing.T) {
+	p := New(Config{})
+	policies := map[string]*Rule{
+		"/strict":  {},
+		"/rollout": {ReportOnly: true},
+	}
+	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, ok := p.PrepareRequest(w, r)
+		if !ok {
+			return
+		}
+		if rule, ok := policies[r.URL.Path]; ok && !p.ValidateRequest(w, r, rule) {
+			return
+		}
+		if _, ok := TokenFromContext(r.Context()); !ok {
+			t.Error("token not in context")
+		}
+	})
+
+	for path, want := range map[string]int{"/strict": http.StatusForbidden, "/rollout": http.StatusOK, "/public": http.StatusOK} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: got %d, want %d", path, rec.Code, want)
+		}
+	}
+	if r, v := p.stats.rejected.Load(), p.stats.reported.Load(); r != 1 || v != 1 {
+		t.Fatalf("rejected %d, reported %d", r, v)
+	}
+}
+
+// hijackRecorder is a ResponseRecorder supporting http.Hijacker.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	c, _ := net.Pipe()
+	return c, bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c)), nil
+}
+
+// Writers behind Prepare keep their optional interfaces on unsafe requests.
+func TestPrepareKeepsHijacker(t *testing.T) {
+	p := New(Config{})
+	mux := http.NewServeMux()
+	upgrade := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Pusher); ok {
+			t.Error("Pusher exposed without support")
+		}
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("%s: hijack: %v", r.Method, err)
+			return
+		}
+		conn.Close()
+	})
+	mux.Handle("GET /ws", upgrade)
+	mux.Handle("POST /ws", p.Enforce(upgrade))
+	app := p.Prepare(mux)
+
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	cookie := getCookieByName(rec.Result(), "csrf_token")
+
+	req := httptest.NewRequest(http.MethodPost, "/ws", nil)
+	req.AddCookie(cookie)
+	req.Header.Set("X-CSRF-Token", cookie.Value)
+	rec = &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	app.ServeHTTP(rec, req)
+	if !rec.hijacked {
+		t.Fatal("POST not hijacked")
+	}
+}
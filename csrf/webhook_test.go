@@ -0,0 +1,71 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Signed webhooks skip the CSRF check and the handler still sees the body.
+func TestWebhookExemption(t *testing.T) {
+	gh := WebhookVerifier{Header: "X-Hub-Signature-256", Prefix: "sha256=", Secret: []byte("s3cret")}
+	var body string
+	p := New(Config{Exempt: gh.Exempt})
+	h := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+
+	payload := `{"action":"opened"}`
+	cases := map[string]int{
+		"sha256=" + sign("s3cret", payload): http.StatusOK,
+		"sha256=" + sign("wrong", payload):  http.StatusForbidden,
+		"":                                  http.StatusForbidden,
+	}
+	for sig, want := range cases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/hooks/github", strings.NewReader(payload))
+		if sig != "" {
+			req.Header.Set("X-Hub-Signature-256", sig)
+		}
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("signature %q: got %d want %d", sig, rec.Code, want)
+		}
+	}
+	if body != payload {
+		t.Fatalf("handler did not receive the original body: %q", body)
+	}
+}
+
+// Stripe-style signatures cover the timestamp and are bounded in time.
+func TestWebhookStripeScheme(t *testing.T) {
+	v := WebhookVerifier{Header: "Stripe-Signature", Secret: []byte("whsec"), Scheme: WebhookStripe}
+	payload := `{"id":"evt_1"}`
+
+	check := func(ts int64) bool {
+		t := strconv.FormatInt(ts, 10)
+		req := httptest.NewRequest(http.MethodPost, "/hooks/stripe", strings.NewReader(payload))
+		req.Header.Set("Stripe-Signature", "t="+t+",v1="+sign("whsec", t+"."+payload))
+		return v.Exempt(req)
+	}
+	if !check(time.Now().Unix()) {
+		t.Fatalf("expected fresh Stripe signature to verify")
+	}
+	if check(time.Now().Add(-time.Hour).Unix()) {
+		t.Fatalf("expected stale Stripe signature to fail")
+	}
+}
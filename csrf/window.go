@@ -0,0 +1,132 @@
+package csrf
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// defaultStatsWindow is the default span of Stats.
+const defaultStatsWindow = time.Hour
+
+// windowBuckets is the number of slices a stats window is split into; counts
+// expire one slice at a time.
+const windowBuckets = 60
+
+// Indexes of the event counters in a window bucket; rejection reasons
+// follow, in reasonCodes order, then "other".
+const (
+	windowIssued = iota
+	windowValidated
+	windowReported
+	windowReasons
+)
+
+// windowBucket holds the counts of one slice of the window.
+type windowBucket struct {
+	// slot is the slice number (time / slice length) the counts belong to.
+	slot   atomic.Int64
+	counts []atomic.Int64
+}
+
+// statsWindow keeps rolling-window event counts in a ring of buckets,
+// updated with atomics only. A bucket is recycled by the first event of a
+// new slice; events racing with the recycling may be lost, which is fine
+// for dashboards.
+type statsWindow struct {
+	span    time.Duration
+	slice   time.Duration
+	buckets [windowBuckets]windowBucket
+
+	now func() time.Time
+}
+
+// newStatsWindow returns an empty window spanning span.
+func newStatsWindow(span time.Duration) *statsWindow {
+	w := &statsWindow{span: span, slice: max(span/windowBuckets, time.Millisecond), now: time.Now}
+	for i := range w.buckets {
+		w.buckets[i].slot.Store(-1)
+		w.buckets[i].counts = make([]atomic.Int64, windowReasons+len(reasonCodes)+1)
+	}
+	return w
+}
+
+// add counts one event of kind (a window index) in the current slice.
+func (w *statsWindow) add(kind int) {
+	slot := w.now().UnixNano() / int64(w.slice)
+	b := &w.buckets[slot%windowBuckets]
+	if old := b.slot.Load(); old != slot && b.slot.CompareAndSwap(old, slot) {
+		for i := range b.counts {
+			b.counts[i].Store(0)
+		}
+	}
+	b.counts[kind].Add(1)
+}
+
+// addRejection counts a rejection under the reason of err.
+func (w *statsWindow) addRejection(err error) {
+	kind := windowReasons + len(reasonCodes)
+	for i, rc := range reasonCodes {
+		if errors.Is(err, rc.err) {
+			kind = windowReasons + i
+			break
+		}
+	}
+	w.add(kind)
+}
+
+// WindowStats holds the counts of the last Window, as returned by Stats.
+type WindowStats struct {
+	// Window is the span covered (Config.StatsWindow; nanoseconds in JSON).
+	Window time.Duration `json:"window"`
+	// Issued counts tokens minted and set as cookie.
+	Issued int64 `json:"issued"`
+	// Validated counts unsafe requests that passed validation.
+	Validated int64 `json:"validated"`
+	// Reported counts failures let through in report-only mode.
+	Reported int64 `json:"reported"`
+	// Rejected counts rejections by reason code (see ReasonCode), including
+	// rate-limited and blocked requests. Reasons that did not occur are
+	// omitted.
+	Rejected map[string]int64 `json:"rejected"`
+}
+
+// Stats returns the issued, validated, reported and rejected counts of the
+// last Config.StatsWindow (one hour by default), for a quick JSON endpoint
+// without a metrics stack:
+//
+//	mux.HandleFunc("GET /internal/csrf-stats", func(w http.ResponseWriter, r *http.Request) {
+//		json.NewEncoder(w).Encode(p.Stats())
+//	})
+//
+// Counts expire in 1/60th of the window steps, so the span is approximate.
+// Lifetime totals are in DebugHandler.
+//
+// Returns:
+// - the window counts.
+func (p *Protector) Stats() WindowStats {
+	w := p.window
+	s := WindowStats{Window: w.span, Rejected: map[string]int64{}}
+	oldest := w.now().UnixNano()/int64(w.slice) - windowBuckets + 1
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if b.slot.Load() < oldest {
+			continue
+		}
+		s.Issued += b.counts[windowIssued].Load()
+		s.Validated += b.counts[windowValidated].Load()
+		s.Reported += b.counts[windowReported].Load()
+		for j := windowReasons; j < len(b.counts); j++ {
+			n := b.counts[j].Load()
+			if n == 0 {
+				continue
+			}
+			code := "other"
+			if k := j - windowReasons; k < len(reasonCodes) {
+				code = reasonCodes[k].code
+			}
+			s.Rejected[code] += n
+		}
+	}
+	return s
+}
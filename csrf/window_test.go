@@ -0,0 +1,53 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsCountsWindow(t *testing.T) {
+	p := New(Config{StatsWindow: time.Minute})
+	now := time.Unix(1_000_000, 0)
+	p.window.now = func() time.Time { return now }
+	h := p.Protect(appHandler(p))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/submit", nil))
+	c := getCookieByName(rec.Result(), "csrf_token")
+	if c == nil {
+		t.Fatal("no cookie issued")
+	}
+
+	post := func(token string) {
+		req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(""))
+		req.AddCookie(c)
+		if token != "" {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	post(c.Value)
+	post("")
+	post("")
+
+	s := p.Stats()
+	if s.Window != time.Minute || s.Issued != 1 || s.Validated != 1 || s.Rejected["missing_token"] != 2 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	now = now.Add(30 * time.Second)
+	other, _ := newToken(32)
+	post(other)
+	if s = p.Stats(); s.Rejected["missing_token"] != 2 || s.Rejected["bad_token"] != 1 {
+		t.Fatalf("after 30s: %+v", s)
+	}
+
+	now = now.Add(45 * time.Second)
+	s = p.Stats()
+	if s.Issued != 0 || s.Validated != 0 || s.Rejected["missing_token"] != 0 || s.Rejected["bad_token"] != 1 {
+		t.Fatalf("after 75s: %+v", s)
+	}
+}
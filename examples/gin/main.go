@@ -28,7 +28,7 @@ func main() {
 	p := csrf.New(csrf.Config{
 		CookieSecure:       true, // in production behind HTTPS
 		EnforceOriginCheck: true,
-		AllowedOrigin:      "app.example.com", // if empty, uses r.Host
+		AllowedOrigins:     []string{"app.example.com"}, // if empty, uses r.Host
 	})
 	csrfMW := CsrfMiddleware(p)
 